@@ -0,0 +1,81 @@
+package http2grpc
+
+import (
+	"fmt"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"net/http"
+	"strconv"
+)
+
+const (
+	metricBlockedCalls = "http2grpc_blocked_calls_total"
+
+	// aclRuleNotAllowed labels blocked calls of methods not matching allow list,
+	// methods are not used as labels, because blocked ones are chosen by clients
+	aclRuleNotAllowed = "other"
+)
+
+// MethodACLConfig describes gRPC methods blocked by middleware without calling backend.
+type MethodACLConfig struct {
+	// Allow are glob patterns of gRPC methods passed to backend, all methods are allowed if empty
+	Allow []string `yaml:"allow"`
+	// Deny are glob patterns of gRPC methods blocked even if allowed
+	Deny []string `yaml:"deny"`
+	// Code is gRPC status code of blocked call, usually PERMISSION_DENIED (7) or UNIMPLEMENTED (12)
+	Code int `yaml:"code"`
+	// Message is gRPC status message of blocked call
+	Message string `yaml:"message"`
+}
+
+type methodACL struct {
+	config  *MethodACLConfig
+	allow   methodMatcher
+	deny    methodMatcher
+	metrics *metrics
+}
+
+// newMethodACL returns nil if there are no allow and deny lists.
+func newMethodACL(config *MethodACLConfig, m *metrics) (*methodACL, error) {
+	if len(config.Allow) == 0 && len(config.Deny) == 0 {
+		return nil, nil //nolint:nilnil // disabled feature
+	}
+
+	if config.Code <= grpc.OK || config.Code > grpc.UNAUTHENTICATED {
+		return nil, fmt.Errorf("ERROR: http2grpc: methodACL.code %d is not gRPC error code", config.Code)
+	}
+
+	allow, err := newMethodMatcher(config.Allow)
+	if err != nil {
+		return nil, err
+	}
+
+	deny, err := newMethodMatcher(config.Deny)
+	if err != nil {
+		return nil, err
+	}
+
+	m.register(metricBlockedCalls, metricCounter, "gRPC calls blocked by method allow and deny lists.")
+
+	return &methodACL{config: config, allow: allow, deny: deny, metrics: m}, nil
+}
+
+// intercept blocks method not matching allow list or matching deny list.
+func (a *methodACL) intercept(rw *http2grpcModifier, req *http.Request) bool {
+	method := grpcMethod(req)
+
+	rule, denied := a.deny.matchPattern(method)
+	if !denied {
+		if a.allow.matchOrEmpty(method) {
+			return false
+		}
+
+		rule = aclRuleNotAllowed
+	}
+
+	LoggerINFO.Printf("acl: method %q blocked with code %d by rule %s", req.URL.Path, a.config.Code, rule)
+	a.metrics.add(metricBlockedCalls, 1, "rule", rule, "code", strconv.Itoa(a.config.Code))
+
+	rw.writeGrpcStatus(a.config.Code, a.config.Message)
+
+	return true
+}
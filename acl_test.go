@@ -0,0 +1,188 @@
+package http2grpc_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/v-electrolux/http2grpc"
+)
+
+type TestMethodACLData struct {
+	cfgAllow   []string
+	cfgDeny    []string
+	cfgCode    int
+	cfgMessage string
+
+	reqPaths []string
+
+	expBackendCalls      int
+	expGrpcResStatusCode int
+	expGrpcResStatusMsg  string
+	expMetrics           []string
+}
+
+func TestMethodACLDenied(t *testing.T) {
+	data := TestMethodACLData{
+		cfgDeny: []string{"/admin.v1.Admin/*"},
+
+		reqPaths: []string{"/admin.v1.Admin/Drop", "/admin.v1.Admin/Drop"},
+
+		expBackendCalls:      0,
+		expGrpcResStatusCode: 7,
+		expGrpcResStatusMsg:  "method is not allowed",
+		expMetrics: []string{
+			`http2grpc_blocked_calls_total{rule="/admin.v1.Admin/*",code="7"} 2`,
+		},
+	}
+	testMethodACLRequest(t, data)
+}
+
+func TestMethodACLNotAllowed(t *testing.T) {
+	data := TestMethodACLData{
+		cfgAllow:   []string{"/pkg.v1.Public/*"},
+		cfgCode:    12,
+		cfgMessage: "unknown method",
+
+		reqPaths: []string{"/pkg.v1.Internal/Get", "/pkg.v1.Internal/Put"},
+
+		expBackendCalls:      0,
+		expGrpcResStatusCode: 12,
+		expGrpcResStatusMsg:  "unknown method",
+		expMetrics: []string{
+			`http2grpc_blocked_calls_total{rule="other",code="12"} 2`,
+		},
+	}
+	testMethodACLRequest(t, data)
+}
+
+func TestMethodACLDenyOverridesAllow(t *testing.T) {
+	data := TestMethodACLData{
+		cfgAllow: []string{"/pkg.v1.Public/*"},
+		cfgDeny:  []string{"/pkg.v1.Public/Debug"},
+
+		reqPaths: []string{"/pkg.v1.Public/Debug"},
+
+		expBackendCalls:      0,
+		expGrpcResStatusCode: 7,
+		expGrpcResStatusMsg:  "method is not allowed",
+		expMetrics: []string{
+			`http2grpc_blocked_calls_total{rule="/pkg.v1.Public/Debug",code="7"} 1`,
+		},
+	}
+	testMethodACLRequest(t, data)
+}
+
+func TestMethodACLAllowed(t *testing.T) {
+	data := TestMethodACLData{
+		cfgAllow: []string{"/pkg.v1.Public/*"},
+		cfgDeny:  []string{"/pkg.v1.Public/Debug"},
+
+		reqPaths: []string{"/pkg.v1.Public/Get"},
+
+		expBackendCalls:      1,
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+		expMetrics: []string{
+			"# TYPE http2grpc_blocked_calls_total counter",
+		},
+	}
+	testMethodACLRequest(t, data)
+}
+
+func TestMethodACLInvalidCode(t *testing.T) {
+	cfg := http2grpc.CreateConfig()
+	cfg.MethodACL.Deny = []string{"/admin.v1.Admin/*"}
+	cfg.MethodACL.Code = 0
+
+	_, err := http2grpc.New(context.Background(), http.NotFoundHandler(), cfg, "http2grpc")
+	if err == nil {
+		t.Errorf("expected error for OK code of blocked call")
+	}
+}
+
+func testMethodACLRequest(t *testing.T, data TestMethodACLData) {
+	t.Helper()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.MethodACL.Allow = data.cfgAllow
+	cfg.MethodACL.Deny = data.cfgDeny
+	cfg.Metrics.Path = "/metrics"
+
+	if data.cfgCode != 0 {
+		cfg.MethodACL.Code = data.cfgCode
+	}
+
+	if data.cfgMessage != "" {
+		cfg.MethodACL.Message = data.cfgMessage
+	}
+
+	backendCalls := 0
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		backendCalls++
+
+		rw.Header().Set("Content-Type", "application/grpc")
+		rw.Header().Set("Trailer", "grpc-status")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte{0x00, 0x00, 0x00, 0x00, 0x00})
+		rw.Header().Set("grpc-status", "0")
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, reqPath := range data.reqPaths {
+		recorder := httptest.NewRecorder()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost"+reqPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		handler.ServeHTTP(recorder, req)
+		resp := recorder.Result()
+
+		assertStatusCode(t, resp, http.StatusOK)
+		assertBody(t, resp, []byte{0x00, 0x00, 0x00, 0x00, 0x00})
+		assertHeader(t, resp, "Content-Type", "application/grpc")
+		assertTrailer(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+		assertTrailer(t, resp, "grpc-message", data.expGrpcResStatusMsg)
+	}
+
+	if backendCalls != data.expBackendCalls {
+		t.Errorf("expected backend calls: `%d`, got: `%d`", data.expBackendCalls, backendCalls)
+	}
+
+	assertMetrics(t, handler, data.expMetrics)
+}
+
+func assertMetrics(t *testing.T, handler http.Handler, expected []string) {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(recorder, req)
+
+	body, err := ioutil.ReadAll(recorder.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("expected metrics line: `%s`, got metrics:\n%s", line, body)
+		}
+	}
+}
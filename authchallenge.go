@@ -0,0 +1,160 @@
+package http2grpc
+
+import (
+	"github.com/v-electrolux/http2grpc/grpc"
+	"github.com/v-electrolux/http2grpc/internal/pb"
+	"strings"
+)
+
+const (
+	// WWWAuthenticateHeaderName is challenge of 401 response, it is also sent as trailer of converted response
+	WWWAuthenticateHeaderName = "WWW-Authenticate"
+
+	AuthChallengeReasonRequired = "AUTHENTICATION_REQUIRED"
+)
+
+// authChallenge is the first challenge of WWW-Authenticate header from RFC 7235,
+// its params are like the ones of Bearer scheme from RFC 6750: realm, scope, error, error_description.
+type authChallenge struct {
+	// raw is the whole header value
+	raw    string
+	scheme string
+	// params are keyed by lower case names
+	params map[string]string
+}
+
+// parseAuthChallenge parses the first challenge of WWW-Authenticate header value,
+// token68 credentials and the following challenges are ignored, nil is returned if there is no scheme.
+func parseAuthChallenge(value string) *authChallenge {
+	rest := strings.TrimLeft(value, " \t")
+
+	scheme, rest := consumeToken(rest)
+	if scheme == "" {
+		return nil
+	}
+
+	challenge := &authChallenge{raw: value, scheme: scheme, params: make(map[string]string)}
+
+	for {
+		var name, paramValue string
+
+		name, rest = consumeToken(strings.TrimLeft(rest, " \t"))
+		rest = strings.TrimLeft(rest, " \t")
+
+		if name == "" || !strings.HasPrefix(rest, "=") {
+			return challenge
+		}
+
+		rest = strings.TrimLeft(rest[1:], " \t")
+		if strings.HasPrefix(rest, `"`) {
+			paramValue, rest = consumeQuotedString(rest)
+		} else {
+			paramValue, rest = consumeToken(rest)
+		}
+
+		challenge.params[strings.ToLower(name)] = paramValue
+
+		rest = strings.TrimLeft(rest, " \t")
+		if !strings.HasPrefix(rest, ",") {
+			return challenge
+		}
+
+		rest = rest[1:]
+	}
+}
+
+// errorInfo describes challenge as ErrorInfo: reason is upper case error param, domain is realm,
+// metadata has scheme and present params except error_description, which is the status message.
+func (c *authChallenge) errorInfo() *pb.ErrorInfo {
+	info := &pb.ErrorInfo{
+		Reason:   AuthChallengeReasonRequired,
+		Domain:   c.params["realm"],
+		Metadata: map[string]string{"scheme": c.scheme},
+	}
+
+	if errorCode := c.params["error"]; errorCode != "" {
+		info.Reason = strings.ToUpper(errorCode)
+	}
+
+	for _, name := range []string{"realm", "scope", "error"} {
+		if value, ok := c.params[name]; ok {
+			info.Metadata[name] = value
+		}
+	}
+
+	return info
+}
+
+// consumeToken returns leading token of RFC 7230 and the rest of s.
+func consumeToken(s string) (string, string) {
+	i := 0
+	for i < len(s) && isTokenChar(s[i]) {
+		i++
+	}
+
+	return s[:i], s[i:]
+}
+
+// consumeQuotedString returns unescaped value of leading quoted-string of RFC 7230 and the rest of s,
+// unterminated string takes the whole s.
+func consumeQuotedString(s string) (string, string) {
+	var b strings.Builder
+
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return b.String(), s[i+1:]
+		case '\\':
+			if i+1 < len(s) {
+				i++
+			}
+		}
+
+		b.WriteByte(s[i])
+	}
+
+	return b.String(), ""
+}
+
+func isTokenChar(c byte) bool {
+	return c > ' ' && c < 0x7f && !strings.ContainsRune(`"(),/:;<=>?@[\]{}`, rune(c))
+}
+
+// markAuthChallenge parses challenge of 401 response, must be called before headers are sent,
+// error_description becomes status message unless it is taken from body.
+func (h *http2grpcModifier) markAuthChallenge() {
+	value := h.responseWriter.Header().Get(WWWAuthenticateHeaderName)
+	if value == "" {
+		return
+	}
+
+	h.authChallenge = parseAuthChallenge(value)
+	if h.authChallenge == nil {
+		LoggerDEBUG.Printf("WriteHeader() invalid %s header: %q", WWWAuthenticateHeaderName, value)
+		return
+	}
+
+	if description := h.authChallenge.params["error_description"]; description != "" {
+		h.responseWriter.Header().Set(GrpcMessageHeaderName, grpc.EncodeMessage(description))
+	}
+}
+
+// finishAuthChallenge adds challenge of 401 response to status as ErrorInfo detail and www-authenticate trailer.
+func (h *http2grpcModifier) finishAuthChallenge() {
+	if h.authChallenge == nil {
+		return
+	}
+
+	header := h.responseWriter.Header()
+	grpc.SetTrailer(header, strings.ToLower(WWWAuthenticateHeaderName), h.authChallenge.raw)
+
+	status, err := grpc.FromHeader(header)
+	if err != nil {
+		LoggerDEBUG.Printf("finish() auth challenge not added: %s", err)
+		return
+	}
+
+	detail := pb.NewAny(h.authChallenge.errorInfo())
+	status.Details = append(status.Details, grpc.Any{TypeURL: detail.TypeURL, Value: detail.Value})
+	status.SetTrailers(header)
+}
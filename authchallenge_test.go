@@ -0,0 +1,186 @@
+package http2grpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/v-electrolux/http2grpc"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"github.com/v-electrolux/http2grpc/internal/pb"
+)
+
+type TestAuthChallengeData struct {
+	cfgBodyAsStatusMessage bool
+
+	backendStatusCode      int
+	backendWWWAuthenticate string
+	backendBody            string
+
+	expGrpcResStatusCode int
+	expGrpcResStatusMsg  string
+	expErrorInfo         *pb.ErrorInfo
+}
+
+func TestAuthChallengeBearerInvalidToken(t *testing.T) {
+	data := TestAuthChallengeData{
+		backendStatusCode: http.StatusUnauthorized,
+		backendWWWAuthenticate: `Bearer realm="example", error="invalid_token", ` +
+			`error_description="The access token expired", scope="read write"`,
+
+		expGrpcResStatusCode: grpc.UNAUTHENTICATED,
+		expGrpcResStatusMsg:  "The access token expired",
+		expErrorInfo: &pb.ErrorInfo{
+			Reason: "INVALID_TOKEN",
+			Domain: "example",
+			Metadata: map[string]string{
+				"scheme": "Bearer", "realm": "example", "error": "invalid_token", "scope": "read write",
+			},
+		},
+	}
+	testAuthChallengeRequest(t, data)
+}
+
+func TestAuthChallengeWithoutError(t *testing.T) {
+	data := TestAuthChallengeData{
+		backendStatusCode:      http.StatusUnauthorized,
+		backendWWWAuthenticate: `Basic realm=admin, Bearer error="invalid_request"`,
+
+		expGrpcResStatusCode: grpc.UNAUTHENTICATED,
+		expGrpcResStatusMsg:  "",
+		expErrorInfo: &pb.ErrorInfo{
+			Reason:   "AUTHENTICATION_REQUIRED",
+			Domain:   "admin",
+			Metadata: map[string]string{"scheme": "Basic", "realm": "admin"},
+		},
+	}
+	testAuthChallengeRequest(t, data)
+}
+
+func TestAuthChallengeEscapedDescription(t *testing.T) {
+	data := TestAuthChallengeData{
+		backendStatusCode:      http.StatusUnauthorized,
+		backendWWWAuthenticate: `Bearer error = invalid_token ,error_description="say \"hi\", then retry"`,
+
+		expGrpcResStatusCode: grpc.UNAUTHENTICATED,
+		expGrpcResStatusMsg:  `say "hi", then retry`,
+		expErrorInfo: &pb.ErrorInfo{
+			Reason:   "INVALID_TOKEN",
+			Metadata: map[string]string{"scheme": "Bearer", "error": "invalid_token"},
+		},
+	}
+	testAuthChallengeRequest(t, data)
+}
+
+func TestAuthChallengeBodyAsStatusMessage(t *testing.T) {
+	data := TestAuthChallengeData{
+		cfgBodyAsStatusMessage: true,
+
+		backendStatusCode:      http.StatusUnauthorized,
+		backendWWWAuthenticate: `Bearer error="invalid_token", error_description="expired"`,
+		backendBody:            "token expired at noon",
+
+		expGrpcResStatusCode: grpc.UNAUTHENTICATED,
+		expGrpcResStatusMsg:  "token expired at noon",
+		expErrorInfo: &pb.ErrorInfo{
+			Reason:   "INVALID_TOKEN",
+			Metadata: map[string]string{"scheme": "Bearer", "error": "invalid_token"},
+		},
+	}
+	testAuthChallengeRequest(t, data)
+}
+
+func TestAuthChallengeIgnoredForForbidden(t *testing.T) {
+	data := TestAuthChallengeData{
+		backendStatusCode:      http.StatusForbidden,
+		backendWWWAuthenticate: `Bearer error="insufficient_scope", error_description="scope required"`,
+
+		expGrpcResStatusCode: grpc.PERMISSION_DENIED,
+		expGrpcResStatusMsg:  "",
+		expErrorInfo:         nil,
+	}
+	testAuthChallengeRequest(t, data)
+}
+
+func TestAuthChallengeMissing(t *testing.T) {
+	data := TestAuthChallengeData{
+		backendStatusCode: http.StatusUnauthorized,
+
+		expGrpcResStatusCode: grpc.UNAUTHENTICATED,
+		expGrpcResStatusMsg:  "",
+		expErrorInfo:         nil,
+	}
+	testAuthChallengeRequest(t, data)
+}
+
+func testAuthChallengeRequest(t *testing.T, data TestAuthChallengeData) {
+	t.Helper()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.BodyAsStatusMessage = data.cfgBodyAsStatusMessage
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if data.backendWWWAuthenticate != "" {
+			rw.Header().Set("WWW-Authenticate", data.backendWWWAuthenticate)
+		}
+
+		rw.WriteHeader(data.backendStatusCode)
+		rw.Write([]byte(data.backendBody))
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost/pkg.v1.Svc/Get", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertTrailer(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+
+	status, err := grpc.FromHeader(resp.Trailer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.Message != data.expGrpcResStatusMsg {
+		t.Errorf("expected grpc-message: `%s`, got: `%s`", data.expGrpcResStatusMsg, status.Message)
+	}
+
+	if data.expErrorInfo == nil {
+		assertTrailer(t, resp, "www-authenticate", "")
+
+		if len(status.Details) != 0 {
+			t.Errorf("expected no details, got %+v", status.Details)
+		}
+
+		return
+	}
+
+	assertTrailer(t, resp, "www-authenticate", data.backendWWWAuthenticate)
+
+	if len(status.Details) != 1 {
+		t.Fatalf("expected one ErrorInfo detail, got %+v", status.Details)
+	}
+
+	detail, err := pb.UnmarshalDetail(&pb.Any{TypeURL: status.Details[0].TypeURL, Value: status.Details[0].Value})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(detail, data.expErrorInfo) {
+		t.Errorf("expected detail %+v, got %+v", data.expErrorInfo, detail)
+	}
+}
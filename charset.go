@@ -0,0 +1,134 @@
+package http2grpc
+
+import (
+	"bytes"
+	"mime"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// singleByteCharsets are characters of bytes from 0x80 to 0xFF of single-byte charsets,
+// the lower half is ASCII. Labels are from WHATWG Encoding standard, so ISO-8859-1 and US-ASCII
+// are decoded as Windows-1252 as browsers do.
+//
+//nolint:gochecknoglobals // static tables of charsets
+var singleByteCharsets = map[string][]rune{
+	"windows-1251": []rune(
+		"ЂЃ‚ѓ„…†‡€‰Љ‹ЊЌЋЏђ‘’“”•–—\ufffd™љ›њќћџ" +
+			"\u00a0ЎўЈ¤Ґ¦§Ё©Є«¬\u00ad®Ї°±Ііґµ¶·ё№є»јЅѕї" +
+			"АБВГДЕЖЗИЙКЛМНОПРСТУФХЦЧШЩЪЫЬЭЮЯ" +
+			"абвгдежзийклмнопрстуфхцчшщъыьэюя",
+	),
+	"windows-1252": []rune(
+		"€\ufffd‚ƒ„…†‡ˆ‰Š‹Œ\ufffdŽ\ufffd\ufffd‘’“”•–—˜™š›œ\ufffdžŸ" +
+			"\u00a0¡¢£¤¥¦§¨©ª«¬\u00ad®¯°±²³´µ¶·¸¹º»¼½¾¿" +
+			"ÀÁÂÃÄÅÆÇÈÉÊËÌÍÎÏÐÑÒÓÔÕÖ×ØÙÚÛÜÝÞß" +
+			"àáâãäåæçèéêëìíîïðñòóôõö÷øùúûüýþÿ",
+	),
+	"koi8-r": []rune(
+		"─│┌┐└┘├┤┬┴┼▀▄█▌▐░▒▓⌠■∙√≈≤≥\u00a0⌡°²·÷" +
+			"═║╒ё╓╔╕╖╗╘╙╚╛╜╝╞╟╠╡Ё╢╣╤╥╦╧╨╩╪╫╬©" +
+			"юабцдефгхийклмнопярстужвьызшэщчъ" +
+			"ЮАБЦДЕФГХИЙКЛМНОПЯРСТУЖВЬЫЗШЭЩЧЪ",
+	),
+}
+
+// charsetAliases are other labels of supported charsets.
+//
+//nolint:gochecknoglobals // static map
+var charsetAliases = map[string]string{
+	"cp1251":            "windows-1251",
+	"x-cp1251":          "windows-1251",
+	"cp1252":            "windows-1252",
+	"x-cp1252":          "windows-1252",
+	"iso-8859-1":        "windows-1252",
+	"iso8859-1":         "windows-1252",
+	"iso_8859-1":        "windows-1252",
+	"latin1":            "windows-1252",
+	"l1":                "windows-1252",
+	"us-ascii":          "windows-1252",
+	"ascii":             "windows-1252",
+	"koi8":              "koi8-r",
+	"koi":               "koi8-r",
+	"csucs2":            "utf-16le",
+	"ucs-2":             "utf-16le",
+	"unicodefeff":       "utf-16le",
+	"utf-16":            "utf-16le",
+	"unicodefffe":       "utf-16be",
+	"utf8":              "utf-8",
+	"unicode-1-1-utf-8": "utf-8",
+}
+
+// decodeBody converts text body to valid UTF-8 by charset of Content-Type header value,
+// byte order mark takes precedence over the charset, invalid sequences are replaced with U+FFFD.
+func decodeBody(body []byte, contentType string) string {
+	charset := "utf-8"
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["charset"] != "" {
+		charset = strings.ToLower(strings.TrimSpace(params["charset"]))
+	}
+
+	if alias, ok := charsetAliases[charset]; ok {
+		charset = alias
+	}
+
+	switch {
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		return strings.ToValidUTF8(string(body[3:]), string(utf8.RuneError))
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		return decodeUTF16(body[2:], false)
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		return decodeUTF16(body[2:], true)
+	case charset == "utf-16le":
+		return decodeUTF16(body, false)
+	case charset == "utf-16be":
+		return decodeUTF16(body, true)
+	}
+
+	if table, ok := singleByteCharsets[charset]; ok {
+		return decodeSingleByte(body, table)
+	}
+
+	if charset != "utf-8" {
+		LoggerDEBUG.Printf("unsupported charset %q of body, decoded as UTF-8", charset)
+	}
+
+	return strings.ToValidUTF8(string(body), string(utf8.RuneError))
+}
+
+// decodeUTF16 decodes UTF-16 without byte order mark, unpaired surrogates and odd byte are replaced with U+FFFD.
+func decodeUTF16(body []byte, bigEndian bool) string {
+	units := make([]uint16, 0, len(body)/2)
+
+	for i := 0; i+1 < len(body); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(body[i])<<8|uint16(body[i+1]))
+		} else {
+			units = append(units, uint16(body[i+1])<<8|uint16(body[i]))
+		}
+	}
+
+	text := string(utf16.Decode(units))
+	if len(body)%2 == 1 {
+		text += string(utf8.RuneError)
+	}
+
+	return text
+}
+
+// decodeSingleByte decodes single-byte charset by table of its upper half.
+func decodeSingleByte(body []byte, table []rune) string {
+	var b strings.Builder
+
+	b.Grow(len(body))
+
+	for _, c := range body {
+		if c < utf8.RuneSelf {
+			b.WriteByte(c)
+		} else {
+			b.WriteRune(table[c-utf8.RuneSelf])
+		}
+	}
+
+	return b.String()
+}
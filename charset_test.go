@@ -0,0 +1,145 @@
+package http2grpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/v-electrolux/http2grpc"
+	"github.com/v-electrolux/http2grpc/grpc"
+)
+
+type TestCharsetData struct {
+	backendContentType string
+	backendBody        []string
+
+	expGrpcResStatusMsg string
+}
+
+func TestCharsetWindows1251(t *testing.T) {
+	data := TestCharsetData{
+		backendContentType: "text/plain; charset=windows-1251",
+		backendBody:        []string{"\xc4\xee\xf1\xf2\xf3\xef \xe7\xe0\xef\xf0\xe5\xf9\xb8\xed"},
+
+		expGrpcResStatusMsg: "Доступ запрещён",
+	}
+	testCharsetRequest(t, data)
+}
+
+func TestCharsetISO88591(t *testing.T) {
+	data := TestCharsetData{
+		backendContentType: `text/plain; charset="ISO-8859-1"`,
+		backendBody:        []string{"Zugriff verweigert: M\xfcller"},
+
+		expGrpcResStatusMsg: "Zugriff verweigert: Müller",
+	}
+	testCharsetRequest(t, data)
+}
+
+func TestCharsetKOI8RAlias(t *testing.T) {
+	data := TestCharsetData{
+		backendContentType: "text/plain;charset=koi8",
+		backendBody:        []string{"\xef\xdb\xc9\xc2\xcb\xc1"},
+
+		expGrpcResStatusMsg: "Ошибка",
+	}
+	testCharsetRequest(t, data)
+}
+
+func TestCharsetUTF16ByteOrderMarkSplitInChunks(t *testing.T) {
+	data := TestCharsetData{
+		backendContentType: "text/plain",
+		backendBody:        []string{"\xff\xfe\x1a\x04;", "\x04N\x04G\x04:\x00 \x00\x13'"},
+
+		expGrpcResStatusMsg: "Ключ: ✓",
+	}
+	testCharsetRequest(t, data)
+}
+
+func TestCharsetUTF16BigEndianWithOddByte(t *testing.T) {
+	data := TestCharsetData{
+		backendContentType: "text/plain; charset=UTF-16BE",
+		backendBody:        []string{"\x04\x1d\x045\x04B\x00  \xac\x00"},
+
+		expGrpcResStatusMsg: "Нет €�",
+	}
+	testCharsetRequest(t, data)
+}
+
+func TestCharsetHTMLWindows1251(t *testing.T) {
+	data := TestCharsetData{
+		backendContentType: "text/html; charset=cp1251",
+		backendBody:        []string{"<title>\xce\xf8\xe8\xe1\xea\xe0</title>"},
+
+		expGrpcResStatusMsg: "Ошибка",
+	}
+	testCharsetRequest(t, data)
+}
+
+func TestCharsetInvalidUTF8Replaced(t *testing.T) {
+	data := TestCharsetData{
+		backendContentType: "text/plain; charset=utf-8",
+		backendBody:        []string{"bad \xff\xfe text \xd0"},
+
+		expGrpcResStatusMsg: "bad � text �",
+	}
+	testCharsetRequest(t, data)
+}
+
+func TestCharsetUnsupportedDecodedAsUTF8(t *testing.T) {
+	data := TestCharsetData{
+		backendContentType: "text/plain; charset=shift_jis",
+		backendBody:        []string{"plain \x82"},
+
+		expGrpcResStatusMsg: "plain �",
+	}
+	testCharsetRequest(t, data)
+}
+
+func TestCharsetLongBodyTruncated(t *testing.T) {
+	data := TestCharsetData{
+		backendContentType: "text/plain; charset=windows-1251",
+		backendBody:        []string{strings.Repeat("\xc4\xe0 ", 20*1024), strings.Repeat("\xcd\xe5\xf2 ", 20*1024)},
+
+		expGrpcResStatusMsg: strings.Repeat("Да ", 85) + "Д...",
+	}
+	testCharsetRequest(t, data)
+}
+
+func testCharsetRequest(t *testing.T, data TestCharsetData) {
+	t.Helper()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.BodyAsStatusMessage = true
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", data.backendContentType)
+		rw.WriteHeader(http.StatusForbidden)
+
+		for _, chunk := range data.backendBody {
+			rw.Write([]byte(chunk))
+		}
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost/pkg.v1.Svc/Get", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertTrailer(t, resp, "grpc-status", "7")
+	assertTrailer(t, resp, "grpc-message", grpc.EncodeMessage(data.expGrpcResStatusMsg))
+}
@@ -0,0 +1,104 @@
+//go:build go1.24
+
+package main
+
+import (
+	"fmt"
+	"github.com/v-electrolux/http2grpc"
+	"github.com/v-electrolux/http2grpc/internal/config"
+	"net/url"
+	"time"
+)
+
+// Config describes standalone proxy, middleware section has the same schema as traefik plugin configuration.
+type Config struct {
+	// Listen is address of cleartext listener serving HTTP/1.1 and h2c, disabled if empty
+	Listen string `yaml:"listen"`
+	// TLS describes listener serving HTTP/1.1 and HTTP/2 over TLS
+	TLS TLSConfig `yaml:"tls"`
+	// Backend describes gRPC service requests are proxied to
+	Backend BackendConfig `yaml:"backend"`
+	// ShutdownTimeout is max time to complete in-flight calls on SIGINT or SIGTERM as go duration string
+	ShutdownTimeout string `yaml:"shutdownTimeout"`
+	// ForwardAuthChain are auth services asked in order before middleware, the first denial answers the call
+	ForwardAuthChain []http2grpc.ForwardAuthConfig `yaml:"forwardAuthChain"`
+	// Middleware is configuration of middleware wrapping the proxy
+	Middleware *http2grpc.Config `yaml:"middleware"`
+}
+
+// TLSConfig describes TLS listener, it is disabled if Listen is empty.
+type TLSConfig struct {
+	Listen   string `yaml:"listen"`
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+// BackendConfig describes gRPC service requests are proxied to.
+type BackendConfig struct {
+	// URL of backend, http scheme is dialed with h2c, https one with HTTP/2 over TLS
+	URL string `yaml:"url"`
+	// InsecureSkipVerify disables verification of backend certificate
+	InsecureSkipVerify bool `yaml:"insecureSkipVerify"`
+}
+
+func createConfig() *Config {
+	return &Config{
+		Listen:          ":8080",
+		ShutdownTimeout: "30s",
+		Middleware:      http2grpc.CreateConfig(),
+	}
+}
+
+// loadConfig reads YAML or TOML configuration file, absent keys keep defaults.
+func loadConfig(path string) (*Config, error) {
+	cfg := createConfig()
+
+	if err := config.Load(path, cfg); err != nil {
+		return nil, err
+	}
+
+	// auth services of the chain are decoded into zero values, so they get defaults here
+	defaultForwardAuth := http2grpc.CreateConfig().ForwardAuth
+	for i := range cfg.ForwardAuthChain {
+		if cfg.ForwardAuthChain[i].Timeout == "" {
+			cfg.ForwardAuthChain[i].Timeout = defaultForwardAuth.Timeout
+		}
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) validate() error {
+	if c.Listen == "" && c.TLS.Listen == "" {
+		return fmt.Errorf("config: neither listen nor tls.listen is set")
+	}
+
+	if c.TLS.Listen != "" && (c.TLS.CertFile == "" || c.TLS.KeyFile == "") {
+		return fmt.Errorf("config: tls.certFile and tls.keyFile are required by tls.listen")
+	}
+
+	backend, err := url.Parse(c.Backend.URL)
+	if err != nil {
+		return fmt.Errorf("config: backend.url: %w", err)
+	}
+
+	if (backend.Scheme != "http" && backend.Scheme != "https") || backend.Host == "" {
+		return fmt.Errorf("config: backend.url must be absolute http or https url, got %q", c.Backend.URL)
+	}
+
+	for i, auth := range c.ForwardAuthChain {
+		if auth.Address == "" {
+			return fmt.Errorf("config: forwardAuthChain[%d].address is empty", i)
+		}
+	}
+
+	if _, err = time.ParseDuration(c.ShutdownTimeout); err != nil {
+		return fmt.Errorf("config: shutdownTimeout: %w", err)
+	}
+
+	return nil
+}
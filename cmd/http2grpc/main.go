@@ -0,0 +1,105 @@
+//go:build go1.24
+
+// Command http2grpc is standalone reverse proxy to gRPC backend with http2grpc middleware,
+// so the conversion runs as a sidecar without traefik.
+//
+//	http2grpc -config http2grpc.yaml
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/v-electrolux/http2grpc"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const readHeaderTimeout = 10 * time.Second
+
+func main() {
+	configPath := flag.String("config", "http2grpc.yaml", "path to YAML or TOML configuration file")
+	flag.Parse()
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("ERROR: http2grpc: %s", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err = run(ctx, cfg); err != nil {
+		stop()
+		log.Fatalf("ERROR: http2grpc: %s", err) //nolint:gocritic // stop is called above
+	}
+}
+
+// run serves until ctx is done, then waits for in-flight calls up to shutdown timeout.
+func run(ctx context.Context, cfg *Config) error {
+	handler, err := newHandler(cfg)
+	if err != nil {
+		return err
+	}
+
+	shutdownTimeout, err := time.ParseDuration(cfg.ShutdownTimeout)
+	if err != nil {
+		return fmt.Errorf("shutdownTimeout: %w", err)
+	}
+
+	var servers []*http.Server
+
+	errs := make(chan error, 2)
+
+	if cfg.Listen != "" {
+		protocols := new(http.Protocols)
+		protocols.SetHTTP1(true)
+		protocols.SetUnencryptedHTTP2(true)
+
+		server := &http.Server{
+			Addr: cfg.Listen, Handler: handler, Protocols: protocols, ReadHeaderTimeout: readHeaderTimeout,
+		}
+		servers = append(servers, server)
+
+		go func() {
+			http2grpc.LoggerINFO.Printf("listening h2c on %s", cfg.Listen)
+			errs <- server.ListenAndServe()
+		}()
+	}
+
+	if cfg.TLS.Listen != "" {
+		server := &http.Server{Addr: cfg.TLS.Listen, Handler: handler, ReadHeaderTimeout: readHeaderTimeout}
+		servers = append(servers, server)
+
+		go func() {
+			http2grpc.LoggerINFO.Printf("listening TLS on %s", cfg.TLS.Listen)
+			errs <- server.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		}()
+	}
+
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		http2grpc.LoggerINFO.Printf("shutting down, waiting for in-flight calls up to %s", shutdownTimeout)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	for _, server := range servers {
+		if shutdownErr := server.Shutdown(shutdownCtx); shutdownErr != nil && err == nil {
+			err = shutdownErr
+		}
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
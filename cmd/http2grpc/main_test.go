@@ -0,0 +1,225 @@
+//go:build go1.24
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/v-electrolux/http2grpc"
+)
+
+type TestProxyData struct {
+	config  string
+	backend http.HandlerFunc
+	auth    []http.HandlerFunc
+
+	expBody       string
+	expGrpcStatus string
+	expGrpcMsg    string
+}
+
+func TestProxyPassesGrpcResponse(t *testing.T) {
+	data := TestProxyData{
+		config: "middleware:\n  logLevel: debug\n",
+		backend: func(rw http.ResponseWriter, req *http.Request) {
+			if req.ProtoMajor != 2 {
+				t.Errorf("expected h2c backend request, got %s", req.Proto)
+			}
+
+			rw.Header().Set("Content-Type", "application/grpc")
+			rw.Header().Set("Trailer", "grpc-status")
+			_, _ = rw.Write([]byte{0, 0, 0, 0, 2, 'o', 'k'})
+			rw.Header().Set("grpc-status", "0")
+		},
+
+		expBody:       "\x00\x00\x00\x00\x02ok",
+		expGrpcStatus: "0",
+	}
+	testProxy(t, data)
+}
+
+func TestProxyBackendUnavailable(t *testing.T) {
+	data := TestProxyData{
+		config: "middleware:\n  proxyErrors:\n    enabled: true\n",
+
+		expBody:       "\x00\x00\x00\x00\x00",
+		expGrpcStatus: "14",
+		expGrpcMsg:    "proxy: 502 Bad Gateway",
+	}
+	testProxy(t, data)
+}
+
+func TestProxyForwardAuthChainDenies(t *testing.T) {
+	data := TestProxyData{
+		config: "middleware:\n  bodyAsStatusMessage: true\n",
+		backend: func(rw http.ResponseWriter, req *http.Request) {
+			t.Error("backend must not be called")
+		},
+		auth: []http.HandlerFunc{
+			func(rw http.ResponseWriter, req *http.Request) {},
+			func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusForbidden)
+				_, _ = rw.Write([]byte("second says no"))
+			},
+		},
+
+		expBody:       "\x00\x00\x00\x00\x00",
+		expGrpcStatus: "7",
+		expGrpcMsg:    "second says no",
+	}
+	testProxy(t, data)
+}
+
+func TestLoadTOMLConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "http2grpc.toml")
+	doc := `listen = ":9090"
+shutdownTimeout = "5s"
+
+[backend]
+url = "http://127.0.0.1:5000"
+
+[[forwardAuthChain]]
+address = "http://auth:8080/check"
+
+[middleware]
+logLevel = "debug"
+
+[[middleware.rateLimit.limits]]
+methods = ["/svc.Svc/*"]
+average = 10
+`
+
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Listen != ":9090" || cfg.Backend.URL != "http://127.0.0.1:5000" || cfg.ShutdownTimeout != "5s" {
+		t.Errorf("unexpected server config %+v", cfg)
+	}
+
+	if len(cfg.ForwardAuthChain) != 1 || cfg.ForwardAuthChain[0].Timeout != "30s" {
+		t.Errorf("unexpected forward auth chain %+v", cfg.ForwardAuthChain)
+	}
+
+	if cfg.Middleware.LogLevel != "debug" || cfg.Middleware.RateLimit.Message != "rate limit exceeded" ||
+		len(cfg.Middleware.RateLimit.Limits) != 1 || cfg.Middleware.RateLimit.Limits[0].Average != 10 {
+		t.Errorf("unexpected middleware config %+v", cfg.Middleware)
+	}
+}
+
+func TestLoadConfigWithoutBackend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "http2grpc.yaml")
+	if err := os.WriteFile(path, []byte("listen: :9090\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := loadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "backend.url") {
+		t.Errorf("expected backend.url error, got %v", err)
+	}
+}
+
+func testProxy(t *testing.T, data TestProxyData) {
+	t.Helper()
+
+	doc := data.config + "backend:\n  url: " + startH2CServer(t, data.backend) + "\n"
+
+	if len(data.auth) > 0 {
+		doc += "forwardAuthChain:\n"
+		for _, auth := range data.auth {
+			server := httptest.NewServer(auth)
+			t.Cleanup(server.Close)
+
+			doc += "  - address: " + server.URL + "\n"
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "http2grpc.yaml")
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler, err := newHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxyURL := startH2CServer(t, handler.ServeHTTP)
+
+	req, err := http.NewRequest(http.MethodPost, proxyURL+"/svc.Svc/Call", strings.NewReader("\x00\x00\x00\x00\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/grpc")
+
+	res, err := newH2CClient().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != data.expBody {
+		t.Errorf("expected body %q, got %q", data.expBody, body)
+	}
+
+	status, message := res.Trailer.Get(http2grpc.GrpcStatusHeaderName), res.Trailer.Get(http2grpc.GrpcMessageHeaderName)
+	if status == "" {
+		// trailers-only response
+		status, message = res.Header.Get(http2grpc.GrpcStatusHeaderName), res.Header.Get(http2grpc.GrpcMessageHeaderName)
+	}
+
+	if status != data.expGrpcStatus {
+		t.Errorf("expected grpc-status %q, got %q", data.expGrpcStatus, status)
+	}
+
+	if message != data.expGrpcMsg {
+		t.Errorf("expected grpc-message %q, got %q", data.expGrpcMsg, message)
+	}
+}
+
+// startH2CServer returns url of cleartext HTTP/2 server, url of closed port if handler is nil.
+func startH2CServer(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+
+	if handler == nil {
+		server.Close()
+	} else {
+		t.Cleanup(server.Close)
+	}
+
+	return server.URL
+}
+
+func newH2CClient() *http.Client {
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+
+	return &http.Client{Transport: &http.Transport{Protocols: protocols}}
+}
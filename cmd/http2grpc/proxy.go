@@ -0,0 +1,93 @@
+//go:build go1.24
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"github.com/v-electrolux/http2grpc"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// newHandler returns reverse proxy to backend wrapped by middleware and by forward auth chain around it.
+func newHandler(cfg *Config) (http.Handler, error) {
+	backend, err := url.Parse(cfg.Backend.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(backend)
+			r.Out.Host = r.In.Host
+			r.SetXForwarded()
+		},
+		Transport: newBackendTransport(cfg.Backend),
+		// gRPC streams must not be buffered
+		FlushInterval: -1,
+		ErrorHandler:  proxyErrorHandler(cfg.Middleware.ProxyErrors.MarkerHeader),
+		ErrorLog:      http2grpc.LoggerINFO,
+	}
+
+	var handler http.Handler
+
+	if handler, err = http2grpc.Wrap(proxy, http2grpc.WithConfig(cfg.Middleware)); err != nil {
+		return nil, err
+	}
+
+	// the first auth service of the chain is the outermost one
+	for i := len(cfg.ForwardAuthChain) - 1; i >= 0; i-- {
+		authConfig := http2grpc.CreateConfig()
+		authConfig.LogLevel = cfg.Middleware.LogLevel
+		authConfig.BodyAsStatusMessage = cfg.Middleware.BodyAsStatusMessage
+		authConfig.ForwardAuth = cfg.ForwardAuthChain[i]
+
+		handler, err = http2grpc.Wrap(handler, http2grpc.WithConfig(authConfig), http2grpc.WithName("http2grpc-auth"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return handler, nil
+}
+
+// newBackendTransport returns transport speaking HTTP/2 only, h2c for http scheme of backend url.
+func newBackendTransport(cfg BackendConfig) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert // default is *http.Transport
+
+	protocols := new(http.Protocols)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	transport.Protocols = protocols
+
+	if cfg.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec // configured by user
+	}
+
+	return transport
+}
+
+// proxyErrorHandler answers like traefik does when backend is not reachable, so middleware recognizes proxy errors.
+func proxyErrorHandler(markerHeader string) func(http.ResponseWriter, *http.Request, error) {
+	return func(rw http.ResponseWriter, req *http.Request, err error) {
+		statusCode := http.StatusBadGateway
+
+		var netErr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+			statusCode = http.StatusGatewayTimeout
+		}
+
+		http2grpc.LoggerINFO.Printf("backend call %s failed: %s", req.URL.Path, err)
+
+		if markerHeader != "" {
+			rw.Header().Set(markerHeader, "true")
+		}
+
+		rw.WriteHeader(statusCode)
+		_, _ = rw.Write([]byte(http.StatusText(statusCode)))
+	}
+}
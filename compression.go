@@ -0,0 +1,286 @@
+package http2grpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	GrpcAcceptEncodingHeaderName = "grpc-accept-encoding"
+
+	grpcEncodingIdentity = "identity"
+	grpcEncodingGzip     = "gzip"
+	grpcEncodingDeflate  = "deflate"
+)
+
+// CompressionConfig describes recompression of gRPC response messages,
+// so clients receive only encodings listed in their grpc-accept-encoding.
+type CompressionConfig struct {
+	// Enabled turns on recompression: gzip and deflate messages are decompressed for clients not accepting them,
+	// identity messages are compressed with gzip for clients accepting it
+	Enabled bool `yaml:"enabled"`
+	// MinSize is min length of identity message compressed, smaller messages are sent as is
+	MinSize int `yaml:"minSize"`
+	// MaxDecompressedSize is max length of decompressed message, larger one ends the call with RESOURCE_EXHAUSTED
+	MaxDecompressedSize int `yaml:"maxDecompressedSize"`
+	// MaxBufferedSize is max length of message buffered to be rewritten, larger compressed message
+	// ends the call with RESOURCE_EXHAUSTED, larger identity message is sent not compressed
+	MaxBufferedSize int `yaml:"maxBufferedSize"`
+}
+
+func validateCompression(config *CompressionConfig) error {
+	if !config.Enabled {
+		return nil
+	}
+
+	if config.MinSize < 0 {
+		return fmt.Errorf("ERROR: http2grpc: negative compression.minSize %d", config.MinSize)
+	}
+
+	if config.MaxDecompressedSize <= 0 {
+		return fmt.Errorf("ERROR: http2grpc: compression.maxDecompressedSize must be positive, got %d",
+			config.MaxDecompressedSize)
+	}
+
+	if config.MaxBufferedSize <= 0 {
+		return fmt.Errorf("ERROR: http2grpc: compression.maxBufferedSize must be positive, got %d", config.MaxBufferedSize)
+	}
+
+	return nil
+}
+
+// grpcRecompressor rewrites complete length-prefixed messages of response from one encoding to another.
+type grpcRecompressor struct {
+	config *CompressionConfig
+	// decompress is backend encoding to decompress from, empty if messages are compressed instead
+	decompress string
+	// pending accumulates prefix and bytes of the current message until it is complete
+	pending []byte
+	// passthrough is count of bytes not received yet of the current message, which is sent as is
+	passthrough uint32
+}
+
+// newGrpcRecompressor returns nil if messages of backend encoding are acceptable by client as is,
+// otherwise it rewrites grpc-encoding response header, so it must be called before headers are sent.
+func newGrpcRecompressor(config *CompressionConfig, acceptEncoding string, header http.Header) *grpcRecompressor {
+	encoding := strings.ToLower(strings.TrimSpace(header.Get(GrpcEncodingHeaderName)))
+	accepted := parseAcceptEncoding(acceptEncoding)
+
+	switch {
+	case encoding == grpcEncodingGzip || encoding == grpcEncodingDeflate:
+		if accepted[encoding] {
+			return nil
+		}
+
+		LoggerDEBUG.Printf("compression: client does not accept %s, decompressing", encoding)
+		header.Del(GrpcEncodingHeaderName)
+
+		return &grpcRecompressor{config: config, decompress: encoding}
+	case encoding == "" || encoding == grpcEncodingIdentity:
+		if !accepted[grpcEncodingGzip] {
+			return nil
+		}
+
+		LoggerDEBUG.Printf("compression: client accepts gzip, compressing")
+		header.Set(GrpcEncodingHeaderName, grpcEncodingGzip)
+
+		return &grpcRecompressor{config: config}
+	default:
+		// encodings unknown to middleware are passed as is
+		return nil
+	}
+}
+
+// transform consumes buf and returns rewritten messages completed by it,
+// messages not rewritten are streamed as is without buffering.
+func (r *grpcRecompressor) transform(buf []byte) ([]byte, *grpcStatusError) {
+	var out []byte
+
+	for {
+		if r.passthrough > 0 {
+			if len(buf) == 0 {
+				break
+			}
+
+			count := uint32(len(buf))
+			if count > r.passthrough {
+				count = r.passthrough
+			}
+
+			out = append(out, buf[:count]...)
+			buf = buf[count:]
+			r.passthrough -= count
+
+			continue
+		}
+
+		if len(r.pending) < GrpcFramePrefixLength {
+			if len(buf) == 0 {
+				break
+			}
+
+			count := GrpcFramePrefixLength - len(r.pending)
+			if count > len(buf) {
+				count = len(buf)
+			}
+
+			r.pending = append(r.pending, buf[:count]...)
+			buf = buf[count:]
+
+			if len(r.pending) < GrpcFramePrefixLength {
+				break
+			}
+
+			buffered, err := r.buffered(r.pending[0], binary.BigEndian.Uint32(r.pending[1:GrpcFramePrefixLength]))
+			if err != nil {
+				return out, err
+			}
+
+			if !buffered {
+				out = append(out, r.pending...)
+				r.passthrough = binary.BigEndian.Uint32(r.pending[1:GrpcFramePrefixLength])
+				r.pending = nil
+
+				continue
+			}
+		}
+
+		length := binary.BigEndian.Uint32(r.pending[1:GrpcFramePrefixLength])
+
+		// compared as uint64, message length does not fit int on 32-bit platforms
+		missing := uint64(GrpcFramePrefixLength) + uint64(length) - uint64(len(r.pending))
+		if uint64(len(buf)) < missing {
+			r.pending = append(r.pending, buf...)
+			break
+		}
+
+		r.pending = append(r.pending, buf[:missing]...)
+		buf = buf[missing:]
+
+		var err *grpcStatusError
+
+		if out, err = r.rewrite(out, r.pending[GrpcFramePrefixLength:]); err != nil {
+			return out, err
+		}
+
+		r.pending = nil
+	}
+
+	return out, nil
+}
+
+// incomplete is whether the stream ended in the middle of a message.
+func (r *grpcRecompressor) incomplete() bool {
+	return len(r.pending) > 0 || r.passthrough > 0
+}
+
+// buffered is whether message with flag and length is rewritten, so it must be buffered until it is complete.
+func (r *grpcRecompressor) buffered(flag byte, length uint32) (bool, *grpcStatusError) {
+	switch {
+	case r.decompress != "" && flag == grpcFrameCompressed:
+		if uint64(length) > uint64(r.config.MaxBufferedSize) {
+			return false, &grpcStatusError{
+				code:    grpc.RESOURCE_EXHAUSTED,
+				message: fmt.Sprintf("grpc: received compressed message larger than max %d", r.config.MaxBufferedSize),
+			}
+		}
+
+		return true, nil
+	case r.decompress == "" && flag == grpcFrameNotCompressed:
+		// too large messages are sent not compressed instead of buffering them
+		return uint64(length) >= uint64(r.config.MinSize) && uint64(length) <= uint64(r.config.MaxBufferedSize), nil
+	default:
+		// messages may be sent not compressed regardless of grpc-encoding
+		return false, nil
+	}
+}
+
+func (r *grpcRecompressor) rewrite(out []byte, message []byte) ([]byte, *grpcStatusError) {
+	if r.decompress != "" {
+		decompressed, err := decompressMessage(r.decompress, message, r.config.MaxDecompressedSize)
+		if err != nil {
+			return out, err
+		}
+
+		return appendGrpcFrame(out, decompressed), nil
+	}
+
+	compressed, err := gzipMessage(message)
+	if err != nil {
+		return out, &grpcStatusError{code: grpc.INTERNAL, message: fmt.Sprintf("grpc: failed to compress message: %s", err)}
+	}
+
+	var prefix [GrpcFramePrefixLength]byte
+
+	prefix[0] = grpcFrameCompressed
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(compressed)))
+
+	return append(append(out, prefix[:]...), compressed...), nil
+}
+
+func decompressMessage(encoding string, message []byte, maxSize int) ([]byte, *grpcStatusError) {
+	var reader io.ReadCloser
+
+	var err error
+
+	if encoding == grpcEncodingGzip {
+		reader, err = gzip.NewReader(bytes.NewReader(message))
+	} else {
+		reader, err = zlib.NewReader(bytes.NewReader(message))
+	}
+
+	if err != nil {
+		return nil, &grpcStatusError{code: grpc.INTERNAL, message: fmt.Sprintf("grpc: failed to decompress message: %s", err)}
+	}
+	defer reader.Close()
+
+	decompressed, err := ioutil.ReadAll(io.LimitReader(reader, int64(maxSize)+1))
+	if err != nil {
+		return nil, &grpcStatusError{code: grpc.INTERNAL, message: fmt.Sprintf("grpc: failed to decompress message: %s", err)}
+	}
+
+	if len(decompressed) > maxSize {
+		return nil, &grpcStatusError{
+			code:    grpc.RESOURCE_EXHAUSTED,
+			message: fmt.Sprintf("grpc: received message after decompression larger than max %d", maxSize),
+		}
+	}
+
+	return decompressed, nil
+}
+
+func gzipMessage(message []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(message); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// parseAcceptEncoding parses comma separated grpc-accept-encoding header.
+func parseAcceptEncoding(value string) map[string]bool {
+	accepted := make(map[string]bool)
+
+	for _, encoding := range strings.Split(value, ",") {
+		if encoding = strings.ToLower(strings.TrimSpace(encoding)); encoding != "" {
+			accepted[encoding] = true
+		}
+	}
+
+	return accepted
+}
@@ -0,0 +1,294 @@
+package http2grpc_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/v-electrolux/http2grpc"
+)
+
+type TestCompressionData struct {
+	cfgMinSize             int
+	cfgMaxDecompressedSize int
+	cfgMaxBufferedSize     int
+
+	reqAcceptEncoding string
+
+	backendGrpcEncoding string
+	backendGrpcBody     []byte
+
+	expGrpcEncoding      string
+	expGrpcResBody       []byte
+	expGzipMessage       []byte
+	expGrpcResStatusCode int
+	expGrpcResStatusMsg  string
+}
+
+func TestCompressionGzipDecompressed(t *testing.T) {
+	data := TestCompressionData{
+		reqAcceptEncoding: "identity",
+
+		backendGrpcEncoding: "gzip",
+		backendGrpcBody:     compressedFrame(t, "gzip", []byte("hello, world")),
+
+		expGrpcEncoding:      "",
+		expGrpcResBody:       frame([]byte("hello, world")),
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testCompressionRequest(t, data)
+}
+
+func TestCompressionDeflateDecompressed(t *testing.T) {
+	data := TestCompressionData{
+		reqAcceptEncoding: "gzip",
+
+		backendGrpcEncoding: "deflate",
+		backendGrpcBody:     append(compressedFrame(t, "deflate", []byte("first")), frame([]byte("second"))...),
+
+		expGrpcEncoding:      "",
+		expGrpcResBody:       append(frame([]byte("first")), frame([]byte("second"))...),
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testCompressionRequest(t, data)
+}
+
+func TestCompressionAcceptedEncodingPassed(t *testing.T) {
+	body := compressedFrame(t, "gzip", []byte("hello, world"))
+	data := TestCompressionData{
+		reqAcceptEncoding: "deflate, gzip",
+
+		backendGrpcEncoding: "gzip",
+		backendGrpcBody:     body,
+
+		expGrpcEncoding:      "gzip",
+		expGrpcResBody:       body,
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testCompressionRequest(t, data)
+}
+
+func TestCompressionIdentityCompressed(t *testing.T) {
+	data := TestCompressionData{
+		cfgMinSize:        8,
+		reqAcceptEncoding: "gzip",
+
+		backendGrpcBody: frame([]byte("hello, world")),
+
+		expGrpcEncoding:      "gzip",
+		expGzipMessage:       []byte("hello, world"),
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testCompressionRequest(t, data)
+}
+
+func TestCompressionSmallMessageNotCompressed(t *testing.T) {
+	data := TestCompressionData{
+		cfgMinSize:        1024,
+		reqAcceptEncoding: "gzip",
+
+		backendGrpcBody: frame([]byte("hello, world")),
+
+		expGrpcEncoding:      "gzip",
+		expGrpcResBody:       frame([]byte("hello, world")),
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testCompressionRequest(t, data)
+}
+
+func TestCompressionDecompressedTooLarge(t *testing.T) {
+	data := TestCompressionData{
+		cfgMaxDecompressedSize: 64,
+		reqAcceptEncoding:      "",
+
+		backendGrpcEncoding: "gzip",
+		backendGrpcBody:     compressedFrame(t, "gzip", bytes.Repeat([]byte("a"), 100)),
+
+		expGrpcEncoding:      "",
+		expGrpcResBody:       []byte{},
+		expGrpcResStatusCode: 8,
+		expGrpcResStatusMsg:  "grpc: received message after decompression larger than max 64",
+	}
+	testCompressionRequest(t, data)
+}
+
+func TestCompressionLargeIdentityMessageCompressed(t *testing.T) {
+	message := bytes.Repeat([]byte("a"), 100)
+	data := TestCompressionData{
+		cfgMinSize:             8,
+		cfgMaxDecompressedSize: 50,
+		reqAcceptEncoding:      "gzip",
+
+		backendGrpcBody: frame(message),
+
+		expGrpcEncoding:      "gzip",
+		expGzipMessage:       message,
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testCompressionRequest(t, data)
+}
+
+func TestCompressionCompressedTooLargeToBuffer(t *testing.T) {
+	data := TestCompressionData{
+		cfgMaxBufferedSize: 16,
+		reqAcceptEncoding:  "",
+
+		backendGrpcEncoding: "gzip",
+		backendGrpcBody:     compressedFrame(t, "gzip", bytes.Repeat([]byte("a"), 100)),
+
+		expGrpcEncoding:      "",
+		expGrpcResBody:       []byte{},
+		expGrpcResStatusCode: 8,
+		expGrpcResStatusMsg:  "grpc: received compressed message larger than max 16",
+	}
+	testCompressionRequest(t, data)
+}
+
+func TestCompressionIdentityTooLargeToBufferNotCompressed(t *testing.T) {
+	message := bytes.Repeat([]byte("a"), 100)
+	data := TestCompressionData{
+		cfgMinSize:         8,
+		cfgMaxBufferedSize: 50,
+		reqAcceptEncoding:  "gzip",
+
+		backendGrpcBody: append(frame(message), frame([]byte("hello, world"))...),
+
+		expGrpcEncoding:      "gzip",
+		expGrpcResBody:       append(frame(message), compressedFrame(t, "gzip", []byte("hello, world"))...),
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testCompressionRequest(t, data)
+}
+
+func testCompressionRequest(t *testing.T, data TestCompressionData) {
+	t.Helper()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.Compression.Enabled = true
+
+	if data.cfgMinSize != 0 {
+		cfg.Compression.MinSize = data.cfgMinSize
+	}
+
+	if data.cfgMaxDecompressedSize != 0 {
+		cfg.Compression.MaxDecompressedSize = data.cfgMaxDecompressedSize
+	}
+
+	if data.cfgMaxBufferedSize != 0 {
+		cfg.Compression.MaxBufferedSize = data.cfgMaxBufferedSize
+	}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/grpc")
+		rw.Header().Set("Trailer", "grpc-status")
+
+		if data.backendGrpcEncoding != "" {
+			rw.Header().Set("grpc-encoding", data.backendGrpcEncoding)
+		}
+
+		rw.WriteHeader(http.StatusOK)
+
+		// split write checks messages are reassembled
+		half := len(data.backendGrpcBody) / 2
+		rw.Write(data.backendGrpcBody[:half])
+		rw.Write(data.backendGrpcBody[half:])
+		rw.Header().Set("grpc-status", "0")
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost/pkg.v1.Service/Get", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.reqAcceptEncoding != "" {
+		req.Header.Set("grpc-accept-encoding", data.reqAcceptEncoding)
+	}
+
+	handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertHeader(t, resp, "grpc-encoding", data.expGrpcEncoding)
+
+	if data.expGzipMessage != nil {
+		assertGzipFrame(t, resp, data.expGzipMessage)
+	} else {
+		assertBody(t, resp, data.expGrpcResBody)
+	}
+
+	assertTrailer(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+	assertTrailer(t, resp, "grpc-message", data.expGrpcResStatusMsg)
+}
+
+func assertGzipFrame(t *testing.T, res *http.Response, expected []byte) {
+	t.Helper()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if len(body) < 5 || body[0] != 0x01 || int(binary.BigEndian.Uint32(body[1:5])) != len(body)-5 {
+		t.Fatalf("expected one compressed message, got body: `%v`", body)
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(body[5:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(message, expected) {
+		t.Errorf("expected decompressed message: `%s`, got: `%s`", expected, message)
+	}
+}
+
+func frame(message []byte) []byte {
+	prefix := []byte{0x00, 0x00, 0x00, 0x00, 0x00}
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(message)))
+
+	return append(prefix, message...)
+}
+
+func compressedFrame(t *testing.T, encoding string, message []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	if encoding == "gzip" {
+		writer := gzip.NewWriter(&buf)
+		writer.Write(message)
+		writer.Close()
+	} else {
+		writer := zlib.NewWriter(&buf)
+		writer.Write(message)
+		writer.Close()
+	}
+
+	compressed := frame(buf.Bytes())
+	compressed[0] = 0x01
+
+	return compressed
+}
@@ -0,0 +1,278 @@
+package http2grpc
+
+import (
+	"context"
+	"fmt"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	metricInFlightCalls = "http2grpc_in_flight_calls"
+	metricQueuedCalls   = "http2grpc_queued_calls"
+	metricShedCalls     = "http2grpc_shed_calls_total"
+
+	// maxTrackedMethods bounds methods having own semaphore and metric series, because methods are chosen by clients,
+	// the rest of methods share semaphore of their limit and concurrencyOtherMethods label
+	maxTrackedMethods       = 1024
+	concurrencyOtherMethods = "other"
+)
+
+// ConcurrencyMethodLimitConfig describes limit of concurrent calls of every gRPC method.
+type ConcurrencyMethodLimitConfig struct {
+	// Methods are glob patterns of gRPC methods, every method has its own limit
+	Methods []string `yaml:"methods"`
+	// MaxInFlight is max number of concurrent calls of one method
+	MaxInFlight int `yaml:"maxInFlight"`
+}
+
+// ConcurrencyLimitConfig describes limit of concurrent calls passed to backend,
+// including long streaming ones, overflow is rejected without calling backend.
+type ConcurrencyLimitConfig struct {
+	// MaxInFlight is max number of concurrent calls of middleware instance, unlimited if 0
+	MaxInFlight int `yaml:"maxInFlight"`
+	// Methods are checked in order, the first one matching method is applied in addition to MaxInFlight
+	Methods []ConcurrencyMethodLimitConfig `yaml:"methods"`
+	// QueueSize is max number of calls waiting for free slot, overflow is rejected immediately if 0
+	QueueSize int `yaml:"queueSize"`
+	// QueueTimeout is max waiting time for free slot as go duration string
+	QueueTimeout string `yaml:"queueTimeout"`
+	// Code is gRPC status code of rejected call, RESOURCE_EXHAUSTED (8) or UNAVAILABLE (14)
+	Code int `yaml:"code"`
+	// Message is gRPC status message of rejected call
+	Message string `yaml:"message"`
+}
+
+// semaphore is channel with capacity of limit, every call in flight holds one element.
+type semaphore chan struct{}
+
+type concurrencyMethodLimit struct {
+	config  *ConcurrencyMethodLimitConfig
+	methods methodMatcher
+	// overflow is shared by methods matching the limit, which are not tracked
+	overflow semaphore
+}
+
+type concurrencyLimiter struct {
+	config       *ConcurrencyLimitConfig
+	instance     semaphore
+	methodLimits []concurrencyMethodLimit
+	queueTimeout time.Duration
+	metrics      *metrics
+	// queued is number of calls waiting for free slot
+	queued int32
+
+	mu sync.Mutex
+	// methodSemaphores are created on the first call of method, nil for methods not limited
+	methodSemaphores map[string]semaphore
+}
+
+// newConcurrencyLimiter returns nil if there are no limits.
+func newConcurrencyLimiter(config *ConcurrencyLimitConfig, m *metrics) (*concurrencyLimiter, error) {
+	if config.MaxInFlight == 0 && len(config.Methods) == 0 {
+		return nil, nil //nolint:nilnil // disabled feature
+	}
+
+	if config.MaxInFlight < 0 {
+		return nil, fmt.Errorf("ERROR: http2grpc: negative concurrencyLimit.maxInFlight %d", config.MaxInFlight)
+	}
+
+	if config.QueueSize < 0 {
+		return nil, fmt.Errorf("ERROR: http2grpc: negative concurrencyLimit.queueSize %d", config.QueueSize)
+	}
+
+	if config.Code != grpc.RESOURCE_EXHAUSTED && config.Code != grpc.UNAVAILABLE {
+		return nil, fmt.Errorf("ERROR: http2grpc: concurrencyLimit.code %d is not RESOURCE_EXHAUSTED or UNAVAILABLE",
+			config.Code)
+	}
+
+	limiter := &concurrencyLimiter{
+		config:           config,
+		methodLimits:     make([]concurrencyMethodLimit, 0, len(config.Methods)),
+		metrics:          m,
+		methodSemaphores: make(map[string]semaphore),
+	}
+
+	if config.MaxInFlight > 0 {
+		limiter.instance = make(semaphore, config.MaxInFlight)
+	}
+
+	for i := range config.Methods {
+		methodConfig := &config.Methods[i]
+		if methodConfig.MaxInFlight <= 0 {
+			return nil, fmt.Errorf("ERROR: http2grpc: concurrencyLimit.methods[%d].maxInFlight must be positive, got %d",
+				i, methodConfig.MaxInFlight)
+		}
+
+		methods, err := newMethodMatcher(methodConfig.Methods)
+		if err != nil {
+			return nil, err
+		}
+
+		limiter.methodLimits = append(limiter.methodLimits, concurrencyMethodLimit{
+			config:   methodConfig,
+			methods:  methods,
+			overflow: make(semaphore, methodConfig.MaxInFlight),
+		})
+	}
+
+	if config.QueueSize > 0 {
+		queueTimeout, err := time.ParseDuration(config.QueueTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("ERROR: http2grpc: concurrencyLimit.queueTimeout: %w", err)
+		}
+
+		limiter.queueTimeout = queueTimeout
+	}
+
+	m.register(metricInFlightCalls, metricGauge, "gRPC calls in flight to backend.")
+	m.register(metricQueuedCalls, metricGauge, "gRPC calls waiting for concurrency limit.")
+	m.register(metricShedCalls, metricCounter, "gRPC calls rejected by concurrency limit.")
+
+	return limiter, nil
+}
+
+// acquire takes slots of instance and method limits, waiting in queue if it is configured,
+// or responds with error status, release must be called after backend completes the call.
+func (l *concurrencyLimiter) acquire(rw *http2grpcModifier, req *http.Request) (func(), bool) {
+	method, methodSemaphore := l.methodSlot(grpcMethod(req))
+
+	if !l.tryAcquire(methodSemaphore) && !l.waitAcquire(req.Context(), methodSemaphore) {
+		l.reject(rw, method)
+		return nil, false
+	}
+
+	l.metrics.add(metricInFlightCalls, 1, "method", method)
+
+	release := func() {
+		l.metrics.add(metricInFlightCalls, -1, "method", method)
+		releaseSlot(l.instance)
+		releaseSlot(methodSemaphore)
+	}
+
+	return release, true
+}
+
+// tryAcquire takes both slots without waiting.
+func (l *concurrencyLimiter) tryAcquire(methodSemaphore semaphore) bool {
+	if !tryAcquireSlot(methodSemaphore) {
+		return false
+	}
+
+	if !tryAcquireSlot(l.instance) {
+		releaseSlot(methodSemaphore)
+		return false
+	}
+
+	return true
+}
+
+// waitAcquire takes both slots waiting in queue not longer than queue timeout.
+func (l *concurrencyLimiter) waitAcquire(ctx context.Context, methodSemaphore semaphore) bool {
+	if int(atomic.AddInt32(&l.queued, 1)) > l.config.QueueSize {
+		atomic.AddInt32(&l.queued, -1)
+		return false
+	}
+
+	l.metrics.add(metricQueuedCalls, 1)
+
+	defer func() {
+		atomic.AddInt32(&l.queued, -1)
+		l.metrics.add(metricQueuedCalls, -1)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, l.queueTimeout)
+	defer cancel()
+
+	if !waitSlot(ctx, methodSemaphore) {
+		return false
+	}
+
+	if !waitSlot(ctx, l.instance) {
+		releaseSlot(methodSemaphore)
+		return false
+	}
+
+	return true
+}
+
+func (l *concurrencyLimiter) reject(rw *http2grpcModifier, method string) {
+	LoggerINFO.Printf("concurrency limit: method %q rejected", method)
+	l.metrics.add(metricShedCalls, 1, "method", method)
+
+	rw.writeGrpcStatus(l.config.Code, l.config.Message)
+}
+
+// methodSlot returns metric label of method and semaphore of the first matching method limit,
+// nil if method is not limited, methods beyond maxTrackedMethods share overflow semaphore of their limit.
+func (l *concurrencyLimiter) methodSlot(method string) (string, semaphore) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if sem, ok := l.methodSemaphores[method]; ok {
+		return method, sem
+	}
+
+	var limit *concurrencyMethodLimit
+
+	for i := range l.methodLimits {
+		if l.methodLimits[i].methods.match(method) {
+			limit = &l.methodLimits[i]
+			break
+		}
+	}
+
+	if len(l.methodSemaphores) >= maxTrackedMethods {
+		if limit == nil {
+			return concurrencyOtherMethods, nil
+		}
+
+		return concurrencyOtherMethods, limit.overflow
+	}
+
+	var sem semaphore
+	if limit != nil {
+		sem = make(semaphore, limit.config.MaxInFlight)
+	}
+
+	l.methodSemaphores[method] = sem
+
+	return method, sem
+}
+
+// tryAcquireSlot takes slot without waiting, nil semaphore is unlimited.
+func tryAcquireSlot(sem semaphore) bool {
+	if sem == nil {
+		return true
+	}
+
+	select {
+	case sem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// waitSlot takes slot waiting until context is done, nil semaphore is unlimited.
+func waitSlot(ctx context.Context, sem semaphore) bool {
+	if sem == nil {
+		return true
+	}
+
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func releaseSlot(sem semaphore) {
+	if sem != nil {
+		<-sem
+	}
+}
@@ -0,0 +1,237 @@
+package http2grpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/v-electrolux/http2grpc"
+)
+
+type TestConcurrencyData struct {
+	cfgLimit http2grpc.ConcurrencyLimitConfig
+
+	// prevCalls is number of calls of distinct methods completed before
+	prevCalls int
+	// reqPath of the call holding the slot until the second call is done or released by releaseAfter
+	reqPath      string
+	secondPath   string
+	releaseAfter time.Duration
+
+	expMetricsInFlight   []string
+	expGrpcResStatusCode int
+	expGrpcResStatusMsg  string
+	expMetrics           []string
+}
+
+func TestConcurrencyInstanceLimitRejects(t *testing.T) {
+	data := TestConcurrencyData{
+		cfgLimit: http2grpc.ConcurrencyLimitConfig{MaxInFlight: 1},
+
+		reqPath:    "/pkg.v1.Service/Watch",
+		secondPath: "/pkg.v1.Service/Get",
+
+		expMetricsInFlight: []string{
+			`http2grpc_in_flight_calls{method="/pkg.v1.Service/Watch"} 1`,
+		},
+		expGrpcResStatusCode: 8,
+		expGrpcResStatusMsg:  "too many concurrent calls",
+		expMetrics: []string{
+			`http2grpc_in_flight_calls{method="/pkg.v1.Service/Watch"} 0`,
+			`http2grpc_shed_calls_total{method="/pkg.v1.Service/Get"} 1`,
+		},
+	}
+	testConcurrencyRequest(t, data)
+}
+
+func TestConcurrencyMethodLimitRejects(t *testing.T) {
+	data := TestConcurrencyData{
+		cfgLimit: http2grpc.ConcurrencyLimitConfig{
+			Methods: []http2grpc.ConcurrencyMethodLimitConfig{
+				{Methods: []string{"/pkg.v1.Service/Watch"}, MaxInFlight: 1},
+			},
+			Code:    14,
+			Message: "overloaded",
+		},
+
+		reqPath:    "/pkg.v1.Service/Watch",
+		secondPath: "/pkg.v1.Service/Watch",
+
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "overloaded",
+	}
+	testConcurrencyRequest(t, data)
+}
+
+func TestConcurrencyOtherMethodPassed(t *testing.T) {
+	data := TestConcurrencyData{
+		cfgLimit: http2grpc.ConcurrencyLimitConfig{
+			Methods: []http2grpc.ConcurrencyMethodLimitConfig{
+				{Methods: []string{"/pkg.v1.Service/Watch"}, MaxInFlight: 1},
+			},
+		},
+
+		reqPath:    "/pkg.v1.Service/Watch",
+		secondPath: "/pkg.v1.Service/Get",
+
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testConcurrencyRequest(t, data)
+}
+
+func TestConcurrencyQueuedCallPassed(t *testing.T) {
+	data := TestConcurrencyData{
+		cfgLimit: http2grpc.ConcurrencyLimitConfig{MaxInFlight: 1, QueueSize: 1, QueueTimeout: "5s"},
+
+		reqPath:      "/pkg.v1.Service/Watch",
+		secondPath:   "/pkg.v1.Service/Get",
+		releaseAfter: 50 * time.Millisecond,
+
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+		expMetrics: []string{
+			`http2grpc_queued_calls 0`,
+		},
+	}
+	testConcurrencyRequest(t, data)
+}
+
+func TestConcurrencyQueueTimeout(t *testing.T) {
+	data := TestConcurrencyData{
+		cfgLimit: http2grpc.ConcurrencyLimitConfig{MaxInFlight: 1, QueueSize: 1, QueueTimeout: "20ms"},
+
+		reqPath:    "/pkg.v1.Service/Watch",
+		secondPath: "/pkg.v1.Service/Get",
+
+		expGrpcResStatusCode: 8,
+		expGrpcResStatusMsg:  "too many concurrent calls",
+	}
+	testConcurrencyRequest(t, data)
+}
+
+func TestConcurrencyUntrackedMethodsShareLimit(t *testing.T) {
+	data := TestConcurrencyData{
+		cfgLimit: http2grpc.ConcurrencyLimitConfig{
+			Methods: []http2grpc.ConcurrencyMethodLimitConfig{
+				{Methods: []string{"*/*"}, MaxInFlight: 1},
+			},
+		},
+
+		prevCalls:  1024,
+		reqPath:    "/pkg.v1.Service/Watch",
+		secondPath: "/pkg.v1.Service/Get",
+
+		expMetricsInFlight: []string{
+			`http2grpc_in_flight_calls{method="other"} 1`,
+		},
+		expGrpcResStatusCode: 8,
+		expGrpcResStatusMsg:  "too many concurrent calls",
+		expMetrics: []string{
+			`http2grpc_in_flight_calls{method="/pkg.v1.Prev/Call0"} 0`,
+			`http2grpc_in_flight_calls{method="other"} 0`,
+			`http2grpc_shed_calls_total{method="other"} 1`,
+		},
+	}
+	testConcurrencyRequest(t, data)
+}
+
+func testConcurrencyRequest(t *testing.T, data TestConcurrencyData) {
+	t.Helper()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.ConcurrencyLimit.MaxInFlight = data.cfgLimit.MaxInFlight
+	cfg.ConcurrencyLimit.Methods = data.cfgLimit.Methods
+	cfg.ConcurrencyLimit.QueueSize = data.cfgLimit.QueueSize
+	cfg.Metrics.Path = "/metrics"
+
+	if data.cfgLimit.QueueTimeout != "" {
+		cfg.ConcurrencyLimit.QueueTimeout = data.cfgLimit.QueueTimeout
+	}
+
+	if data.cfgLimit.Code != 0 {
+		cfg.ConcurrencyLimit.Code = data.cfgLimit.Code
+	}
+
+	if data.cfgLimit.Message != "" {
+		cfg.ConcurrencyLimit.Message = data.cfgLimit.Message
+	}
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == data.reqPath {
+			select {
+			case entered <- struct{}{}:
+				<-release
+			default:
+			}
+		}
+
+		rw.Header().Set("Content-Type", "application/grpc")
+		rw.Header().Set("Trailer", "grpc-status")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte{0x00, 0x00, 0x00, 0x00, 0x00})
+		rw.Header().Set("grpc-status", "0")
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < data.prevCalls; i++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost/pkg.v1.Prev/Call"+strconv.Itoa(i), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost"+data.reqPath, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	<-entered
+
+	assertMetrics(t, handler, data.expMetricsInFlight)
+
+	if data.releaseAfter > 0 {
+		time.AfterFunc(data.releaseAfter, func() { close(release) })
+	}
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost"+data.secondPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(recorder, req)
+
+	if data.releaseAfter == 0 {
+		close(release)
+	}
+
+	<-done
+
+	resp := recorder.Result()
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertBody(t, resp, []byte{0x00, 0x00, 0x00, 0x00, 0x00})
+	assertHeader(t, resp, "Content-Type", "application/grpc")
+	assertTrailer(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+	assertTrailer(t, resp, "grpc-message", data.expGrpcResStatusMsg)
+	assertMetrics(t, handler, data.expMetrics)
+}
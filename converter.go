@@ -0,0 +1,253 @@
+package http2grpc
+
+import (
+	"fmt"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"net/http"
+	"os"
+)
+
+// Conversion is HTTP response of next handler converted to gRPC status, it is passed to OnConvert hook.
+type Conversion struct {
+	Request *http.Request
+	// HTTPStatus is status code of HTTP response
+	HTTPStatus int
+	// Header is header of HTTP response before conversion
+	Header http.Header
+	// Body is body of HTTP response up to 64 KiB, it is not forwarded to client
+	Body []byte
+	// Code, Message and Details are gRPC status sent to client, hook may change them
+	Code    int
+	Message string
+	Details []grpc.Any
+}
+
+// StatusMapper maps HTTP status code of response to gRPC status code.
+type StatusMapper func(httpStatusCode int) int
+
+// MessageExtractor builds gRPC status message from HTTP response.
+type MessageExtractor func(httpStatusCode int, header http.Header, body []byte) string
+
+// Option configures Converter created by Wrap.
+type Option func(*Converter)
+
+// WithConfig sets configuration of Converter, it is CreateConfig by default.
+func WithConfig(config *Config) Option {
+	return func(c *Converter) {
+		c.config = config
+	}
+}
+
+// WithName sets name of Converter instance.
+func WithName(name string) Option {
+	return func(c *Converter) {
+		c.name = name
+	}
+}
+
+// WithStatusMapper replaces gRPC spec map of HTTP status codes to gRPC ones, which is DefaultStatusMapper.
+func WithStatusMapper(mapper StatusMapper) Option {
+	return func(c *Converter) {
+		c.hooksOrNew().mapper = mapper
+	}
+}
+
+// WithMessageExtractor sets builder of gRPC status message from HTTP response,
+// it replaces bodyAsStatusMessage flag.
+func WithMessageExtractor(extractor MessageExtractor) Option {
+	return func(c *Converter) {
+		c.hooksOrNew().extractor = extractor
+	}
+}
+
+// OnConvert sets hook called for each HTTP response with non-200 status code converted to gRPC status,
+// just before the status is sent to client.
+func OnConvert(hook func(*Conversion)) Option {
+	return func(c *Converter) {
+		c.hooksOrNew().onConvert = hook
+	}
+}
+
+// conversionHooks customize conversion of HTTP responses with non-200 status code.
+type conversionHooks struct {
+	mapper    StatusMapper
+	extractor MessageExtractor
+	onConvert func(*Conversion)
+	// validation converts validation errors in body to BadRequest detail, nil if disabled
+	validation *validationErrors
+}
+
+func (c *Converter) hooksOrNew() *conversionHooks {
+	if c.hooks == nil {
+		c.hooks = &conversionHooks{}
+	}
+
+	return c.hooks
+}
+
+// collectsBody is whether HTTP response is needed as a whole after it is sent.
+func (h *conversionHooks) collectsBody() bool {
+	return h != nil && (h.extractor != nil || h.onConvert != nil || h.validation != nil)
+}
+
+// mapStatus maps HTTP status code with custom mapper if it is set.
+func (h *conversionHooks) mapStatus(httpStatusCode int) int {
+	if h != nil && h.mapper != nil && httpStatusCode != http.StatusOK {
+		return h.mapper(httpStatusCode)
+	}
+
+	return DefaultStatusMapper(httpStatusCode)
+}
+
+// Wrap returns Converter of responses of next handler, configured by options.
+func Wrap(next http.Handler, opts ...Option) (*Converter, error) {
+	c := &Converter{next: next, config: CreateConfig(), name: "http2grpc"}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.config == nil {
+		return nil, fmt.Errorf("ERROR: http2grpc: nil config")
+	}
+
+	config := c.config
+
+	switch config.LogLevel {
+	case "info":
+		LoggerINFO.SetOutput(os.Stdout)
+	case "debug":
+		LoggerINFO.SetOutput(os.Stdout)
+		LoggerDEBUG.SetOutput(os.Stdout)
+	default:
+		return nil, fmt.Errorf("ERROR: http2grpc: %s", config.LogLevel)
+	}
+
+	if config.MaxResponseMessageSize < 0 {
+		return nil, fmt.Errorf("ERROR: http2grpc: negative maxResponseMessageSize %d", config.MaxResponseMessageSize)
+	}
+
+	if err := validateCompression(&config.Compression); err != nil {
+		return nil, err
+	}
+
+	metrics := newMetrics()
+
+	acl, err := newMethodACL(&config.MethodACL, metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	maintenance, err := newMaintenance(&config.Maintenance)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimiter, err := newRateLimiter(&config.RateLimit, metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	health, err := newHealthService(&config.Health)
+	if err != nil {
+		return nil, err
+	}
+
+	stubs, err := newStubs(&config.Stubs)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency, err := newConcurrencyLimiter(&config.ConcurrencyLimit, metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	deadlines, err := newDeadlines(&config.Deadlines)
+	if err != nil {
+		return nil, err
+	}
+
+	retrier, err := newRetrier(&config.Retry)
+	if err != nil {
+		return nil, err
+	}
+
+	messageSizes, err := newMessageSizeLimits(&config.MessageSizeLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	validation, err := newValidationErrors(&config.ValidationErrors)
+	if err != nil {
+		return nil, err
+	}
+
+	if validation != nil {
+		c.hooksOrNew().validation = validation
+	}
+
+	localizer, err := newLocalizer(&config.Localization)
+	if err != nil {
+		return nil, err
+	}
+
+	fwdAuth, err := newForwardAuth(&config.ForwardAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	faults, err := newFaultInjector(&config.FaultInjection)
+	if err != nil {
+		return nil, err
+	}
+
+	var interceptors []interceptor
+	if acl != nil {
+		interceptors = append(interceptors, acl)
+	}
+
+	if maintenance != nil {
+		interceptors = append(interceptors, maintenance)
+	}
+
+	if rateLimiter != nil {
+		interceptors = append(interceptors, rateLimiter)
+	}
+
+	if health != nil {
+		interceptors = append(interceptors, health)
+	}
+
+	if fwdAuth != nil {
+		fwdAuth.retrier = retrier
+		interceptors = append(interceptors, fwdAuth)
+	}
+
+	if faults != nil {
+		interceptors = append(interceptors, faults)
+	}
+
+	if stubs != nil {
+		interceptors = append(interceptors, stubs)
+	}
+
+	c.interceptors = interceptors
+	c.concurrency = concurrency
+	c.deadlines = deadlines
+	c.retrier = retrier
+
+	if retrier != nil && c.hooks != nil {
+		retrier.mapStatus = c.hooks.mapStatus
+	}
+
+	if retrier != nil && config.ProxyErrors.Enabled {
+		retrier.proxyErrors = &config.ProxyErrors
+	}
+
+	c.messageSizes = messageSizes
+	c.statusDetails = newStatusDetailsInspector(&config.StatusDetails, metrics)
+	c.localizer = localizer
+	c.metrics = metrics
+
+	return c, nil
+}
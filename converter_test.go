@@ -0,0 +1,264 @@
+package http2grpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/v-electrolux/http2grpc"
+	"github.com/v-electrolux/http2grpc/grpc"
+)
+
+type TestConverterData struct {
+	opts []http2grpc.Option
+
+	backendStatusCode int
+	backendHeaders    map[string]string
+	backendBody       []string
+
+	expGrpcResStatusCode int
+	expGrpcResStatusMsg  string
+}
+
+func TestConverterDefaults(t *testing.T) {
+	data := TestConverterData{
+		backendStatusCode: http.StatusUnauthorized,
+		backendBody:       []string{"denied"},
+
+		expGrpcResStatusCode: grpc.UNAUTHENTICATED,
+		expGrpcResStatusMsg:  "",
+	}
+	testConverterRequest(t, data)
+}
+
+func TestConverterStatusMapper(t *testing.T) {
+	data := TestConverterData{
+		opts: []http2grpc.Option{
+			http2grpc.WithStatusMapper(func(httpStatusCode int) int {
+				if httpStatusCode == http.StatusNotFound {
+					return grpc.NOT_FOUND
+				}
+
+				return http2grpc.DefaultStatusMapper(httpStatusCode)
+			}),
+		},
+
+		backendStatusCode: http.StatusNotFound,
+
+		expGrpcResStatusCode: grpc.NOT_FOUND,
+		expGrpcResStatusMsg:  "",
+	}
+	testConverterRequest(t, data)
+}
+
+func TestConverterMessageExtractor(t *testing.T) {
+	data := TestConverterData{
+		opts: []http2grpc.Option{
+			http2grpc.WithMessageExtractor(func(httpStatusCode int, header http.Header, body []byte) string {
+				return header.Get("X-Reason") + ": " + strings.ToUpper(string(body))
+			}),
+		},
+
+		backendStatusCode: http.StatusForbidden,
+		backendHeaders:    map[string]string{"X-Reason": "policy"},
+		backendBody:       []string{"not ", "yours"},
+
+		expGrpcResStatusCode: grpc.PERMISSION_DENIED,
+		expGrpcResStatusMsg:  "policy: NOT YOURS",
+	}
+	testConverterRequest(t, data)
+}
+
+func TestConverterOnConvert(t *testing.T) {
+	var conversion *http2grpc.Conversion
+
+	data := TestConverterData{
+		opts: []http2grpc.Option{
+			http2grpc.WithConfig(&http2grpc.Config{LogLevel: "info", BodyAsStatusMessage: true}),
+			http2grpc.OnConvert(func(c *http2grpc.Conversion) {
+				conversion = c
+				c.Code = grpc.UNAVAILABLE
+				c.Message = "retry later: " + c.Message
+			}),
+		},
+
+		backendStatusCode: http.StatusTooManyRequests,
+		backendHeaders:    map[string]string{"Content-Type": "text/plain"},
+		backendBody:       []string{"slow down"},
+
+		expGrpcResStatusCode: grpc.UNAVAILABLE,
+		expGrpcResStatusMsg:  "retry later: slow down",
+	}
+	testConverterRequest(t, data)
+
+	if conversion == nil {
+		t.Fatal("expected OnConvert call")
+	}
+
+	if conversion.HTTPStatus != http.StatusTooManyRequests || string(conversion.Body) != "slow down" ||
+		conversion.Header.Get("Content-Type") != "text/plain" || conversion.Request.URL.Path != "/pkg.v1.Svc/Get" {
+		t.Errorf("unexpected conversion %+v", conversion)
+	}
+}
+
+func TestConverterOnConvertBodyLimited(t *testing.T) {
+	bodySize := 0
+
+	data := TestConverterData{
+		opts: []http2grpc.Option{
+			http2grpc.OnConvert(func(c *http2grpc.Conversion) {
+				bodySize = len(c.Body)
+			}),
+		},
+
+		backendStatusCode: http.StatusInternalServerError,
+		backendBody:       []string{strings.Repeat("a", 50*1024), strings.Repeat("b", 50*1024)},
+
+		expGrpcResStatusCode: grpc.UNKNOWN,
+		expGrpcResStatusMsg:  "",
+	}
+	testConverterRequest(t, data)
+
+	if bodySize != 64*1024 {
+		t.Errorf("expected conversion body size: `%d`, got: `%d`", 64*1024, bodySize)
+	}
+}
+
+func TestConverterOnConvertNotCalledForGrpc(t *testing.T) {
+	called := false
+
+	data := TestConverterData{
+		opts: []http2grpc.Option{
+			http2grpc.OnConvert(func(c *http2grpc.Conversion) {
+				called = true
+			}),
+		},
+
+		backendStatusCode: http.StatusOK,
+		backendHeaders:    map[string]string{"Content-Type": "application/grpc"},
+
+		expGrpcResStatusCode: grpc.OK,
+	}
+	testConverterRequest(t, data)
+
+	if called {
+		t.Error("OnConvert must not be called for gRPC response")
+	}
+}
+
+func TestConverterStatusMapperAppliedToRetries(t *testing.T) {
+	cfg := http2grpc.CreateConfig()
+	cfg.Retry.Policies = []http2grpc.RetryPolicyConfig{{
+		Methods: []string{"/pkg.v1.Svc/Get"}, MaxAttempts: 2, InitialBackoff: "1ms", MaxBackoff: "1ms", BackoffMultiplier: 1,
+	}}
+
+	backendCalls := 0
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		backendCalls++
+
+		if backendCalls == 1 {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := http2grpc.Wrap(next, http2grpc.WithConfig(cfg), http2grpc.WithStatusMapper(func(int) int {
+		return grpc.UNAVAILABLE
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "http://localhost/pkg.v1.Svc/Get", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if backendCalls != 2 {
+		t.Errorf("expected backend calls: `2`, got: `%d`", backendCalls)
+	}
+}
+
+func TestWrapNilConfig(t *testing.T) {
+	_, err := http2grpc.Wrap(http.NotFoundHandler(), http2grpc.WithConfig(nil))
+	if err == nil {
+		t.Error("expected error for nil config")
+	}
+}
+
+func TestNewInvalidConfigReturnsNilHandler(t *testing.T) {
+	cfg := http2grpc.CreateConfig()
+	cfg.LogLevel = "verbose"
+
+	handler, err := http2grpc.New(context.Background(), http.NotFoundHandler(), cfg, "test")
+	if err == nil {
+		t.Fatal("expected error for invalid logLevel")
+	}
+
+	if handler != nil {
+		t.Errorf("expected nil handler, got %#v", handler)
+	}
+}
+
+func TestNewIsConverter(t *testing.T) {
+	handler, err := http2grpc.New(context.Background(), http.NotFoundHandler(), http2grpc.CreateConfig(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := handler.(*http2grpc.Converter); !ok {
+		t.Errorf("expected *http2grpc.Converter, got %T", handler)
+	}
+}
+
+func testConverterRequest(t *testing.T, data TestConverterData) {
+	t.Helper()
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		for key, value := range data.backendHeaders {
+			rw.Header().Set(key, value)
+		}
+
+		isGrpc := rw.Header().Get("Content-Type") == "application/grpc"
+		if isGrpc {
+			rw.Header().Set("Trailer", "grpc-status")
+		}
+
+		rw.WriteHeader(data.backendStatusCode)
+
+		for _, chunk := range data.backendBody {
+			rw.Write([]byte(chunk))
+		}
+
+		if isGrpc {
+			rw.Header().Set("grpc-status", "0")
+		}
+	})
+
+	handler, err := http2grpc.Wrap(next, data.opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequest(http.MethodPost, "http://localhost/pkg.v1.Svc/Get", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertHeader(t, resp, "Content-Type", "application/grpc")
+	assertTrailer(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+	assertTrailer(t, resp, "grpc-message", data.expGrpcResStatusMsg)
+}
@@ -0,0 +1,210 @@
+package http2grpc
+
+import (
+	"context"
+	"fmt"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	GrpcTimeoutHeaderName = "grpc-timeout"
+
+	// maxGrpcTimeoutValue is max TimeoutValue of grpc-timeout header, it is at most 8 digits by gRPC spec
+	maxGrpcTimeoutValue = 99999999
+
+	maxDuration = time.Duration(1<<63 - 1)
+)
+
+// grpcTimeoutUnits are TimeoutUnit of grpc-timeout header from the finest one.
+//
+//nolint:gochecknoglobals // static table from gRPC spec
+var grpcTimeoutUnits = []struct {
+	unit     byte
+	duration time.Duration
+}{
+	{'n', time.Nanosecond},
+	{'u', time.Microsecond},
+	{'m', time.Millisecond},
+	{'S', time.Second},
+	{'M', time.Minute},
+	{'H', time.Hour},
+}
+
+// DeadlineConfig describes deadline of gRPC calls of methods.
+type DeadlineConfig struct {
+	// Methods are glob patterns of gRPC methods, all methods if empty
+	Methods []string `yaml:"methods"`
+	// Default is deadline of call without grpc-timeout header as go duration string, not added if empty
+	Default string `yaml:"default"`
+	// Max is max deadline as go duration string, longer grpc-timeout is clamped, not limited if empty
+	Max string `yaml:"max"`
+}
+
+// DeadlinesConfig describes deadlines added to calls passed to backend by middleware,
+// so clients without deadline can not pin backend resources forever.
+type DeadlinesConfig struct {
+	// Rules are checked in order, the first one matching method is applied
+	Rules []DeadlineConfig `yaml:"rules"`
+}
+
+type deadlineRule struct {
+	methods         methodMatcher
+	defaultDeadline time.Duration
+	maxDeadline     time.Duration
+}
+
+type deadlines struct {
+	rules []deadlineRule
+}
+
+// newDeadlines returns nil if there are no rules.
+func newDeadlines(config *DeadlinesConfig) (*deadlines, error) {
+	if len(config.Rules) == 0 {
+		return nil, nil //nolint:nilnil // disabled feature
+	}
+
+	d := &deadlines{rules: make([]deadlineRule, 0, len(config.Rules))}
+
+	for i := range config.Rules {
+		rule, err := newDeadlineRule(&config.Rules[i])
+		if err != nil {
+			return nil, fmt.Errorf("ERROR: http2grpc: deadlines.rules[%d]: %w", i, err)
+		}
+
+		d.rules = append(d.rules, rule)
+	}
+
+	return d, nil
+}
+
+func newDeadlineRule(config *DeadlineConfig) (deadlineRule, error) {
+	methods, err := newMethodMatcher(config.Methods)
+	if err != nil {
+		return deadlineRule{}, err
+	}
+
+	rule := deadlineRule{methods: methods}
+
+	if rule.defaultDeadline, err = parseOptionalDuration(config.Default); err != nil {
+		return deadlineRule{}, fmt.Errorf("default: %w", err)
+	}
+
+	if rule.maxDeadline, err = parseOptionalDuration(config.Max); err != nil {
+		return deadlineRule{}, fmt.Errorf("max: %w", err)
+	}
+
+	if rule.maxDeadline > 0 && rule.defaultDeadline > rule.maxDeadline {
+		return deadlineRule{}, fmt.Errorf("default %s is longer than max %s", rule.defaultDeadline, rule.maxDeadline)
+	}
+
+	return rule, nil
+}
+
+// apply adds or clamps grpc-timeout header of the first matching rule and sets the deadline to request context,
+// cancel must be called after backend completes the call, it is nil if request is not changed.
+func (d *deadlines) apply(req *http.Request) (*http.Request, context.CancelFunc) {
+	method := grpcMethod(req)
+
+	for _, rule := range d.rules {
+		if !rule.methods.matchOrEmpty(method) {
+			continue
+		}
+
+		timeout, ok := parseGrpcTimeout(req.Header.Get(GrpcTimeoutHeaderName))
+
+		switch {
+		case !ok && rule.defaultDeadline > 0:
+			timeout = rule.defaultDeadline
+			LoggerDEBUG.Printf("deadline: %s has no grpc-timeout, %s added", method, timeout)
+		case ok && rule.maxDeadline > 0 && timeout > rule.maxDeadline:
+			LoggerDEBUG.Printf("deadline: %s grpc-timeout %s clamped to %s", method, timeout, rule.maxDeadline)
+			timeout = rule.maxDeadline
+		case !ok:
+			return req, nil
+		}
+
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		req = req.WithContext(ctx)
+		req.Header.Set(GrpcTimeoutHeaderName, formatGrpcTimeout(timeout))
+
+		return req, cancel
+	}
+
+	return req, nil
+}
+
+// finishDeadline terminates the call with DEADLINE_EXCEEDED if the deadline elapsed before backend completed it,
+// status from gRPC backend is kept, because the call is completed in that case.
+func finishDeadline(rw *http2grpcModifier, req *http.Request) {
+	if req.Context().Err() != context.DeadlineExceeded { //nolint:errorlint // context errors are not wrapped
+		return
+	}
+
+	if rw.backendUseGrpc && grpc.HasTrailer(rw.Header(), GrpcStatusHeaderName) {
+		return
+	}
+
+	LoggerINFO.Printf("deadline: %s exceeded before backend completed the call", req.URL.Path)
+	rw.fail(grpc.DEADLINE_EXCEEDED, "deadline exceeded")
+}
+
+// parseGrpcTimeout parses grpc-timeout header like `100m`, false if it is absent or invalid.
+func parseGrpcTimeout(value string) (time.Duration, bool) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+
+	amount, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || amount < 0 {
+		return 0, false
+	}
+
+	for _, unit := range grpcTimeoutUnits {
+		if unit.unit == value[len(value)-1] {
+			// the longest value, 99999999 hours, does not fit in time.Duration
+			if amount > int64(maxDuration/unit.duration) {
+				return maxDuration, true
+			}
+
+			return time.Duration(amount) * unit.duration, true
+		}
+	}
+
+	return 0, false
+}
+
+// formatGrpcTimeout formats timeout in the finest unit its value fits 8 digits in, rounding up.
+func formatGrpcTimeout(timeout time.Duration) string {
+	for _, unit := range grpcTimeoutUnits {
+		amount := timeout / unit.duration
+		if timeout%unit.duration != 0 {
+			amount++
+		}
+
+		if amount <= maxGrpcTimeoutValue {
+			return strconv.FormatInt(int64(amount), 10) + string(unit.unit)
+		}
+	}
+
+	return strconv.Itoa(maxGrpcTimeoutValue) + "H"
+}
+
+func parseOptionalDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("duration must be positive, got %s", d)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,199 @@
+package http2grpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/v-electrolux/http2grpc"
+)
+
+type TestDeadlineData struct {
+	cfgRules []http2grpc.DeadlineConfig
+
+	reqPath        string
+	reqGrpcTimeout string
+
+	// backendWaitsDeadline is whether backend ignores the call until its context is done
+	backendWaitsDeadline bool
+	backendHTTPError     bool
+
+	expBackendGrpcTimeout string
+	expGrpcResStatusCode  int
+	expGrpcResStatusMsg   string
+}
+
+func TestDeadlineDefaultAdded(t *testing.T) {
+	data := TestDeadlineData{
+		cfgRules: []http2grpc.DeadlineConfig{
+			{Methods: []string{"/pkg.v1.Service/*"}, Default: "100ms"},
+		},
+
+		reqPath: "/pkg.v1.Service/Get",
+
+		expBackendGrpcTimeout: "100000u",
+		expGrpcResStatusCode:  0,
+		expGrpcResStatusMsg:   "",
+	}
+	testDeadlineRequest(t, data)
+}
+
+func TestDeadlineMaxClamped(t *testing.T) {
+	data := TestDeadlineData{
+		cfgRules: []http2grpc.DeadlineConfig{
+			{Default: "1s", Max: "2s"},
+		},
+
+		reqPath:        "/pkg.v1.Service/Get",
+		reqGrpcTimeout: "1H",
+
+		expBackendGrpcTimeout: "2000000u",
+		expGrpcResStatusCode:  0,
+		expGrpcResStatusMsg:   "",
+	}
+	testDeadlineRequest(t, data)
+}
+
+func TestDeadlineShorterKept(t *testing.T) {
+	data := TestDeadlineData{
+		cfgRules: []http2grpc.DeadlineConfig{
+			{Max: "2s"},
+		},
+
+		reqPath:        "/pkg.v1.Service/Get",
+		reqGrpcTimeout: "150m",
+
+		expBackendGrpcTimeout: "150000u",
+		expGrpcResStatusCode:  0,
+		expGrpcResStatusMsg:   "",
+	}
+	testDeadlineRequest(t, data)
+}
+
+func TestDeadlineOtherMethodNotChanged(t *testing.T) {
+	data := TestDeadlineData{
+		cfgRules: []http2grpc.DeadlineConfig{
+			{Methods: []string{"/pkg.v1.Other/*"}, Default: "1s"},
+		},
+
+		reqPath: "/pkg.v1.Service/Get",
+
+		expBackendGrpcTimeout: "",
+		expGrpcResStatusCode:  0,
+		expGrpcResStatusMsg:   "",
+	}
+	testDeadlineRequest(t, data)
+}
+
+func TestDeadlineExceededByGrpcBackend(t *testing.T) {
+	data := TestDeadlineData{
+		cfgRules: []http2grpc.DeadlineConfig{
+			{Default: "20ms"},
+		},
+
+		reqPath:              "/pkg.v1.Service/Get",
+		backendWaitsDeadline: true,
+
+		expBackendGrpcTimeout: "20000000n",
+		expGrpcResStatusCode:  4,
+		expGrpcResStatusMsg:   "deadline exceeded",
+	}
+	testDeadlineRequest(t, data)
+}
+
+func TestDeadlineExceededWithProxyError(t *testing.T) {
+	data := TestDeadlineData{
+		cfgRules: []http2grpc.DeadlineConfig{
+			{Default: "20ms"},
+		},
+
+		reqPath:              "/pkg.v1.Service/Get",
+		backendWaitsDeadline: true,
+		backendHTTPError:     true,
+
+		expBackendGrpcTimeout: "20000000n",
+		expGrpcResStatusCode:  4,
+		expGrpcResStatusMsg:   "deadline exceeded",
+	}
+	testDeadlineRequest(t, data)
+}
+
+func TestDeadlineInvalidConfig(t *testing.T) {
+	cfg := http2grpc.CreateConfig()
+	cfg.Deadlines.Rules = []http2grpc.DeadlineConfig{{Default: "5s", Max: "1s"}}
+
+	_, err := http2grpc.New(context.Background(), http.NotFoundHandler(), cfg, "http2grpc")
+	if err == nil {
+		t.Errorf("expected error for default longer than max")
+	}
+}
+
+func testDeadlineRequest(t *testing.T, data TestDeadlineData) {
+	t.Helper()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.Deadlines.Rules = data.cfgRules
+
+	backendGrpcTimeout := ""
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		backendGrpcTimeout = req.Header.Get("grpc-timeout")
+
+		if data.backendWaitsDeadline {
+			select {
+			case <-req.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
+
+		if data.backendHTTPError {
+			// like traefik proxy does when backend does not respond in time
+			rw.WriteHeader(http.StatusGatewayTimeout)
+			rw.Write([]byte(http.StatusText(http.StatusGatewayTimeout)))
+
+			return
+		}
+
+		if data.backendWaitsDeadline {
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/grpc")
+		rw.Header().Set("Trailer", "grpc-status")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte{0x00, 0x00, 0x00, 0x00, 0x00})
+		rw.Header().Set("grpc-status", "0")
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost"+data.reqPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.reqGrpcTimeout != "" {
+		req.Header.Set("grpc-timeout", data.reqGrpcTimeout)
+	}
+
+	handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+
+	if backendGrpcTimeout != data.expBackendGrpcTimeout {
+		t.Errorf("expected backend grpc-timeout: `%s`, got: `%s`", data.expBackendGrpcTimeout, backendGrpcTimeout)
+	}
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertBody(t, resp, []byte{0x00, 0x00, 0x00, 0x00, 0x00})
+	assertHeader(t, resp, "Content-Type", "application/grpc")
+	assertTrailer(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+	assertTrailer(t, resp, "grpc-message", data.expGrpcResStatusMsg)
+}
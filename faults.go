@@ -0,0 +1,171 @@
+package http2grpc
+
+import (
+	"fmt"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FaultConfig describes failure injected before calling backend.
+type FaultConfig struct {
+	// Percentage of matching requests with the fault, from 0 to 100
+	Percentage float64 `yaml:"percentage"`
+	// Methods are glob patterns of gRPC methods with the fault, all methods if empty
+	Methods []string `yaml:"methods"`
+	// Headers are request headers (gRPC metadata) with values, which all must match
+	Headers map[string]string `yaml:"headers"`
+	// Delay before calling backend or aborting as go duration string, no delay if empty
+	Delay string `yaml:"delay"`
+	// AbortCode is gRPC status code to abort with, request is not aborted if 0
+	AbortCode int `yaml:"abortCode"`
+	// AbortMessage is gRPC status message to abort with
+	AbortMessage string `yaml:"abortMessage"`
+}
+
+// FaultInjectionConfig describes failures injected to test resilience of clients.
+type FaultInjectionConfig struct {
+	// Faults are checked in order, the first selected one is injected
+	Faults []FaultConfig `yaml:"faults"`
+	// ToggleHeader is request header, which value `on` forces the matching fault regardless of percentage,
+	// and value `off` disables faults for the request
+	ToggleHeader string `yaml:"toggleHeader"`
+}
+
+type fault struct {
+	config  *FaultConfig
+	methods methodMatcher
+	delay   time.Duration
+}
+
+type faultInjector struct {
+	faults       []fault
+	toggleHeader string
+	// random is not safe for concurrent use, so it is guarded by mutex
+	randomMu sync.Mutex
+	random   *rand.Rand
+}
+
+// newFaultInjector returns nil if there are no faults.
+func newFaultInjector(config *FaultInjectionConfig) (*faultInjector, error) {
+	if len(config.Faults) == 0 {
+		return nil, nil //nolint:nilnil // disabled feature
+	}
+
+	injector := &faultInjector{
+		faults:       make([]fault, 0, len(config.Faults)),
+		toggleHeader: config.ToggleHeader,
+		random:       rand.New(rand.NewSource(time.Now().UnixNano())), //nolint:gosec // not for security
+	}
+
+	for i := range config.Faults {
+		faultConfig := &config.Faults[i]
+
+		if faultConfig.Percentage < 0 || faultConfig.Percentage > 100 {
+			return nil, fmt.Errorf("ERROR: http2grpc: faults[%d].percentage %v out of [0, 100]", i, faultConfig.Percentage)
+		}
+
+		if faultConfig.AbortCode < grpc.OK || faultConfig.AbortCode > grpc.UNAUTHENTICATED {
+			return nil, fmt.Errorf("ERROR: http2grpc: faults[%d].abortCode %d is not gRPC code", i, faultConfig.AbortCode)
+		}
+
+		methods, err := newMethodMatcher(faultConfig.Methods)
+		if err != nil {
+			return nil, err
+		}
+
+		var delay time.Duration
+		if faultConfig.Delay != "" {
+			if delay, err = time.ParseDuration(faultConfig.Delay); err != nil {
+				return nil, fmt.Errorf("ERROR: http2grpc: faults[%d].delay: %w", i, err)
+			}
+		}
+
+		injector.faults = append(injector.faults, fault{
+			config:  faultConfig,
+			methods: methods,
+			delay:   delay,
+		})
+	}
+
+	return injector, nil
+}
+
+// intercept delays and aborts request by the selected fault.
+func (f *faultInjector) intercept(rw *http2grpcModifier, req *http.Request) bool {
+	forced := false
+
+	if f.toggleHeader != "" {
+		switch strings.ToLower(req.Header.Get(f.toggleHeader)) {
+		case "on":
+			forced = true
+		case "off":
+			return false
+		}
+	}
+
+	selected := f.selectFault(req, forced)
+	if selected == nil {
+		return false
+	}
+
+	LoggerDEBUG.Printf("fault injected for %s: delay %s, abort code %d",
+		req.URL.Path, selected.delay, selected.config.AbortCode)
+
+	if selected.delay > 0 {
+		timer := time.NewTimer(selected.delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			rw.writeGrpcStatus(grpc.CANCELLED, "fault: request canceled during injected delay")
+			return true
+		}
+	}
+
+	if selected.config.AbortCode != grpc.OK {
+		rw.writeGrpcStatus(selected.config.AbortCode, selected.config.AbortMessage)
+		return true
+	}
+
+	return false
+}
+
+func (f *faultInjector) selectFault(req *http.Request, forced bool) *fault {
+	method := grpcMethod(req)
+
+	for i := range f.faults {
+		candidate := &f.faults[i]
+
+		if !candidate.methods.matchOrEmpty(method) || !matchHeaders(req.Header, candidate.config.Headers) {
+			continue
+		}
+
+		if forced || f.roll(candidate.config.Percentage) {
+			return candidate
+		}
+	}
+
+	return nil
+}
+
+func (f *faultInjector) roll(percentage float64) bool {
+	f.randomMu.Lock()
+	defer f.randomMu.Unlock()
+
+	return f.random.Float64()*100 < percentage
+}
+
+func matchHeaders(header http.Header, expected map[string]string) bool {
+	for name, value := range expected {
+		if header.Get(name) != value {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,216 @@
+package http2grpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/v-electrolux/http2grpc"
+)
+
+type TestFaultData struct {
+	cfgFault        http2grpc.FaultConfig
+	cfgToggleHeader string
+
+	reqPath    string
+	reqHeaders map[string]string
+
+	expBackendCalled     bool
+	expMinDuration       time.Duration
+	expGrpcResStatusCode int
+	expGrpcResStatusMsg  string
+}
+
+func TestFaultAbortAll(t *testing.T) {
+	data := TestFaultData{
+		cfgFault: http2grpc.FaultConfig{Percentage: 100, AbortCode: 14, AbortMessage: "injected"},
+
+		expBackendCalled:     false,
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "injected",
+	}
+	testFaultRequest(t, data)
+}
+
+func TestFaultAbortNone(t *testing.T) {
+	data := TestFaultData{
+		cfgFault: http2grpc.FaultConfig{Percentage: 0, AbortCode: 14, AbortMessage: "injected"},
+
+		expBackendCalled:     true,
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testFaultRequest(t, data)
+}
+
+func TestFaultForcedByToggleHeader(t *testing.T) {
+	data := TestFaultData{
+		cfgFault:        http2grpc.FaultConfig{Percentage: 0, AbortCode: 4, AbortMessage: "injected"},
+		cfgToggleHeader: "X-Fault",
+
+		reqHeaders: map[string]string{"X-Fault": "on"},
+
+		expBackendCalled:     false,
+		expGrpcResStatusCode: 4,
+		expGrpcResStatusMsg:  "injected",
+	}
+	testFaultRequest(t, data)
+}
+
+func TestFaultDisabledByToggleHeader(t *testing.T) {
+	data := TestFaultData{
+		cfgFault:        http2grpc.FaultConfig{Percentage: 100, AbortCode: 14, AbortMessage: "injected"},
+		cfgToggleHeader: "X-Fault",
+
+		reqHeaders: map[string]string{"X-Fault": "off"},
+
+		expBackendCalled:     true,
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testFaultRequest(t, data)
+}
+
+func TestFaultOtherMethod(t *testing.T) {
+	data := TestFaultData{
+		cfgFault: http2grpc.FaultConfig{
+			Percentage: 100, Methods: []string{"pkg.Service/Other*"}, AbortCode: 14, AbortMessage: "injected",
+		},
+
+		expBackendCalled:     true,
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testFaultRequest(t, data)
+}
+
+func TestFaultEmptyMethodNameNotMatched(t *testing.T) {
+	data := TestFaultData{
+		cfgFault: http2grpc.FaultConfig{
+			Percentage: 100, Methods: []string{"pkg.Service/*"}, AbortCode: 14, AbortMessage: "injected",
+		},
+
+		reqPath: "/pkg.Service/",
+
+		expBackendCalled:     true,
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testFaultRequest(t, data)
+}
+
+func TestFaultMatchingMethodAndHeader(t *testing.T) {
+	data := TestFaultData{
+		cfgFault: http2grpc.FaultConfig{
+			Percentage: 100, Methods: []string{"/pkg.Service/*"}, Headers: map[string]string{"X-Tenant": "test"},
+			AbortCode: 8, AbortMessage: "injected",
+		},
+
+		reqHeaders: map[string]string{"X-Tenant": "test"},
+
+		expBackendCalled:     false,
+		expGrpcResStatusCode: 8,
+		expGrpcResStatusMsg:  "injected",
+	}
+	testFaultRequest(t, data)
+}
+
+func TestFaultNotMatchingHeader(t *testing.T) {
+	data := TestFaultData{
+		cfgFault: http2grpc.FaultConfig{
+			Percentage: 100, Headers: map[string]string{"X-Tenant": "test"}, AbortCode: 8, AbortMessage: "injected",
+		},
+
+		reqHeaders: map[string]string{"X-Tenant": "prod"},
+
+		expBackendCalled:     true,
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testFaultRequest(t, data)
+}
+
+func TestFaultDelay(t *testing.T) {
+	data := TestFaultData{
+		cfgFault: http2grpc.FaultConfig{Percentage: 100, Delay: "30ms"},
+
+		expBackendCalled:     true,
+		expMinDuration:       30 * time.Millisecond,
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testFaultRequest(t, data)
+}
+
+func TestFaultInvalidPercentage(t *testing.T) {
+	cfg := http2grpc.CreateConfig()
+	cfg.FaultInjection.Faults = []http2grpc.FaultConfig{{Percentage: 150, AbortCode: 14}}
+
+	_, err := http2grpc.New(context.Background(), http.NotFoundHandler(), cfg, "http2grpc")
+	if err == nil {
+		t.Errorf("expected error for percentage out of range")
+	}
+}
+
+func testFaultRequest(t *testing.T, data TestFaultData) {
+	t.Helper()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.FaultInjection.Faults = []http2grpc.FaultConfig{data.cfgFault}
+	cfg.FaultInjection.ToggleHeader = data.cfgToggleHeader
+
+	backendCalled := false
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		backendCalled = true
+
+		rw.Header().Set("Content-Type", "application/grpc")
+		rw.Header().Set("Trailer", "grpc-status")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte{0x00, 0x00, 0x00, 0x00, 0x00})
+		rw.Header().Set("grpc-status", "0")
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	reqPath := data.reqPath
+	if reqPath == "" {
+		reqPath = "/pkg.Service/Method"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost"+reqPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, value := range data.reqHeaders {
+		req.Header.Set(key, value)
+	}
+
+	started := time.Now()
+	handler.ServeHTTP(recorder, req)
+	elapsed := time.Since(started)
+	resp := recorder.Result()
+
+	if backendCalled != data.expBackendCalled {
+		t.Errorf("expected backend called: `%t`, got: `%t`", data.expBackendCalled, backendCalled)
+	}
+
+	if elapsed < data.expMinDuration {
+		t.Errorf("expected duration at least `%s`, got: `%s`", data.expMinDuration, elapsed)
+	}
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertBody(t, resp, []byte{0x00, 0x00, 0x00, 0x00, 0x00})
+	assertHeader(t, resp, "Content-Type", "application/grpc")
+	assertTrailer(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+	assertTrailer(t, resp, "grpc-message", data.expGrpcResStatusMsg)
+}
@@ -0,0 +1,250 @@
+package http2grpc
+
+import (
+	"fmt"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	XForwardedForHeaderName    = "X-Forwarded-For"
+	XForwardedMethodHeaderName = "X-Forwarded-Method"
+	XForwardedProtoHeaderName  = "X-Forwarded-Proto"
+	XForwardedHostHeaderName   = "X-Forwarded-Host"
+	XForwardedURIHeaderName    = "X-Forwarded-Uri"
+
+	// maxAuthResponseBodySize limits denying auth response body, which is used only as status message
+	maxAuthResponseBodySize = 64 * 1024
+)
+
+// hopHeaders are not forwarded to auth service and back, like traefik forward auth does.
+//
+//nolint:gochecknoglobals // static list from RFC 7230
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// ForwardAuthConfig describes auth subrequest made by middleware itself,
+// so auth result is converted to gRPC status without separate forward auth middleware.
+type ForwardAuthConfig struct {
+	// Address of auth service, forward auth is disabled if empty
+	Address string `yaml:"address"`
+	// AuthRequestHeaders is the list of request headers (gRPC metadata) copied to auth request,
+	// all headers are copied if empty
+	AuthRequestHeaders []string `yaml:"authRequestHeaders"`
+	// AuthResponseHeaders is the list of auth response headers copied to request to backend
+	AuthResponseHeaders []string `yaml:"authResponseHeaders"`
+	// TrustForwardHeader keeps X-Forwarded-* headers of incoming request
+	TrustForwardHeader bool `yaml:"trustForwardHeader"`
+	// Timeout of auth request as go duration string
+	Timeout string `yaml:"timeout"`
+}
+
+type forwardAuth struct {
+	config *ForwardAuthConfig
+	client *http.Client
+	// retrier retries auth request failed with retryable status under retry policy of method, nil if disabled
+	retrier *retrier
+}
+
+// newForwardAuth returns nil if forward auth is disabled.
+func newForwardAuth(config *ForwardAuthConfig) (*forwardAuth, error) {
+	if config.Address == "" {
+		return nil, nil //nolint:nilnil // disabled feature
+	}
+
+	timeout, err := time.ParseDuration(config.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("ERROR: http2grpc: forwardAuth.timeout: %w", err)
+	}
+
+	return &forwardAuth{
+		config: config,
+		client: &http.Client{
+			Timeout: timeout,
+			// redirect of auth service is an answer for the client, not for middleware
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}, nil
+}
+
+// intercept makes auth subrequest and answers with gRPC status if auth service denies request.
+func (f *forwardAuth) intercept(rw *http2grpcModifier, req *http.Request) bool {
+	authReq, err := http.NewRequestWithContext(req.Context(), http.MethodGet, f.config.Address, nil)
+	if err != nil {
+		LoggerINFO.Printf("forward auth request creation failed: %s", err)
+		rw.writeGrpcStatus(grpc.INTERNAL, "forward auth: request creation failed")
+
+		return true
+	}
+
+	f.writeAuthRequestHeaders(authReq, req)
+
+	var policy *retryPolicy
+	if f.retrier != nil {
+		policy = f.retrier.policy(grpcMethod(req))
+	}
+
+	for attempt := 1; ; attempt++ {
+		authRes, err := f.client.Do(authReq.Clone(req.Context()))
+
+		if policy != nil && attempt < policy.maxAttempts {
+			code, header := f.authResultCode(authRes, err)
+
+			if delay, retry := f.retrier.retryDelay(policy, code, header, attempt); retry {
+				LoggerINFO.Printf("forward auth attempt %d failed with code %d, retrying in %s", attempt, code, delay)
+
+				if waitRetry(req.Context(), delay) {
+					if authRes != nil {
+						authRes.Body.Close()
+					}
+
+					continue
+				}
+			}
+		}
+
+		return f.handleAuthResult(rw, req, authRes, err)
+	}
+}
+
+// handleAuthResult answers with gRPC status if auth request failed or auth service denied request,
+// otherwise copies auth response headers to request to backend.
+func (f *forwardAuth) handleAuthResult(
+	rw *http2grpcModifier, req *http.Request, authRes *http.Response, err error,
+) bool {
+	if err != nil {
+		LoggerINFO.Printf("forward auth request failed: %s", err)
+		rw.writeGrpcStatus(grpc.UNAVAILABLE, "forward auth: service is unavailable")
+
+		return true
+	}
+	defer authRes.Body.Close()
+
+	LoggerDEBUG.Printf("forward auth responded with %d", authRes.StatusCode)
+
+	if isAuthDenied(authRes) {
+		f.replayAuthResponse(rw, authRes)
+		return true
+	}
+
+	for _, name := range f.config.AuthResponseHeaders {
+		values := authRes.Header.Values(name)
+		req.Header.Del(name)
+
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	return false
+}
+
+// authResultCode returns gRPC status code of auth request result and header of denying response,
+// which may have grpc-retry-pushback-ms, body of denying response is not read before retry decision.
+func (f *forwardAuth) authResultCode(authRes *http.Response, err error) (int, http.Header) {
+	if err != nil {
+		return grpc.UNAVAILABLE, nil
+	}
+
+	if !isAuthDenied(authRes) {
+		return grpc.OK, nil
+	}
+
+	return f.retrier.httpCode(authRes.StatusCode, authRes.Header, nil), authRes.Header
+}
+
+func isAuthDenied(authRes *http.Response) bool {
+	return authRes.StatusCode < http.StatusOK || authRes.StatusCode >= http.StatusMultipleChoices
+}
+
+func (f *forwardAuth) writeAuthRequestHeaders(authReq *http.Request, req *http.Request) {
+	if len(f.config.AuthRequestHeaders) == 0 {
+		authReq.Header = req.Header.Clone()
+	} else {
+		for _, name := range f.config.AuthRequestHeaders {
+			for _, value := range req.Header.Values(name) {
+				authReq.Header.Add(name, value)
+			}
+		}
+	}
+
+	for _, name := range hopHeaders {
+		authReq.Header.Del(name)
+	}
+
+	if !f.config.TrustForwardHeader {
+		for _, name := range []string{
+			XForwardedForHeaderName, XForwardedMethodHeaderName, XForwardedProtoHeaderName,
+			XForwardedHostHeaderName, XForwardedURIHeaderName,
+		} {
+			authReq.Header.Del(name)
+		}
+	}
+
+	if clientIP, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		if prior := authReq.Header.Get(XForwardedForHeaderName); prior != "" {
+			clientIP = prior + ", " + clientIP
+		}
+
+		authReq.Header.Set(XForwardedForHeaderName, clientIP)
+	}
+
+	proto := "http"
+	if req.TLS != nil {
+		proto = "https"
+	}
+
+	setIfEmpty(authReq.Header, XForwardedMethodHeaderName, req.Method)
+	setIfEmpty(authReq.Header, XForwardedProtoHeaderName, proto)
+	setIfEmpty(authReq.Header, XForwardedHostHeaderName, req.Host)
+	setIfEmpty(authReq.Header, XForwardedURIHeaderName, req.URL.RequestURI())
+}
+
+// replayAuthResponse writes denying auth response as if backend responded with it,
+// so it is converted to gRPC status by the usual http to gRPC mapping.
+func (f *forwardAuth) replayAuthResponse(rw *http2grpcModifier, authRes *http.Response) {
+	for name, values := range authRes.Header {
+		if strings.EqualFold(name, ContentLengthHeaderName) {
+			continue
+		}
+
+		rw.Header()[name] = values
+	}
+
+	for _, name := range hopHeaders {
+		rw.Header().Del(name)
+	}
+
+	rw.WriteHeader(authRes.StatusCode)
+
+	// body is written at once, because it becomes grpc status message
+	body, err := ioutil.ReadAll(io.LimitReader(authRes.Body, maxAuthResponseBodySize))
+	if err != nil {
+		LoggerINFO.Printf("forward auth response body read failed: %s", err)
+	}
+
+	if _, err = rw.Write(body); err != nil {
+		LoggerDEBUG.Printf("forward auth response body write failed: %s", err)
+	}
+}
+
+func setIfEmpty(header http.Header, key string, value string) {
+	if header.Get(key) == "" {
+		header.Set(key, value)
+	}
+}
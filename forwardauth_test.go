@@ -0,0 +1,253 @@
+package http2grpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/v-electrolux/http2grpc"
+)
+
+type TestForwardAuthData struct {
+	cfgAuthRequestHeaders  []string
+	cfgAuthResponseHeaders []string
+	cfgRetryMaxAttempts    int
+	authServerDown         bool
+	// authFailures is number of the first auth requests answered with 503
+	authFailures int
+
+	authHTTPResStatusCode int
+	authHTTPResHeaders    map[string]string
+	authHTTPResBody       []byte
+
+	expAuthReqHeaders    map[string]string
+	expAuthCalls         int
+	expBackendReqHeaders map[string]string
+	expBackendCalled     bool
+	expGrpcResStatusCode int
+	expGrpcResStatusMsg  string
+}
+
+func TestForwardAuthAllowed(t *testing.T) {
+	data := TestForwardAuthData{
+		cfgAuthResponseHeaders: []string{"X-User-Id"},
+
+		authHTTPResStatusCode: 200,
+		authHTTPResHeaders:    map[string]string{"X-User-Id": "42", "X-Internal": "secret"},
+
+		expAuthReqHeaders: map[string]string{
+			"Authorization":      "Bearer token",
+			"X-Request-Id":       "abc",
+			"X-Trace-Bin":        "AAEC",
+			"Te":                 "",
+			"X-Forwarded-Method": "POST",
+			"X-Forwarded-Uri":    "/pkg.Service/Method",
+			"X-Forwarded-Host":   "grpc.local",
+			"X-Forwarded-Proto":  "http",
+		},
+		expBackendReqHeaders: map[string]string{"X-User-Id": "42", "X-Internal": ""},
+		expBackendCalled:     true,
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testForwardAuthRequest(t, data)
+}
+
+func TestForwardAuthFilteredRequestHeaders(t *testing.T) {
+	data := TestForwardAuthData{
+		cfgAuthRequestHeaders: []string{"Authorization"},
+
+		authHTTPResStatusCode: 204,
+
+		expAuthReqHeaders:    map[string]string{"Authorization": "Bearer token", "X-Request-Id": ""},
+		expBackendCalled:     true,
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testForwardAuthRequest(t, data)
+}
+
+func TestForwardAuthUnauthorized(t *testing.T) {
+	data := TestForwardAuthData{
+		authHTTPResStatusCode: 401,
+		authHTTPResHeaders:    map[string]string{"Content-Type": "text/plain"},
+		authHTTPResBody:       []byte("token expired"),
+
+		expBackendCalled:     false,
+		expGrpcResStatusCode: 16,
+		expGrpcResStatusMsg:  "token expired",
+	}
+	testForwardAuthRequest(t, data)
+}
+
+func TestForwardAuthForbidden(t *testing.T) {
+	data := TestForwardAuthData{
+		authHTTPResStatusCode: 403,
+		authHTTPResBody:       []byte("forbidden"),
+
+		expBackendCalled:     false,
+		expGrpcResStatusCode: 7,
+		expGrpcResStatusMsg:  "forbidden",
+	}
+	testForwardAuthRequest(t, data)
+}
+
+func TestForwardAuthServerDown(t *testing.T) {
+	data := TestForwardAuthData{
+		authServerDown: true,
+
+		expBackendCalled:     false,
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "forward auth: service is unavailable",
+	}
+	testForwardAuthRequest(t, data)
+}
+
+func TestForwardAuthUnavailableRetried(t *testing.T) {
+	data := TestForwardAuthData{
+		cfgRetryMaxAttempts: 3,
+		authFailures:        2,
+
+		authHTTPResStatusCode: 200,
+
+		expAuthCalls:         3,
+		expBackendCalled:     true,
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testForwardAuthRequest(t, data)
+}
+
+func TestForwardAuthUnavailableRetriesExhausted(t *testing.T) {
+	data := TestForwardAuthData{
+		cfgRetryMaxAttempts: 2,
+		authFailures:        5,
+
+		authHTTPResStatusCode: 200,
+
+		expAuthCalls:         2,
+		expBackendCalled:     false,
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "auth storage is down",
+	}
+	testForwardAuthRequest(t, data)
+}
+
+func TestForwardAuthDeniedNotRetried(t *testing.T) {
+	data := TestForwardAuthData{
+		cfgRetryMaxAttempts: 3,
+
+		authHTTPResStatusCode: 403,
+		authHTTPResBody:       []byte("access denied"),
+
+		expAuthCalls:         1,
+		expBackendCalled:     false,
+		expGrpcResStatusCode: 7,
+		expGrpcResStatusMsg:  "access denied",
+	}
+	testForwardAuthRequest(t, data)
+}
+
+func testForwardAuthRequest(t *testing.T, data TestForwardAuthData) {
+	t.Helper()
+
+	authCalls := 0
+	authServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		authCalls++
+		if authCalls <= data.authFailures {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			rw.Write([]byte("auth storage is down"))
+
+			return
+		}
+
+		for key, expected := range data.expAuthReqHeaders {
+			if got := req.Header.Get(key); got != expected {
+				t.Errorf("expected auth request header %s value: `%s`, got value: `%s`", key, expected, got)
+			}
+		}
+
+		for key, value := range data.authHTTPResHeaders {
+			rw.Header().Set(key, value)
+		}
+
+		rw.WriteHeader(data.authHTTPResStatusCode)
+		rw.Write(data.authHTTPResBody)
+	}))
+	defer authServer.Close()
+
+	if data.authServerDown {
+		authServer.Close()
+	}
+
+	cfg := http2grpc.CreateConfig()
+	cfg.BodyAsStatusMessage = true
+	cfg.ForwardAuth.Address = authServer.URL
+	cfg.ForwardAuth.AuthRequestHeaders = data.cfgAuthRequestHeaders
+	cfg.ForwardAuth.AuthResponseHeaders = data.cfgAuthResponseHeaders
+
+	if data.cfgRetryMaxAttempts != 0 {
+		cfg.Retry.Policies = []http2grpc.RetryPolicyConfig{{
+			Methods:           []string{"/pkg.Service/*"},
+			MaxAttempts:       data.cfgRetryMaxAttempts,
+			InitialBackoff:    "1ms",
+			MaxBackoff:        "1ms",
+			BackoffMultiplier: 1,
+		}}
+	}
+
+	backendCalled := false
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		backendCalled = true
+
+		for key, expected := range data.expBackendReqHeaders {
+			if got := req.Header.Get(key); got != expected {
+				t.Errorf("expected backend request header %s value: `%s`, got value: `%s`", key, expected, got)
+			}
+		}
+
+		rw.Header().Set("Content-Type", "application/grpc")
+		rw.Header().Set("Trailer", "grpc-status")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte{0x00, 0x00, 0x00, 0x00, 0x00})
+		rw.Header().Set("grpc-status", "0")
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://grpc.local/pkg.Service/Method", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("X-Request-Id", "abc")
+	req.Header.Set("X-Trace-Bin", "AAEC")
+
+	handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+
+	if backendCalled != data.expBackendCalled {
+		t.Errorf("expected backend called: `%t`, got: `%t`", data.expBackendCalled, backendCalled)
+	}
+
+	if data.expAuthCalls != 0 && authCalls != data.expAuthCalls {
+		t.Errorf("expected auth calls: `%d`, got: `%d`", data.expAuthCalls, authCalls)
+	}
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertBody(t, resp, []byte{0x00, 0x00, 0x00, 0x00, 0x00})
+	assertHeader(t, resp, "Content-Type", "application/grpc")
+	assertTrailer(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+	assertTrailer(t, resp, "grpc-message", data.expGrpcResStatusMsg)
+}
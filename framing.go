@@ -0,0 +1,153 @@
+package http2grpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"io"
+)
+
+const (
+	// GrpcFramePrefixLength 1 byte for Compressed-Flag and 4 bytes for Message-Length
+	// from gRPC spec https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md
+	GrpcFramePrefixLength = 5
+
+	grpcFrameNotCompressed = 0x00
+	grpcFrameCompressed    = 0x01
+)
+
+// grpcFrameParser tracks length-prefixed messages across arbitrary split writes.
+type grpcFrameParser struct {
+	// prefix accumulates message prefix bytes until it is complete
+	prefix    [GrpcFramePrefixLength]byte
+	prefixLen int
+	// remaining is count of message bytes not received yet for the current message
+	remaining uint32
+	// compressionAllowed is whether the stream declares grpc-encoding other than identity
+	compressionAllowed bool
+	// maxMessageSize is max allowed length of one message, 0 means unlimited
+	maxMessageSize uint32
+}
+
+func newGrpcFrameParser(encoding string, maxMessageSize int) *grpcFrameParser {
+	parser := &grpcFrameParser{
+		compressionAllowed: encoding != "" && encoding != "identity",
+		maxMessageSize:     0,
+	}
+
+	if maxMessageSize > 0 {
+		parser.maxMessageSize = uint32(maxMessageSize)
+	}
+
+	return parser
+}
+
+// parse consumes buf and returns bytes which are safe to forward.
+// Prefix bytes are held back until the whole prefix is received and validated,
+// so on violation the partial prefix never reaches the client.
+func (p *grpcFrameParser) parse(buf []byte) ([]byte, *grpcStatusError) {
+	out := make([]byte, 0, len(buf)+GrpcFramePrefixLength)
+
+	for len(buf) > 0 {
+		if p.remaining > 0 {
+			count := uint32(len(buf))
+			if count > p.remaining {
+				count = p.remaining
+			}
+
+			out = append(out, buf[:count]...)
+			buf = buf[count:]
+			p.remaining -= count
+
+			continue
+		}
+
+		count := copy(p.prefix[p.prefixLen:], buf)
+		buf = buf[count:]
+		p.prefixLen += count
+
+		if p.prefixLen < GrpcFramePrefixLength {
+			break
+		}
+
+		if err := p.validatePrefix(); err != nil {
+			return out, err
+		}
+
+		out = append(out, p.prefix[:]...)
+		p.remaining = binary.BigEndian.Uint32(p.prefix[1:])
+		p.prefixLen = 0
+	}
+
+	return out, nil
+}
+
+// incomplete is whether the stream ended in the middle of a message.
+func (p *grpcFrameParser) incomplete() bool {
+	return p.prefixLen > 0 || p.remaining > 0
+}
+
+func (p *grpcFrameParser) validatePrefix() *grpcStatusError {
+	switch p.prefix[0] {
+	case grpcFrameNotCompressed:
+	case grpcFrameCompressed:
+		if !p.compressionAllowed {
+			return &grpcStatusError{
+				code:    grpc.INTERNAL,
+				message: "grpc: compressed flag set with identity or empty grpc-encoding",
+			}
+		}
+	default:
+		return &grpcStatusError{
+			code:    grpc.INTERNAL,
+			message: fmt.Sprintf("grpc: invalid compressed flag %#x in message prefix", p.prefix[0]),
+		}
+	}
+
+	length := binary.BigEndian.Uint32(p.prefix[1:])
+	if p.maxMessageSize > 0 && length > p.maxMessageSize {
+		return &grpcStatusError{
+			code:    grpc.RESOURCE_EXHAUSTED,
+			message: fmt.Sprintf("grpc: received message larger than max (%d vs. %d)", length, p.maxMessageSize),
+		}
+	}
+
+	return nil
+}
+
+// appendGrpcFrame appends not compressed message with length prefix.
+func appendGrpcFrame(b []byte, message []byte) []byte {
+	var prefix [GrpcFramePrefixLength]byte
+
+	prefix[0] = grpcFrameNotCompressed
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(message)))
+
+	b = append(b, prefix[:]...)
+
+	return append(b, message...)
+}
+
+// readGrpcMessage reads the first not compressed length-prefixed message of request body.
+func readGrpcMessage(body io.Reader, maxMessageSize int) ([]byte, error) {
+	var prefix [GrpcFramePrefixLength]byte
+	if _, err := io.ReadFull(body, prefix[:]); err != nil {
+		return nil, fmt.Errorf("grpc: message prefix read: %w", err)
+	}
+
+	if prefix[0] != grpcFrameNotCompressed {
+		return nil, errors.New("grpc: compressed request messages are not supported")
+	}
+
+	length := binary.BigEndian.Uint32(prefix[1:])
+	if maxMessageSize > 0 && length > uint32(maxMessageSize) {
+		return nil, fmt.Errorf("grpc: received message larger than max (%d vs. %d)", length, maxMessageSize)
+	}
+
+	message := make([]byte, length)
+	if _, err := io.ReadFull(body, message); err != nil {
+		return nil, fmt.Errorf("grpc: message read: %w", err)
+	}
+
+	return message, nil
+}
@@ -0,0 +1,178 @@
+package http2grpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/v-electrolux/http2grpc"
+)
+
+type TestFramingData struct {
+	cfgMaxResponseMessageSize int
+
+	backendGrpcEncoding      string
+	backendGrpcResBodyChunks [][]byte
+
+	expGrpcResStatusCode int
+	expGrpcResStatusMsg  string
+	expGrpcResBody       []byte
+}
+
+func TestValidFramingSplitAcrossWrites(t *testing.T) {
+	data := TestFramingData{
+		backendGrpcResBodyChunks: [][]byte{
+			{0x00, 0x00},
+			{0x00, 0x00, 0x03, 'a'},
+			{'b', 'c', 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+		expGrpcResBody:       []byte{0x00, 0x00, 0x00, 0x00, 0x03, 'a', 'b', 'c', 0x00, 0x00, 0x00, 0x00, 0x00},
+	}
+	testFramingRequest(t, data)
+}
+
+func TestPlainTextBodyWithGrpcContentType(t *testing.T) {
+	data := TestFramingData{
+		backendGrpcResBodyChunks: [][]byte{[]byte("upstream connect error")},
+
+		expGrpcResStatusCode: 13,
+		expGrpcResStatusMsg:  "grpc: invalid compressed flag 0x75 in message prefix",
+		expGrpcResBody:       []byte{},
+	}
+	testFramingRequest(t, data)
+}
+
+func TestCompressedFlagWithoutGrpcEncoding(t *testing.T) {
+	data := TestFramingData{
+		backendGrpcResBodyChunks: [][]byte{
+			{0x00, 0x00, 0x00, 0x00, 0x01, 'a'},
+			{0x01, 0x00, 0x00, 0x00, 0x01, 'b'},
+		},
+
+		expGrpcResStatusCode: 13,
+		expGrpcResStatusMsg:  "grpc: compressed flag set with identity or empty grpc-encoding",
+		expGrpcResBody:       []byte{0x00, 0x00, 0x00, 0x00, 0x01, 'a'},
+	}
+	testFramingRequest(t, data)
+}
+
+func TestCompressedFlagWithGrpcEncoding(t *testing.T) {
+	data := TestFramingData{
+		backendGrpcEncoding:      "gzip",
+		backendGrpcResBodyChunks: [][]byte{{0x01, 0x00, 0x00, 0x00, 0x01, 'b'}},
+
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+		expGrpcResBody:       []byte{0x01, 0x00, 0x00, 0x00, 0x01, 'b'},
+	}
+	testFramingRequest(t, data)
+}
+
+func TestMessageLargerThanMax(t *testing.T) {
+	data := TestFramingData{
+		cfgMaxResponseMessageSize: 2,
+
+		backendGrpcResBodyChunks: [][]byte{
+			{0x00, 0x00, 0x00, 0x00, 0x02, 'a', 'b'},
+			{0x00, 0x00, 0x00, 0x00, 0x03, 'a', 'b', 'c'},
+		},
+
+		expGrpcResStatusCode: 8,
+		expGrpcResStatusMsg:  "grpc: received message larger than max (3 vs. 2)",
+		expGrpcResBody:       []byte{0x00, 0x00, 0x00, 0x00, 0x02, 'a', 'b'},
+	}
+	testFramingRequest(t, data)
+}
+
+func TestTruncatedMessage(t *testing.T) {
+	data := TestFramingData{
+		backendGrpcResBodyChunks: [][]byte{{0x00, 0x00, 0x00, 0x00, 0x05, 'a', 'b'}},
+
+		expGrpcResStatusCode: 13,
+		expGrpcResStatusMsg:  "grpc: response stream ended in the middle of a message",
+		expGrpcResBody:       []byte{0x00, 0x00, 0x00, 0x00, 0x05, 'a', 'b'},
+	}
+	testFramingRequest(t, data)
+}
+
+func testFramingRequest(t *testing.T, data TestFramingData) {
+	t.Helper()
+
+	t.Run("true", func(t *testing.T) {
+		testFramingRequestWithTrailers(t, data, true)
+	})
+	t.Run("false", func(t *testing.T) {
+		testFramingRequestWithTrailers(t, data, false)
+	})
+}
+
+func testFramingRequestWithTrailers(t *testing.T, data TestFramingData, httpTrailerPredeclare bool) {
+	t.Helper()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.ValidateResponseFraming = true
+	cfg.MaxResponseMessageSize = data.cfgMaxResponseMessageSize
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/grpc")
+		if data.backendGrpcEncoding != "" {
+			rw.Header().Set("grpc-encoding", data.backendGrpcEncoding)
+		}
+
+		if httpTrailerPredeclare {
+			rw.Header().Set("Trailer", "grpc-status")
+			rw.Header().Add("Trailer", "grpc-message")
+		}
+
+		rw.WriteHeader(http.StatusOK)
+
+		for _, chunk := range data.backendGrpcResBodyChunks {
+			rw.Write(chunk)
+		}
+
+		if httpTrailerPredeclare {
+			rw.Header().Set("grpc-status", strconv.Itoa(0))
+			rw.Header().Set("grpc-message", "")
+		} else {
+			rw.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(0))
+			rw.Header().Set(http.TrailerPrefix+"Grpc-Message", "")
+		}
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertBody(t, resp, data.expGrpcResBody)
+	assertHeader(t, resp, "Content-Type", "application/grpc")
+	assertTrailer(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+	assertTrailer(t, resp, "grpc-message", data.expGrpcResStatusMsg)
+	assertTrailerValuesCount(t, resp, "grpc-status", 1)
+}
+
+func assertTrailerValuesCount(t *testing.T, res *http.Response, key string, expected int) {
+	t.Helper()
+
+	got := len(res.Trailer.Values(key))
+	if got != expected {
+		t.Errorf("expected trailer %s values count: `%d`, got: `%d`", key, expected, got)
+	}
+}
@@ -0,0 +1,106 @@
+package grpc
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Code is gRPC status code, constants of this package are untyped, so they are both Code and int.
+type Code int
+
+// codeNames are names of codes from gRPC spec, index is the code.
+//
+//nolint:gochecknoglobals // static table from gRPC spec
+var codeNames = []string{
+	"OK",
+	"CANCELLED",
+	"UNKNOWN",
+	"INVALID_ARGUMENT",
+	"DEADLINE_EXCEEDED",
+	"NOT_FOUND",
+	"ALREADY_EXISTS",
+	"PERMISSION_DENIED",
+	"RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION",
+	"ABORTED",
+	"OUT_OF_RANGE",
+	"UNIMPLEMENTED",
+	"INTERNAL",
+	"UNAVAILABLE",
+	"DATA_LOSS",
+	"UNAUTHENTICATED",
+}
+
+// codeDescriptions are descriptions of codes from gRPC spec, index is the code.
+//
+//nolint:gochecknoglobals // static table from gRPC spec
+var codeDescriptions = []string{
+	"Not an error; returned on success.",
+	"The operation was cancelled, typically by the caller.",
+	"Unknown error.",
+	"The client specified an invalid argument.",
+	"The deadline expired before the operation could complete.",
+	"Some requested entity (e.g., file or directory) was not found.",
+	"The entity that a client attempted to create (e.g., file or directory) already exists.",
+	"The caller does not have permission to execute the specified operation.",
+	"Some resource has been exhausted, perhaps a per-user quota, or perhaps the entire file system is out of space.",
+	"The operation was rejected because the system is not in a state required for the operation's execution.",
+	"The operation was aborted, typically due to a concurrency issue such as a sequencer check failure " +
+		"or transaction abort.",
+	"The operation was attempted past the valid range.",
+	"The operation is not implemented or is not supported/enabled in this service.",
+	"Internal errors.",
+	"The service is currently unavailable.",
+	"Unrecoverable data loss or corruption.",
+	"The request does not have valid authentication credentials for the operation.",
+}
+
+// String returns name of code from gRPC spec, like NOT_FOUND, or Code(N) for unknown code.
+func (c Code) String() string {
+	if c.valid() {
+		return codeNames[c]
+	}
+
+	return "Code(" + strconv.Itoa(int(c)) + ")"
+}
+
+// Description returns description of code from gRPC spec, empty for unknown code.
+func (c Code) Description() string {
+	if c.valid() {
+		return codeDescriptions[c]
+	}
+
+	return ""
+}
+
+// HTTPStatus returns HTTP status code matching gRPC code by GRPC2HTTP map, 500 for unknown code.
+func (c Code) HTTPStatus() int {
+	if status, ok := GRPC2HTTP[int(c)]; ok {
+		return status
+	}
+
+	return http.StatusInternalServerError
+}
+
+func (c Code) valid() bool {
+	return c >= OK && c <= UNAUTHENTICATED
+}
+
+// ParseCode parses code by its name in any case, like not_found, or by its number, like 5.
+func ParseCode(s string) (Code, error) {
+	s = strings.TrimSpace(s)
+
+	for code, name := range codeNames {
+		if strings.EqualFold(s, name) {
+			return Code(code), nil
+		}
+	}
+
+	if number, err := strconv.Atoi(s); err == nil && Code(number).valid() {
+		return Code(number), nil
+	}
+
+	return 0, fmt.Errorf("grpc: %q is not gRPC status code", s)
+}
@@ -38,4 +38,26 @@ var (
 		http.StatusGatewayTimeout:     UNAVAILABLE,
 		// if other, code must be UNKNOWN
 	}
+
+	// GRPC2HTTP map of gRPC codes to HTTP status codes based on google/rpc/code.proto,
+	// it is used by gateways which convert gRPC status to HTTP response
+	GRPC2HTTP = map[int]int{ //nolint:gochecknoglobals // static map from googleapis
+		OK:                  http.StatusOK,
+		CANCELLED:           499, // client closed request, there is no constant in net/http
+		UNKNOWN:             http.StatusInternalServerError,
+		INVALID_ARGUMENT:    http.StatusBadRequest,
+		DEADLINE_EXCEEDED:   http.StatusGatewayTimeout,
+		NOT_FOUND:           http.StatusNotFound,
+		ALREADY_EXISTS:      http.StatusConflict,
+		PERMISSION_DENIED:   http.StatusForbidden,
+		RESOURCE_EXHAUSTED:  http.StatusTooManyRequests,
+		FAILED_PRECONDITION: http.StatusBadRequest,
+		ABORTED:             http.StatusConflict,
+		OUT_OF_RANGE:        http.StatusBadRequest,
+		UNIMPLEMENTED:       http.StatusNotImplemented,
+		INTERNAL:            http.StatusInternalServerError,
+		UNAVAILABLE:         http.StatusServiceUnavailable,
+		DATA_LOSS:           http.StatusInternalServerError,
+		UNAUTHENTICATED:     http.StatusUnauthorized,
+	}
 )
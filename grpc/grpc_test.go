@@ -0,0 +1,170 @@
+package grpc_test
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/v-electrolux/http2grpc/grpc"
+)
+
+type TestParseCodeData struct {
+	value string
+
+	expErr  bool
+	expCode grpc.Code
+	expName string
+}
+
+func TestParseCodeName(t *testing.T) {
+	data := TestParseCodeData{value: "not_found", expCode: grpc.NOT_FOUND, expName: "NOT_FOUND"}
+	testParseCode(t, data)
+}
+
+func TestParseCodeNumber(t *testing.T) {
+	data := TestParseCodeData{value: " 16 ", expCode: grpc.UNAUTHENTICATED, expName: "UNAUTHENTICATED"}
+	testParseCode(t, data)
+}
+
+func TestParseCodeOutOfRange(t *testing.T) {
+	data := TestParseCodeData{value: "17", expErr: true}
+	testParseCode(t, data)
+}
+
+func TestParseCodeUnknownName(t *testing.T) {
+	data := TestParseCodeData{value: "NotFound", expErr: true}
+	testParseCode(t, data)
+}
+
+func testParseCode(t *testing.T, data TestParseCodeData) {
+	t.Helper()
+
+	code, err := grpc.ParseCode(data.value)
+	if data.expErr {
+		if err == nil {
+			t.Errorf("expected error for %q, got code %s", data.value, code)
+		}
+
+		return
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code != data.expCode || code.String() != data.expName {
+		t.Errorf("expected code %d %s, got %d %s", data.expCode, data.expName, code, code)
+	}
+}
+
+func TestCodeProperties(t *testing.T) {
+	if got := grpc.Code(42).String(); got != "Code(42)" {
+		t.Errorf("expected Code(42), got %s", got)
+	}
+
+	if got := grpc.Code(grpc.UNAVAILABLE).Description(); got != "The service is currently unavailable." {
+		t.Errorf("unexpected UNAVAILABLE description %q", got)
+	}
+
+	for code, status := range map[grpc.Code]int{
+		grpc.OK:                 http.StatusOK,
+		grpc.CANCELLED:          499,
+		grpc.RESOURCE_EXHAUSTED: http.StatusTooManyRequests,
+		grpc.UNAUTHENTICATED:    http.StatusUnauthorized,
+		grpc.Code(42):           http.StatusInternalServerError,
+	} {
+		if got := code.HTTPStatus(); got != status {
+			t.Errorf("expected HTTP status %d of %s, got %d", status, code, got)
+		}
+	}
+}
+
+func TestMessageEncoding(t *testing.T) {
+	message := "доступ 100% запрещён\n"
+	encoded := grpc.EncodeMessage(message)
+
+	expected := "%D0%B4%D0%BE%D1%81%D1%82%D1%83%D0%BF 100%25 %D0%B7%D0%B0%D0%BF%D1%80%D0%B5%D1%89%D1%91%D0%BD%0A"
+	if encoded != expected {
+		t.Errorf("expected encoded message %q, got %q", expected, encoded)
+	}
+
+	if decoded := grpc.DecodeMessage(encoded); decoded != message {
+		t.Errorf("expected decoded message %q, got %q", message, decoded)
+	}
+
+	if decoded := grpc.DecodeMessage("bad %zz and %4"); decoded != "bad %zz and %4" {
+		t.Errorf("expected invalid sequences kept, got %q", decoded)
+	}
+}
+
+type TestStatusHeaderData struct {
+	header http.Header
+
+	expErr    error
+	expStatus *grpc.Status
+}
+
+func TestStatusTrailersRoundTrip(t *testing.T) {
+	header := http.Header{"Trailer": []string{"grpc-status, grpc-message"}}
+	status := &grpc.Status{
+		Code:    grpc.PERMISSION_DENIED,
+		Message: "нет",
+		Details: []grpc.Any{{TypeURL: "type.googleapis.com/google.rpc.ErrorInfo", Value: []byte{0x0a, 0x01, 0x41}}},
+	}
+	status.SetTrailers(header)
+
+	if header.Get("grpc-message") != "%D0%BD%D0%B5%D1%82" {
+		t.Errorf("expected predeclared percent-encoded grpc-message, got header %v", header)
+	}
+
+	if header.Get(http.TrailerPrefix+"grpc-status-details-bin") == "" {
+		t.Errorf("expected not predeclared grpc-status-details-bin with trailer prefix, got header %v", header)
+	}
+
+	data := TestStatusHeaderData{header: header, expStatus: status}
+	testStatusFromHeader(t, data)
+}
+
+func TestStatusFromTrailersOnlyWithPaddedDetails(t *testing.T) {
+	data := TestStatusHeaderData{
+		header: http.Header{
+			"Grpc-Status":             []string{"5"},
+			"Grpc-Status-Details-Bin": []string{"CAUSBG5vbmUaCgoBdBIFdmFsdWU="},
+		},
+		expStatus: &grpc.Status{
+			Code:    grpc.NOT_FOUND,
+			Details: []grpc.Any{{TypeURL: "t", Value: []byte("value")}},
+		},
+	}
+	testStatusFromHeader(t, data)
+}
+
+func TestStatusFromHeaderWithoutStatus(t *testing.T) {
+	data := TestStatusHeaderData{
+		header: http.Header{"Grpc-Message": []string{"orphan"}},
+		expErr: grpc.ErrNoStatus,
+	}
+	testStatusFromHeader(t, data)
+}
+
+func testStatusFromHeader(t *testing.T, data TestStatusHeaderData) {
+	t.Helper()
+
+	status, err := grpc.FromHeader(data.header)
+	if data.expErr != nil {
+		if !errors.Is(err, data.expErr) {
+			t.Errorf("expected error %v, got %v", data.expErr, err)
+		}
+
+		return
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(status, data.expStatus) {
+		t.Errorf("expected status %+v, got %+v", data.expStatus, status)
+	}
+}
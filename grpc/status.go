@@ -0,0 +1,202 @@
+package grpc
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/v-electrolux/http2grpc/internal/pb"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	StatusHeaderName        = "grpc-status"
+	MessageHeaderName       = "grpc-message"
+	StatusDetailsHeaderName = "grpc-status-details-bin"
+
+	trailerHeaderName = "Trailer"
+)
+
+// ErrNoStatus is returned by FromHeader if there is no grpc-status in header.
+var ErrNoStatus = errors.New("grpc: no grpc-status in header") //nolint:gochecknoglobals // sentinel error
+
+// Any is google.protobuf.Any, one of error details of Status.
+type Any struct {
+	// TypeURL is like type.googleapis.com/google.rpc.ErrorInfo
+	TypeURL string
+	// Value is message encoded in protobuf wire format
+	Value []byte
+}
+
+// Status is result of gRPC call, it is sent in grpc-status, grpc-message and grpc-status-details-bin trailers.
+type Status struct {
+	Code    Code
+	Message string
+	// Details are error details of google.rpc.Status
+	Details []Any
+}
+
+// NewStatus returns status without details.
+func NewStatus(code Code, message string) *Status {
+	return &Status{Code: code, Message: message}
+}
+
+// Error implements error, so not OK status can be returned as error.
+func (s *Status) Error() string {
+	return fmt.Sprintf("grpc: code = %s desc = %s", s.Code, s.Message)
+}
+
+// SetTrailers sets status to header as trailers, see SetTrailer,
+// grpc-status-details-bin is set only if there are details.
+func (s *Status) SetTrailers(header http.Header) {
+	SetTrailer(header, StatusHeaderName, strconv.Itoa(int(s.Code)))
+	SetTrailer(header, MessageHeaderName, EncodeMessage(s.Message))
+
+	if len(s.Details) == 0 {
+		return
+	}
+
+	status := &pb.Status{Code: int32(s.Code), Message: s.Message, Details: make([]*pb.Any, 0, len(s.Details))}
+	for _, detail := range s.Details {
+		status.Details = append(status.Details, &pb.Any{TypeURL: detail.TypeURL, Value: detail.Value})
+	}
+
+	// binary header values are base64 encoded without padding as gRPC implementations do
+	SetTrailer(header, StatusDetailsHeaderName, base64.RawStdEncoding.EncodeToString(status.Marshal()))
+}
+
+// FromHeader reads status from header of trailers-only response, from trailers of response
+// or from header of handler with trailers set either by name or with http.TrailerPrefix.
+// Details are read from grpc-status-details-bin, its code and message are ignored as gRPC implementations do.
+func FromHeader(header http.Header) (*Status, error) {
+	if !HasTrailer(header, StatusHeaderName) {
+		return nil, ErrNoStatus
+	}
+
+	code, err := strconv.Atoi(strings.TrimSpace(Trailer(header, StatusHeaderName)))
+	if err != nil {
+		return nil, fmt.Errorf("grpc: invalid grpc-status %q", Trailer(header, StatusHeaderName))
+	}
+
+	status := &Status{Code: Code(code), Message: DecodeMessage(Trailer(header, MessageHeaderName))}
+
+	encoded := Trailer(header, StatusDetailsHeaderName)
+	if encoded == "" {
+		return status, nil
+	}
+
+	details, err := decodeBinaryHeader(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("grpc: invalid grpc-status-details-bin: %w", err)
+	}
+
+	var decoded pb.Status
+	if err = decoded.Unmarshal(details); err != nil {
+		return nil, fmt.Errorf("grpc: invalid grpc-status-details-bin: %w", err)
+	}
+
+	for _, detail := range decoded.Details {
+		status.Details = append(status.Details, Any{TypeURL: detail.TypeURL, Value: detail.Value})
+	}
+
+	return status, nil
+}
+
+// decodeBinaryHeader decodes -bin header, which is base64 with or without padding by gRPC spec.
+func decodeBinaryHeader(value string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+// EncodeMessage percent-encodes grpc-message by gRPC spec: bytes outside of printable ASCII and '%' are encoded.
+func EncodeMessage(message string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// DecodeMessage decodes percent-encoded grpc-message, invalid sequences are kept as is.
+func DecodeMessage(message string) string {
+	if !strings.Contains(message, "%") {
+		return message
+	}
+
+	b := make([]byte, 0, len(message))
+
+	for i := 0; i < len(message); i++ {
+		if message[i] == '%' && i+2 < len(message) {
+			if c, err := strconv.ParseUint(message[i+1:i+3], 16, 8); err == nil {
+				b = append(b, byte(c))
+				i += 2
+
+				continue
+			}
+		}
+
+		b = append(b, message[i])
+	}
+
+	return string(b)
+}
+
+// HasTrailer is whether key is set as header, predeclared trailer or trailer with http.TrailerPrefix.
+func HasTrailer(header http.Header, key string) bool {
+	if header.Get(key) != "" {
+		return true
+	}
+
+	for name, values := range header {
+		if strings.EqualFold(name, http.TrailerPrefix+key) && len(values) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Trailer returns value of header or trailer set either by name or with http.TrailerPrefix.
+func Trailer(header http.Header, key string) string {
+	if value := header.Get(key); value != "" {
+		return value
+	}
+
+	for name, values := range header {
+		if strings.EqualFold(name, http.TrailerPrefix+key) && len(values) > 0 {
+			return values[0]
+		}
+	}
+
+	return ""
+}
+
+// SetTrailer sets trailer either by name if it is predeclared in Trailer header,
+// or with http.TrailerPrefix if it is not.
+func SetTrailer(header http.Header, key string, value string) {
+	// http.TrailerPrefix keys are not canonicalized, so they could be set in any case
+	for name := range header {
+		if strings.EqualFold(name, http.TrailerPrefix+key) {
+			delete(header, name)
+		}
+	}
+
+	for _, declared := range header.Values(trailerHeaderName) {
+		for _, name := range strings.Split(declared, ",") {
+			if strings.EqualFold(strings.TrimSpace(name), key) {
+				header.Set(key, value)
+
+				return
+			}
+		}
+	}
+
+	header.Set(http.TrailerPrefix+key, value)
+}
@@ -0,0 +1,184 @@
+package http2grpc
+
+import (
+	"fmt"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"github.com/v-electrolux/http2grpc/internal/pb"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	HealthCheckMethod = "/grpc.health.v1.Health/Check"
+	HealthWatchMethod = "/grpc.health.v1.Health/Watch"
+
+	// maxHealthCheckRequestSize limits HealthCheckRequest, which has only service name
+	maxHealthCheckRequestSize = 4 * 1024
+)
+
+// HealthConfig describes synthesized grpc.health.v1.Health service,
+// which is answered by middleware from HTTP health endpoint of backend.
+type HealthConfig struct {
+	// ProbeURL is HTTP health endpoint, 2xx means SERVING, health service is not synthesized if empty
+	ProbeURL string `yaml:"probeURL"`
+	// Services are known service names, any service is known if empty,
+	// empty service name (the whole server) is always known
+	Services []string `yaml:"services"`
+	// Timeout of probe request as go duration string
+	Timeout string `yaml:"timeout"`
+	// WatchInterval is probe polling interval of Watch as go duration string
+	WatchInterval string `yaml:"watchInterval"`
+}
+
+type healthService struct {
+	config        *HealthConfig
+	services      map[string]bool
+	client        *http.Client
+	watchInterval time.Duration
+}
+
+// newHealthService returns nil if health service is not synthesized.
+func newHealthService(config *HealthConfig) (*healthService, error) {
+	if config.ProbeURL == "" {
+		return nil, nil //nolint:nilnil // disabled feature
+	}
+
+	timeout, err := time.ParseDuration(config.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("ERROR: http2grpc: health.timeout: %w", err)
+	}
+
+	watchInterval, err := time.ParseDuration(config.WatchInterval)
+	if err != nil {
+		return nil, fmt.Errorf("ERROR: http2grpc: health.watchInterval: %w", err)
+	}
+
+	if watchInterval <= 0 {
+		return nil, fmt.Errorf("ERROR: http2grpc: health.watchInterval must be positive, got %s", watchInterval)
+	}
+
+	services := make(map[string]bool, len(config.Services))
+	for _, service := range config.Services {
+		services[service] = true
+	}
+
+	return &healthService{
+		config:        config,
+		services:      services,
+		client:        &http.Client{Timeout: timeout},
+		watchInterval: watchInterval,
+	}, nil
+}
+
+// intercept answers Health/Check and Health/Watch, other methods are passed to backend.
+func (s *healthService) intercept(rw *http2grpcModifier, req *http.Request) bool {
+	method := grpcMethod(req)
+	if method != HealthCheckMethod && method != HealthWatchMethod {
+		return false
+	}
+
+	message, err := readGrpcMessage(req.Body, maxHealthCheckRequestSize)
+	if err != nil {
+		rw.writeGrpcStatus(grpc.INTERNAL, fmt.Sprintf("health: %s", err))
+		return true
+	}
+
+	healthReq := &pb.HealthCheckRequest{}
+	if err = healthReq.Unmarshal(message); err != nil {
+		rw.writeGrpcStatus(grpc.INTERNAL, fmt.Sprintf("health: request decode: %s", err))
+		return true
+	}
+
+	LoggerDEBUG.Printf("health: %s for service %q", method, healthReq.Service)
+
+	if method == HealthCheckMethod {
+		s.check(rw, req, healthReq.Service)
+	} else {
+		s.watch(rw, req, healthReq.Service)
+	}
+
+	return true
+}
+
+func (s *healthService) check(rw *http2grpcModifier, req *http.Request, service string) {
+	if !s.known(service) {
+		rw.writeGrpcStatus(grpc.NOT_FOUND, "unknown service")
+		return
+	}
+
+	status := s.probe(req)
+	if err := rw.writeGrpcMessage((&pb.HealthCheckResponse{Status: status}).Marshal()); err != nil {
+		LoggerDEBUG.Printf("health: response write failed: %s", err)
+		return
+	}
+
+	setGrpcStatusTrailers(rw.Header(), grpc.OK, "")
+}
+
+// watch polls probe and sends status on every change until client cancels the call.
+func (s *healthService) watch(rw *http2grpcModifier, req *http.Request, service string) {
+	ticker := time.NewTicker(s.watchInterval)
+	defer ticker.Stop()
+
+	lastStatus := pb.ServingStatus(-1)
+
+	for {
+		status := pb.ServingStatusServiceUnknown
+		if s.known(service) {
+			status = s.probe(req)
+		}
+
+		// failed probe of canceled call is not a status change
+		if req.Context().Err() != nil {
+			setGrpcStatusTrailers(rw.Header(), grpc.CANCELLED, "health: watch canceled")
+			return
+		}
+
+		if status != lastStatus {
+			if err := rw.writeGrpcMessage((&pb.HealthCheckResponse{Status: status}).Marshal()); err != nil {
+				LoggerDEBUG.Printf("health: watch response write failed: %s", err)
+				return
+			}
+
+			lastStatus = status
+		}
+
+		select {
+		case <-ticker.C:
+		case <-req.Context().Done():
+			setGrpcStatusTrailers(rw.Header(), grpc.CANCELLED, "health: watch canceled")
+			return
+		}
+	}
+}
+
+func (s *healthService) known(service string) bool {
+	return service == "" || len(s.services) == 0 || s.services[service]
+}
+
+func (s *healthService) probe(req *http.Request) pb.ServingStatus {
+	probeReq, err := http.NewRequestWithContext(req.Context(), http.MethodGet, s.config.ProbeURL, nil)
+	if err != nil {
+		LoggerINFO.Printf("health: probe request creation failed: %s", err)
+		return pb.ServingStatusNotServing
+	}
+
+	probeRes, err := s.client.Do(probeReq)
+	if err != nil {
+		LoggerDEBUG.Printf("health: probe failed: %s", err)
+		return pb.ServingStatusNotServing
+	}
+	defer probeRes.Body.Close()
+
+	// drain body to reuse connection
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(probeRes.Body, maxHealthCheckRequestSize))
+
+	if probeRes.StatusCode < http.StatusOK || probeRes.StatusCode >= http.StatusMultipleChoices {
+		LoggerDEBUG.Printf("health: probe responded with %d", probeRes.StatusCode)
+		return pb.ServingStatusNotServing
+	}
+
+	return pb.ServingStatusServing
+}
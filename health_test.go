@@ -0,0 +1,190 @@
+package http2grpc_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/v-electrolux/http2grpc"
+)
+
+type TestHealthData struct {
+	cfgServices []string
+
+	probeHTTPResStatusCodes []int
+
+	reqPath    string
+	reqService string
+	reqTimeout time.Duration
+
+	expBackendCalled     bool
+	expGrpcResBody       []byte
+	expGrpcResStatusCode int
+	expGrpcResStatusMsg  string
+}
+
+func TestHealthCheckServing(t *testing.T) {
+	data := TestHealthData{
+		probeHTTPResStatusCodes: []int{200},
+
+		reqPath: "/grpc.health.v1.Health/Check",
+
+		expGrpcResBody:       []byte{0x00, 0x00, 0x00, 0x00, 0x02, 0x08, 0x01},
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testHealthRequest(t, data)
+}
+
+func TestHealthCheckNotServing(t *testing.T) {
+	data := TestHealthData{
+		cfgServices:             []string{"pkg.Service"},
+		probeHTTPResStatusCodes: []int{503},
+
+		reqPath:    "/grpc.health.v1.Health/Check",
+		reqService: "pkg.Service",
+
+		expGrpcResBody:       []byte{0x00, 0x00, 0x00, 0x00, 0x02, 0x08, 0x02},
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testHealthRequest(t, data)
+}
+
+func TestHealthCheckUnknownService(t *testing.T) {
+	data := TestHealthData{
+		cfgServices:             []string{"pkg.Service"},
+		probeHTTPResStatusCodes: []int{200},
+
+		reqPath:    "/grpc.health.v1.Health/Check",
+		reqService: "pkg.Other",
+
+		expGrpcResBody:       []byte{0x00, 0x00, 0x00, 0x00, 0x00},
+		expGrpcResStatusCode: 5,
+		expGrpcResStatusMsg:  "unknown service",
+	}
+	testHealthRequest(t, data)
+}
+
+func TestHealthWatchStatusChange(t *testing.T) {
+	data := TestHealthData{
+		probeHTTPResStatusCodes: []int{200, 200, 500},
+
+		reqPath:    "/grpc.health.v1.Health/Watch",
+		reqTimeout: 200 * time.Millisecond,
+
+		expGrpcResBody: []byte{
+			0x00, 0x00, 0x00, 0x00, 0x02, 0x08, 0x01,
+			0x00, 0x00, 0x00, 0x00, 0x02, 0x08, 0x02,
+		},
+		expGrpcResStatusCode: 1,
+		expGrpcResStatusMsg:  "health: watch canceled",
+	}
+	testHealthRequest(t, data)
+}
+
+func TestHealthWatchUnknownService(t *testing.T) {
+	data := TestHealthData{
+		cfgServices:             []string{"pkg.Service"},
+		probeHTTPResStatusCodes: []int{200},
+
+		reqPath:    "/grpc.health.v1.Health/Watch",
+		reqService: "pkg.Other",
+		reqTimeout: 50 * time.Millisecond,
+
+		expGrpcResBody:       []byte{0x00, 0x00, 0x00, 0x00, 0x02, 0x08, 0x03},
+		expGrpcResStatusCode: 1,
+		expGrpcResStatusMsg:  "health: watch canceled",
+	}
+	testHealthRequest(t, data)
+}
+
+func TestHealthOtherMethodPassed(t *testing.T) {
+	data := TestHealthData{
+		probeHTTPResStatusCodes: []int{200},
+
+		reqPath: "/pkg.Service/Method",
+
+		expBackendCalled:     true,
+		expGrpcResBody:       []byte{0x00, 0x00, 0x00, 0x00, 0x00},
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testHealthRequest(t, data)
+}
+
+func testHealthRequest(t *testing.T, data TestHealthData) {
+	t.Helper()
+
+	var probeCount int32
+
+	probeServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		i := int(atomic.AddInt32(&probeCount, 1)) - 1
+		if i >= len(data.probeHTTPResStatusCodes) {
+			i = len(data.probeHTTPResStatusCodes) - 1
+		}
+
+		rw.WriteHeader(data.probeHTTPResStatusCodes[i])
+	}))
+	defer probeServer.Close()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.Health.ProbeURL = probeServer.URL
+	cfg.Health.Services = data.cfgServices
+	cfg.Health.WatchInterval = "10ms"
+
+	backendCalled := false
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		backendCalled = true
+
+		rw.Header().Set("Content-Type", "application/grpc")
+		rw.Header().Set("Trailer", "grpc-status")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte{0x00, 0x00, 0x00, 0x00, 0x00})
+		rw.Header().Set("grpc-status", "0")
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.reqTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, data.reqTimeout)
+
+		defer cancel()
+	}
+
+	reqBody := []byte{0x00, 0x00, 0x00, 0x00, 0x00}
+	if data.reqService != "" {
+		reqBody = []byte{0x00, 0x00, 0x00, 0x00, byte(len(data.reqService) + 2), 0x0a, byte(len(data.reqService))}
+		reqBody = append(reqBody, data.reqService...)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost"+data.reqPath, bytes.NewReader(reqBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+
+	if backendCalled != data.expBackendCalled {
+		t.Errorf("expected backend called: `%t`, got: `%t`", data.expBackendCalled, backendCalled)
+	}
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertBody(t, resp, data.expGrpcResBody)
+	assertHeader(t, resp, "Content-Type", "application/grpc")
+	assertTrailer(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+	assertTrailer(t, resp, "grpc-message", data.expGrpcResStatusMsg)
+}
@@ -0,0 +1,149 @@
+package http2grpc
+
+import (
+	"html"
+	"mime"
+	"strings"
+)
+
+// isHTMLContentType is whether Content-Type header value is HTML page.
+func isHTMLContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
+
+// summarizeHTML returns concise status message of HTML error page: its title, the first h1 heading,
+// or its text without markup, scripts and styles, with whitespace collapsed and truncated in any case.
+func summarizeHTML(page string) string {
+	page = dropComments(page)
+	lower := lowerASCII(page)
+
+	for _, name := range []string{"title", "h1"} {
+		if inner, ok := elementContent(page, lower, name); ok {
+			if text := collapseWhitespace(html.UnescapeString(stripTags(inner))); text != "" {
+				return truncateMessage(text)
+			}
+		}
+	}
+
+	return truncateMessage(collapseWhitespace(html.UnescapeString(stripTags(page))))
+}
+
+// elementContent returns content of the first element with name, lower is page in lower case.
+func elementContent(page string, lower string, name string) (string, bool) {
+	start := openingTagEnd(lower, name, 0)
+	if start < 0 {
+		return "", false
+	}
+
+	end := strings.Index(lower[start:], "</"+name)
+	if end < 0 {
+		return page[start:], true
+	}
+
+	return page[start : start+end], true
+}
+
+// openingTagEnd returns index after the first opening tag with name starting from index from, -1 if there is none.
+func openingTagEnd(lower string, name string, from int) int {
+	for {
+		i := strings.Index(lower[from:], "<"+name)
+		if i < 0 {
+			return -1
+		}
+
+		i += from + len(name) + 1
+		if i < len(lower) && (lower[i] == '>' || lower[i] == '/' || isHTMLSpace(lower[i])) {
+			end := strings.IndexByte(lower[i:], '>')
+			if end < 0 {
+				return -1
+			}
+
+			return i + end + 1
+		}
+
+		from = i
+	}
+}
+
+// dropComments removes HTML comments, unterminated one takes the rest of the page.
+func dropComments(page string) string {
+	var b strings.Builder
+
+	for {
+		start := strings.Index(page, "<!--")
+		if start < 0 {
+			b.WriteString(page)
+			return b.String()
+		}
+
+		b.WriteString(page[:start])
+
+		end := strings.Index(page[start+4:], "-->")
+		if end < 0 {
+			return b.String()
+		}
+
+		page = page[start+4+end+3:]
+	}
+}
+
+// stripTags replaces tags with spaces and drops content of script and style elements.
+func stripTags(page string) string {
+	lower := lowerASCII(page)
+
+	var b strings.Builder
+
+	for i := 0; i < len(page); {
+		if page[i] != '<' {
+			b.WriteByte(page[i])
+			i++
+
+			continue
+		}
+
+		end := strings.IndexByte(page[i:], '>')
+		if end < 0 {
+			break
+		}
+
+		next := i + end + 1
+
+		for _, name := range []string{"script", "style"} {
+			if openingTagEnd(lower[i:next], name, 0) == end+1 {
+				if closing := strings.Index(lower[next:], "</"+name); closing >= 0 {
+					next += closing
+				} else {
+					next = len(page)
+				}
+			}
+		}
+
+		b.WriteByte(' ')
+
+		i = next
+	}
+
+	return b.String()
+}
+
+func collapseWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// lowerASCII lowers ASCII letters only, so indexes of lowered string match the original one.
+func lowerASCII(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + ('a' - 'A')
+		}
+	}
+
+	return string(b)
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
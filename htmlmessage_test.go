@@ -0,0 +1,169 @@
+package http2grpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/v-electrolux/http2grpc"
+	"github.com/v-electrolux/http2grpc/grpc"
+)
+
+type TestHTMLMessageData struct {
+	cfgBodyAsStatusMessage bool
+
+	backendStatusCode  int
+	backendContentType string
+	backendBody        []string
+
+	expGrpcResStatusCode int
+	expGrpcResStatusMsg  string
+}
+
+func TestHTMLMessageTitle(t *testing.T) {
+	data := TestHTMLMessageData{
+		cfgBodyAsStatusMessage: true,
+
+		backendStatusCode:  http.StatusBadGateway,
+		backendContentType: "text/html",
+		backendBody: []string{
+			"<html>\r\n<head><title>502 Bad",
+			" Gateway</title></head>\r\n<body>\r\n<center><h1>502 Bad Gateway</h1></center>\r\n",
+			"<hr><center>nginx</center>\r\n</body>\r\n</html>\r\n",
+		},
+
+		expGrpcResStatusCode: grpc.UNAVAILABLE,
+		expGrpcResStatusMsg:  "502 Bad Gateway",
+	}
+	testHTMLMessageRequest(t, data)
+}
+
+func TestHTMLMessageHeading(t *testing.T) {
+	data := TestHTMLMessageData{
+		cfgBodyAsStatusMessage: true,
+
+		backendStatusCode:  http.StatusForbidden,
+		backendContentType: "text/html; charset=utf-8",
+		backendBody: []string{
+			"<!DOCTYPE html><HTML><Title>  </Title><BODY><H1 class=\"error\">Access <b>denied</b> &amp;\n logged</H1>",
+			"<p>Contact admin</p></BODY></HTML>",
+		},
+
+		expGrpcResStatusCode: grpc.PERMISSION_DENIED,
+		expGrpcResStatusMsg:  "Access denied & logged",
+	}
+	testHTMLMessageRequest(t, data)
+}
+
+func TestHTMLMessageStrippedText(t *testing.T) {
+	data := TestHTMLMessageData{
+		cfgBodyAsStatusMessage: true,
+
+		backendStatusCode:  http.StatusNotFound,
+		backendContentType: "application/xhtml+xml",
+		backendBody: []string{
+			"<html><head><style>p { color: red; }</style><script type=\"text/javascript\">var a = 1 < 2;</script>",
+			"</head><body><!-- <h1>hidden</h1> --><p>Page\t not</p>\n\n<p>found&#33;</p></body></html>",
+		},
+
+		expGrpcResStatusCode: grpc.UNIMPLEMENTED,
+		expGrpcResStatusMsg:  "Page not found!",
+	}
+	testHTMLMessageRequest(t, data)
+}
+
+func TestHTMLMessageLongTextTruncated(t *testing.T) {
+	data := TestHTMLMessageData{
+		cfgBodyAsStatusMessage: true,
+
+		backendStatusCode:  http.StatusInternalServerError,
+		backendContentType: "text/html",
+		backendBody:        []string{"<div>" + strings.Repeat("ab ", 100) + "</div>"},
+
+		expGrpcResStatusCode: grpc.UNKNOWN,
+		expGrpcResStatusMsg:  strings.Repeat("ab ", 85) + "a...",
+	}
+	testHTMLMessageRequest(t, data)
+}
+
+func TestHTMLMessageLongTitleTruncated(t *testing.T) {
+	data := TestHTMLMessageData{
+		cfgBodyAsStatusMessage: true,
+
+		backendStatusCode:  http.StatusInternalServerError,
+		backendContentType: "text/html",
+		backendBody:        []string{"<title>" + strings.Repeat("ошибка ", 50) + "</title>"},
+
+		expGrpcResStatusCode: grpc.UNKNOWN,
+		expGrpcResStatusMsg:  strings.Repeat("ошибка ", 36) + "ошиб...",
+	}
+	testHTMLMessageRequest(t, data)
+}
+
+func TestHTMLMessagePlainTextNotSummarized(t *testing.T) {
+	data := TestHTMLMessageData{
+		cfgBodyAsStatusMessage: true,
+
+		backendStatusCode:  http.StatusForbidden,
+		backendContentType: "text/plain",
+		backendBody:        []string{"<title>not html</title>"},
+
+		expGrpcResStatusCode: grpc.PERMISSION_DENIED,
+		expGrpcResStatusMsg:  "<title>not html</title>",
+	}
+	testHTMLMessageRequest(t, data)
+}
+
+func TestHTMLMessageWithoutBodyAsStatusMessage(t *testing.T) {
+	data := TestHTMLMessageData{
+		cfgBodyAsStatusMessage: false,
+
+		backendStatusCode:  http.StatusBadGateway,
+		backendContentType: "text/html",
+		backendBody:        []string{"<html><head><title>502 Bad Gateway</title></head></html>"},
+
+		expGrpcResStatusCode: grpc.UNAVAILABLE,
+		expGrpcResStatusMsg:  "",
+	}
+	testHTMLMessageRequest(t, data)
+}
+
+func testHTMLMessageRequest(t *testing.T, data TestHTMLMessageData) {
+	t.Helper()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.BodyAsStatusMessage = data.cfgBodyAsStatusMessage
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", data.backendContentType)
+		rw.WriteHeader(data.backendStatusCode)
+
+		for _, chunk := range data.backendBody {
+			rw.Write([]byte(chunk))
+		}
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost/pkg.v1.Svc/Get", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertHeader(t, resp, "Content-Type", "application/grpc")
+	assertTrailer(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+	assertTrailer(t, resp, "grpc-message", grpc.EncodeMessage(data.expGrpcResStatusMsg))
+}
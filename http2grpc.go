@@ -5,22 +5,30 @@ import (
 	"context"
 	"fmt"
 	"github.com/v-electrolux/http2grpc/grpc"
+	"github.com/v-electrolux/http2grpc/internal/pb"
 	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
-	"os"
 	"strconv"
+	"unicode/utf8"
 )
 
 const (
 	GrpcStatusHeaderName               = "grpc-status"
 	GrpcMessageHeaderName              = "grpc-message"
+	GrpcEncodingHeaderName             = "grpc-encoding"
+	GrpcRetryPushbackHeaderName        = "grpc-retry-pushback-ms"
 	TrailerHeaderName                  = "Trailer"
 	ContentLengthHeaderName            = "Content-Length"
 	ContentTypeHeaderName              = "Content-Type"
 	ContentTypeHeaderGrpcValue         = "application/grpc"
 	ContentTypeHeaderGrpcWithBodyValue = "application/grpc+proto"
+
+	// maxDroppedBodySize limits collected body of response with non-200 http status code
+	maxDroppedBodySize = 64 * 1024
+	// maxBodyMessageLength is max number of characters of status message made of response body
+	maxBodyMessageLength = 256
 )
 
 //nolint:gochecknoglobals // TODO exchange for traefik log when available
@@ -34,52 +42,266 @@ var (
 )
 
 type Config struct {
-	LogLevel            string `yaml:"logLevel"`
-	BodyAsStatusMessage bool   `yaml:"bodyAsStatusMessage"`
+	LogLevel                string                  `yaml:"logLevel"`
+	BodyAsStatusMessage     bool                    `yaml:"bodyAsStatusMessage"`
+	ValidateResponseFraming bool                    `yaml:"validateResponseFraming"`
+	MaxResponseMessageSize  int                     `yaml:"maxResponseMessageSize"`
+	ProxyErrors             ProxyErrorsConfig       `yaml:"proxyErrors"`
+	ForwardAuth             ForwardAuthConfig       `yaml:"forwardAuth"`
+	FaultInjection          FaultInjectionConfig    `yaml:"faultInjection"`
+	Maintenance             MaintenanceConfig       `yaml:"maintenance"`
+	Health                  HealthConfig            `yaml:"health"`
+	Stubs                   StubsConfig             `yaml:"stubs"`
+	MethodACL               MethodACLConfig         `yaml:"methodACL"`
+	RateLimit               RateLimitConfig         `yaml:"rateLimit"`
+	ConcurrencyLimit        ConcurrencyLimitConfig  `yaml:"concurrencyLimit"`
+	Deadlines               DeadlinesConfig         `yaml:"deadlines"`
+	Retry                   RetryConfig             `yaml:"retry"`
+	Compression             CompressionConfig       `yaml:"compression"`
+	MessageSizeLimits       MessageSizeLimitsConfig `yaml:"messageSizeLimits"`
+	StatusDetails           StatusDetailsConfig     `yaml:"statusDetails"`
+	ValidationErrors        ValidationErrorsConfig  `yaml:"validationErrors"`
+	Localization            LocalizationConfig      `yaml:"localization"`
+	Metrics                 MetricsConfig           `yaml:"metrics"`
 }
 
 func CreateConfig() *Config {
 	return &Config{
-		BodyAsStatusMessage: false,
-		LogLevel:            "info",
+		BodyAsStatusMessage:     false,
+		LogLevel:                "info",
+		ValidateResponseFraming: false,
+		MaxResponseMessageSize:  0,
+		ProxyErrors: ProxyErrorsConfig{
+			Enabled:      false,
+			MarkerHeader: "",
+			Domain:       "traefik.io",
+		},
+		ForwardAuth: ForwardAuthConfig{
+			Address:             "",
+			AuthRequestHeaders:  nil,
+			AuthResponseHeaders: nil,
+			TrustForwardHeader:  false,
+			Timeout:             "30s",
+		},
+		FaultInjection: FaultInjectionConfig{
+			Faults:       nil,
+			ToggleHeader: "",
+		},
+		Maintenance: MaintenanceConfig{
+			Enabled:        false,
+			Message:        "service is under maintenance",
+			RetryPushback:  "",
+			AllowMethods:   nil,
+			AllowClientIPs: nil,
+			AllowHeaders:   nil,
+			Start:          "",
+			End:            "",
+		},
+		Health: HealthConfig{
+			ProbeURL:      "",
+			Services:      nil,
+			Timeout:       "5s",
+			WatchInterval: "5s",
+		},
+		Stubs: StubsConfig{
+			DescriptorSetFile: "",
+			DescriptorSet:     "",
+			Responses:         nil,
+		},
+		MethodACL: MethodACLConfig{
+			Allow:   nil,
+			Deny:    nil,
+			Code:    grpc.PERMISSION_DENIED,
+			Message: "method is not allowed",
+		},
+		RateLimit: RateLimitConfig{
+			Limits:  nil,
+			Message: "rate limit exceeded",
+		},
+		ConcurrencyLimit: ConcurrencyLimitConfig{
+			MaxInFlight:  0,
+			Methods:      nil,
+			QueueSize:    0,
+			QueueTimeout: "100ms",
+			Code:         grpc.RESOURCE_EXHAUSTED,
+			Message:      "too many concurrent calls",
+		},
+		Deadlines: DeadlinesConfig{
+			Rules: nil,
+		},
+		Retry: RetryConfig{
+			Policies:        nil,
+			MaxRequestSize:  64 * 1024,
+			MaxResponseSize: 64 * 1024,
+		},
+		Compression: CompressionConfig{
+			Enabled:             false,
+			MinSize:             1024,
+			MaxDecompressedSize: 4 * 1024 * 1024,
+			MaxBufferedSize:     4 * 1024 * 1024,
+		},
+		MessageSizeLimits: MessageSizeLimitsConfig{
+			Limits: nil,
+		},
+		StatusDetails: StatusDetailsConfig{
+			Enabled: false,
+		},
+		ValidationErrors: ValidationErrorsConfig{
+			Enabled:     false,
+			StatusCodes: []int{http.StatusBadRequest, http.StatusUnprocessableEntity},
+			ErrorsPath:  "errors",
+			FieldKey:    "field",
+			MessageKey:  "message",
+			ReasonKey:   "",
+		},
+		Localization: LocalizationConfig{
+			Catalogs:      nil,
+			LocaleHeader:  "",
+			DefaultLocale: "",
+		},
+		Metrics: MetricsConfig{
+			Path: "",
+		},
 	}
 }
 
-type HTTP2Grpc struct {
+// interceptor answers or rejects request before calling backend.
+type interceptor interface {
+	// intercept returns true if response is already written to rw, so backend must not be called
+	intercept(rw *http2grpcModifier, req *http.Request) bool
+}
+
+// HTTP2Grpc is the former name of Converter.
+//
+// Deprecated: use Converter.
+type HTTP2Grpc = Converter
+
+// Converter is http.Handler which converts HTTP responses of next handler to gRPC ones,
+// it is created by Wrap or by New as traefik plugin.
+type Converter struct {
 	next   http.Handler
 	config *Config
 	name   string
+	// interceptors are called in order before backend
+	interceptors []interceptor
+	// concurrency limits calls passed to backend, nil if disabled
+	concurrency *concurrencyLimiter
+	// deadlines adds grpc-timeout to calls passed to backend, nil if disabled
+	deadlines *deadlines
+	// retrier retries failed backend calls, nil if disabled
+	retrier *retrier
+	// messageSizes checks sizes of messages of calls passed to backend, nil if disabled
+	messageSizes *messageSizeLimits
+	// statusDetails inspects error details of gRPC backend responses, nil if disabled
+	statusDetails *statusDetailsInspector
+	// localizer adds localized messages to statuses, nil if disabled
+	localizer *localizer
+	metrics   *metrics
+	// hooks customize conversion of HTTP responses, nil if none is set
+	hooks *conversionHooks
 }
 
+// New creates middleware as traefik plugin.
 func New(_ context.Context, next http.Handler, config *Config, name string) (http.Handler, error) {
-	switch config.LogLevel {
-	case "info":
-		LoggerINFO.SetOutput(os.Stdout)
-	case "debug":
-		LoggerINFO.SetOutput(os.Stdout)
-		LoggerDEBUG.SetOutput(os.Stdout)
-	default:
-		return nil, fmt.Errorf("ERROR: http2grpc: %s", config.LogLevel)
+	c, err := Wrap(next, WithConfig(config), WithName(name))
+	if err != nil {
+		return nil, err
 	}
 
-	return &HTTP2Grpc{
-		next:   next,
-		name:   name,
-		config: config,
-	}, nil
+	return c, nil
 }
 
-func (h *HTTP2Grpc) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+func (h *Converter) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	LoggerDEBUG.Printf("ServeHTTP started")
 
+	if h.config.Metrics.Path != "" && req.URL.Path == h.config.Metrics.Path {
+		h.metrics.serveHTTP(rw)
+		LoggerDEBUG.Printf("ServeHTTP completed, metrics served")
+
+		return
+	}
+
 	bodyAsStatusMessage := h.config.BodyAsStatusMessage
 
 	LoggerDEBUG.Printf("ServeHTTP config read")
 
 	rwMod := newHTTP2grpcModifier(rw, bodyAsStatusMessage)
+	rwMod.statusDetails = h.statusDetails
+
+	if h.localizer != nil {
+		rwMod.localizer = h.localizer
+		rwMod.locales = h.localizer.requestLocales(req)
+	}
+
+	if h.hooks != nil {
+		rwMod.hooks = h.hooks
+		rwMod.request = req
+	}
+
+	if h.config.ValidateResponseFraming {
+		rwMod.enableFrameValidation(h.config.MaxResponseMessageSize)
+	}
+
+	if h.config.ProxyErrors.Enabled {
+		rwMod.proxyErrors = &h.config.ProxyErrors
+	}
+
+	if h.config.Compression.Enabled {
+		rwMod.compression = &h.config.Compression
+		rwMod.acceptEncoding = req.Header.Get(GrpcAcceptEncodingHeaderName)
+	}
 
 	LoggerDEBUG.Printf("ServeHTTP http2grpcModifier created")
-	h.next.ServeHTTP(rwMod, req)
+
+	for _, step := range h.interceptors {
+		if step.intercept(rwMod, req) {
+			rwMod.finish()
+			LoggerDEBUG.Printf("ServeHTTP completed, intercepted by %T", step)
+
+			return
+		}
+	}
+
+	if h.concurrency != nil {
+		release, acquired := h.concurrency.acquire(rwMod, req)
+		if !acquired {
+			rwMod.finish()
+			LoggerDEBUG.Printf("ServeHTTP completed, rejected by concurrency limit")
+
+			return
+		}
+
+		defer release()
+	}
+
+	var deadlineCancel context.CancelFunc
+	if h.deadlines != nil {
+		req, deadlineCancel = h.deadlines.apply(req)
+	}
+
+	var limitedBody *limitedGrpcBody
+	if h.messageSizes != nil {
+		limitedBody = h.messageSizes.apply(rwMod, req)
+	}
+
+	if h.retrier != nil {
+		h.retrier.serveHTTP(h.next, rwMod, req)
+	} else {
+		h.next.ServeHTTP(rwMod, req)
+	}
+
+	if limitedBody != nil {
+		if violation := limitedBody.err(); violation != nil {
+			rwMod.fail(violation.code, violation.message)
+		}
+	}
+
+	if deadlineCancel != nil {
+		finishDeadline(rwMod, req)
+		deadlineCancel()
+	}
+
+	rwMod.finish()
 	LoggerDEBUG.Printf("ServeHTTP completed")
 	LoggerINFO.Printf("executed successful")
 }
@@ -95,9 +317,52 @@ type http2grpcModifier struct {
 	bodyAsStatusMessage bool
 	// headerSent is whether the headers have already been sent, either through Write or WriteHeader.
 	headerSent bool
+	// validateFraming enables length-prefixed framing check of gRPC response from backend
+	validateFraming bool
+	// maxMessageSize is max allowed length of one gRPC response message, 0 means unlimited
+	maxMessageSize int
+	// frameParser checks gRPC response body, created in WriteHeader if validateFraming is enabled
+	frameParser *grpcFrameParser
+	// abortErr is the status middleware terminates the call with, e.g. framing violation,
+	// after it the rest of the body is dropped
+	abortErr *grpcStatusError
+	// droppedBody collects body of response with non-200 http status code, which is not forwarded to client
+	droppedBody []byte
+	// droppedBodyTruncated is whether droppedBody misses the end of the body, which exceeded collected size
+	droppedBodyTruncated bool
+	// proxyErrors enables recognition of traefik originated errors if not nil
+	proxyErrors *ProxyErrorsConfig
+	// proxyErrorMarked is whether the response has proxy error marker header
+	proxyErrorMarked bool
+	// compression enables recompression of gRPC response messages if not nil
+	compression *CompressionConfig
+	// acceptEncoding is grpc-accept-encoding header of request
+	acceptEncoding string
+	// recompressor rewrites gRPC response messages, created in WriteHeader if client does not accept backend encoding
+	recompressor *grpcRecompressor
+	// hooks customize conversion of HTTP responses, nil if none is set
+	hooks *conversionHooks
+	// request is passed to OnConvert hook
+	request *http.Request
+	// converted is whether HTTP response with non-200 status code is converted with hooks collecting its body
+	converted bool
+	// backendHeader is header of converted HTTP response before conversion
+	backendHeader http.Header
+	// statusDetails inspects error details of gRPC backend response, nil if disabled
+	statusDetails *statusDetailsInspector
+	// authChallenge is WWW-Authenticate challenge of converted 401 response, nil if there is none
+	authChallenge *authChallenge
+	// messageFromBody is whether status message is built from the body collected in droppedBody
+	messageFromBody bool
+	// messageContentType is Content-Type of HTTP response, which message is built from
+	messageContentType string
+	// localizer adds localized message to status, nil if disabled
+	localizer *localizer
+	// locales are requested by client in order of preference
+	locales []string
 }
 
-func newHTTP2grpcModifier(rw http.ResponseWriter, bodyAsStatusMessage bool) http.ResponseWriter {
+func newHTTP2grpcModifier(rw http.ResponseWriter, bodyAsStatusMessage bool) *http2grpcModifier {
 	http2grpcMod := &http2grpcModifier{
 		responseWriter:        rw,
 		responseWriterFlusher: nil,
@@ -105,6 +370,27 @@ func newHTTP2grpcModifier(rw http.ResponseWriter, bodyAsStatusMessage bool) http
 		backendUseGrpc:        false,
 		bodyAsStatusMessage:   bodyAsStatusMessage,
 		headerSent:            false,
+		validateFraming:       false,
+		maxMessageSize:        0,
+		frameParser:           nil,
+		abortErr:              nil,
+		droppedBody:           nil,
+		droppedBodyTruncated:  false,
+		proxyErrors:           nil,
+		proxyErrorMarked:      false,
+		compression:           nil,
+		acceptEncoding:        "",
+		recompressor:          nil,
+		hooks:                 nil,
+		request:               nil,
+		converted:             false,
+		backendHeader:         nil,
+		statusDetails:         nil,
+		authChallenge:         nil,
+		messageFromBody:       false,
+		messageContentType:    "",
+		localizer:             nil,
+		locales:               nil,
 	}
 
 	if flusher, ok := rw.(http.Flusher); ok {
@@ -131,21 +417,57 @@ func (h *http2grpcModifier) Write(buf []byte) (int, error) {
 
 	h.WriteHeader(http.StatusOK)
 
-	if isHTTPResponseFromBackend && isNotOkStatusFromBackend && h.bodyAsStatusMessage {
-		LoggerDEBUG.Printf("Write() `grpc-message` header set to %s", string(buf))
-		h.responseWriter.Header().Set(GrpcMessageHeaderName, string(buf))
+	if h.backendUseGrpc && h.sentHTTPStatusCode != http.StatusOK {
+		// body of non-200 response is not a stream of gRPC messages, so it is never forwarded
+		LoggerDEBUG.Printf("Write() grpc response with http status %d, dropping %d bytes", h.sentHTTPStatusCode, len(buf))
+
+		if h.bodyAsStatusMessage {
+			h.collectBody(buf, maxDroppedBodySize)
+		}
+
+		return len(buf), nil
+	}
+
+	if isHTTPResponseFromBackend && isNotOkStatusFromBackend {
+		if h.converted || h.messageFromBody {
+			h.collectBody(buf, maxDroppedBodySize)
+		} else if h.proxyErrors != nil {
+			// traefik error body is just status text with optional line feed, see isTraefikErrorBody
+			h.collectBody(buf, len(http.StatusText(h.sentHTTPStatusCode))+1)
+		}
+	}
+
+	if h.abortErr != nil {
+		LoggerDEBUG.Printf("Write() call already aborted, dropping %d bytes", len(buf))
+		return len(buf), nil
 	}
 
 	var body []byte
 	if isHTTPResponseFromBackend && isNotOkStatusFromBackend {
 		body = EmptyGrpcBody
+	} else if h.frameParser != nil {
+		body, h.abortErr = h.frameParser.parse(buf)
+		if h.abortErr != nil {
+			LoggerINFO.Printf("invalid grpc framing from backend: %s", h.abortErr)
+		}
 	} else {
 		body = buf
 	}
 
+	if h.recompressor != nil && h.abortErr == nil {
+		body, h.abortErr = h.recompressor.transform(body)
+		if h.abortErr != nil {
+			LoggerINFO.Printf("grpc message recompression failed: %s", h.abortErr)
+		}
+	}
+
 	count, err := h.responseWriter.Write(body)
 	LoggerDEBUG.Printf("Write() body wrote, length %d", len(body))
 
+	if (h.frameParser != nil || h.recompressor != nil) && err == nil {
+		count = len(buf)
+	}
+
 	// need for gRPC stream, because response can be buffered
 	// delaying messages via stream
 	if h.responseWriterFlusher != nil {
@@ -165,11 +487,23 @@ func (h *http2grpcModifier) WriteHeader(statusCode int) {
 
 	if isHTTPResponseFromBackend := !h.checkResponseInGrpcFormat(); isHTTPResponseFromBackend {
 		LoggerDEBUG.Printf("WriteHeader() converting http to grpc")
+		h.markProxyError(statusCode)
 		h.convertHTTPToGrpc(statusCode)
 	} else {
 		LoggerDEBUG.Printf("WriteHeader() grpc leave as is, headers: %+v", h.responseWriter.Header())
+
+		// framing is validated against encoding of backend, before it is rewritten by recompression
+		encoding := h.responseWriter.Header().Get(GrpcEncodingHeaderName)
+		if h.compression != nil && statusCode == http.StatusOK {
+			h.recompressor = newGrpcRecompressor(h.compression, h.acceptEncoding, h.responseWriter.Header())
+		}
+
 		h.responseWriter.WriteHeader(http.StatusOK)
 		h.backendUseGrpc = true
+
+		if h.validateFraming {
+			h.frameParser = newGrpcFrameParser(encoding, h.maxMessageSize)
+		}
 	}
 
 	h.sentHTTPStatusCode = statusCode
@@ -198,7 +532,219 @@ func (h *http2grpcModifier) Flush() {
 	}
 }
 
+func (h *http2grpcModifier) enableFrameValidation(maxMessageSize int) {
+	// the strictest limit applies if framing is validated for several reasons
+	if h.validateFraming && h.maxMessageSize > 0 && (maxMessageSize == 0 || h.maxMessageSize < maxMessageSize) {
+		return
+	}
+
+	h.validateFraming = true
+	h.maxMessageSize = maxMessageSize
+}
+
+// finish must be called after backend completes the response,
+// so trailers set here take precedence over trailers from backend.
+func (h *http2grpcModifier) finish() {
+	h.finishFraming()
+	h.finishStatusDetails()
+	h.finishNotOkGrpc()
+	h.finishBodyMessage()
+	h.finishConversion()
+	h.finishAuthChallenge()
+	h.finishProxyError()
+	h.finishAbort()
+	h.finishLocalization()
+}
+
+// fail terminates the call with status after backend completed it,
+// the status is sent as a whole response if backend sent nothing.
+func (h *http2grpcModifier) fail(code int, message string) {
+	h.abort(code, message)
+
+	if !h.headerSent {
+		h.writeGrpcStatus(code, message)
+	}
+}
+
+// abort terminates the call with status, unless it is already aborted,
+// the rest of the body is dropped and status is set to trailers in finish.
+func (h *http2grpcModifier) abort(code int, message string) {
+	if h.abortErr == nil {
+		h.abortErr = &grpcStatusError{code: code, message: message}
+	}
+}
+
+func (h *http2grpcModifier) finishFraming() {
+	if h.abortErr != nil {
+		return
+	}
+
+	if (h.frameParser != nil && h.frameParser.incomplete()) || (h.recompressor != nil && h.recompressor.incomplete()) {
+		h.abort(grpc.INTERNAL, "grpc: response stream ended in the middle of a message")
+		LoggerINFO.Printf("invalid grpc framing from backend: %s", h.abortErr)
+	}
+}
+
+func (h *http2grpcModifier) finishAbort() {
+	if h.abortErr != nil {
+		setGrpcStatusTrailers(h.responseWriter.Header(), h.abortErr.code, h.abortErr.message)
+	}
+}
+
+// collectBody appends buf to droppedBody up to limit, the rest of the body is discarded.
+func (h *http2grpcModifier) collectBody(buf []byte, limit int) {
+	if room := limit - len(h.droppedBody); len(buf) > room {
+		h.droppedBodyTruncated = true
+
+		if room <= 0 {
+			return
+		}
+
+		buf = buf[:room]
+	}
+
+	h.droppedBody = append(h.droppedBody, buf...)
+}
+
+// truncateMessage cuts status message made of response body to maxBodyMessageLength characters.
+func truncateMessage(message string) string {
+	if utf8.RuneCountInString(message) <= maxBodyMessageLength {
+		return message
+	}
+
+	return string([]rune(message)[:maxBodyMessageLength]) + "..."
+}
+
+// finishBodyMessage sets status message made of HTTP response body decoded by its charset,
+// the body is collected as a whole, because charsets and HTML are not meaningful chunk by chunk.
+func (h *http2grpcModifier) finishBodyMessage() {
+	if !h.messageFromBody || len(h.droppedBody) == 0 {
+		return
+	}
+
+	message := decodeBody(h.droppedBody, h.messageContentType)
+	if isHTMLContentType(h.messageContentType) {
+		message = summarizeHTML(message)
+	} else {
+		message = truncateMessage(message)
+	}
+
+	LoggerDEBUG.Printf("finish() `grpc-message` set to %s", message)
+	h.responseWriter.Header().Set(GrpcMessageHeaderName, grpc.EncodeMessage(message))
+}
+
+// finishConversion builds status of converted HTTP response with message extractor and OnConvert hook.
+func (h *http2grpcModifier) finishConversion() {
+	if !h.converted {
+		return
+	}
+
+	conversion := &Conversion{
+		Request:    h.request,
+		HTTPStatus: h.sentHTTPStatusCode,
+		Header:     h.backendHeader,
+		Body:       h.droppedBody,
+		Code:       h.hooks.mapStatus(h.sentHTTPStatusCode),
+		Message:    grpc.DecodeMessage(grpc.Trailer(h.responseWriter.Header(), GrpcMessageHeaderName)),
+	}
+
+	if h.hooks.extractor != nil {
+		conversion.Message = h.hooks.extractor(h.sentHTTPStatusCode, h.backendHeader, h.droppedBody)
+	}
+
+	if h.hooks.validation != nil {
+		h.hooks.validation.apply(conversion)
+	}
+
+	if h.hooks.onConvert != nil {
+		h.hooks.onConvert(conversion)
+	}
+
+	status := &grpc.Status{Code: grpc.Code(conversion.Code), Message: conversion.Message, Details: conversion.Details}
+	status.SetTrailers(h.responseWriter.Header())
+}
+
+// finishNotOkGrpc synthesizes trailers for gRPC response with non-200 http status code,
+// which is possible when backend or traefik itself fails, and keeps trailers if backend supplied them.
+func (h *http2grpcModifier) finishNotOkGrpc() {
+	if !h.backendUseGrpc || h.sentHTTPStatusCode == http.StatusOK {
+		return
+	}
+
+	if grpc.HasTrailer(h.responseWriter.Header(), GrpcStatusHeaderName) {
+		LoggerDEBUG.Printf("finish() grpc response with http status %d has grpc-status, leave as is",
+			h.sentHTTPStatusCode)
+		return
+	}
+
+	LoggerDEBUG.Printf("finish() grpc response with http status %d has no grpc-status, synthesizing",
+		h.sentHTTPStatusCode)
+
+	message := truncateMessage(decodeBody(h.droppedBody, ""))
+	setGrpcStatusTrailers(h.responseWriter.Header(), h.hooks.mapStatus(h.sentHTTPStatusCode), message)
+}
+
 func (h *http2grpcModifier) convertHTTPToGrpc(statusCode int) {
+	if statusCode != http.StatusOK && h.bodyAsStatusMessage && (h.hooks == nil || h.hooks.extractor == nil) {
+		h.messageFromBody = true
+		h.messageContentType = h.responseWriter.Header().Get(ContentTypeHeaderName)
+	}
+
+	if statusCode != http.StatusOK && h.hooks.collectsBody() {
+		h.converted = true
+		h.backendHeader = h.responseWriter.Header().Clone()
+	}
+
+	h.sendGrpcHeaders()
+	h.responseWriter.Header().Set(GrpcStatusHeaderName, strconv.Itoa(h.hooks.mapStatus(statusCode)))
+
+	if !h.bodyAsStatusMessage {
+		h.responseWriter.Header().Set(GrpcMessageHeaderName, "")
+	}
+
+	if statusCode == http.StatusUnauthorized {
+		h.markAuthChallenge()
+	}
+}
+
+// writeGrpcStatus responds with gRPC status generated by middleware itself,
+// in the same way as converted http error, so backend must not be called after it.
+func (h *http2grpcModifier) writeGrpcStatus(code int, message string, details ...pb.Message) {
+	if !h.headerSent {
+		h.sendGrpcHeaders()
+		h.headerSent = true
+	}
+
+	setGrpcStatusTrailers(h.responseWriter.Header(), code, message, details...)
+
+	if _, err := h.responseWriter.Write(EmptyGrpcBody); err != nil {
+		LoggerDEBUG.Printf("writeGrpcStatus() body write failed: %s", err)
+	}
+
+	if h.responseWriterFlusher != nil {
+		h.responseWriterFlusher.Flush()
+	}
+}
+
+// writeGrpcMessage writes one length-prefixed message of response generated by middleware itself.
+func (h *http2grpcModifier) writeGrpcMessage(message []byte) error {
+	if !h.headerSent {
+		h.sendGrpcHeaders()
+		h.headerSent = true
+	}
+
+	if _, err := h.responseWriter.Write(appendGrpcFrame(nil, message)); err != nil {
+		return err
+	}
+
+	if h.responseWriterFlusher != nil {
+		h.responseWriterFlusher.Flush()
+	}
+
+	return nil
+}
+
+func (h *http2grpcModifier) sendGrpcHeaders() {
 	// gRPC status code and message send in trailers because of gRPC implementation over HTTP/2
 	h.responseWriter.Header().Set(TrailerHeaderName, GrpcStatusHeaderName)
 	h.responseWriter.Header().Add(TrailerHeaderName, GrpcMessageHeaderName)
@@ -211,13 +757,6 @@ func (h *http2grpcModifier) convertHTTPToGrpc(statusCode int) {
 
 	// always set HTTP OK because of gRPC implementation over HTTP/2
 	h.responseWriter.WriteHeader(http.StatusOK)
-
-	grpcCodeString := getGrpcStatusCode(statusCode)
-	h.responseWriter.Header().Set(GrpcStatusHeaderName, grpcCodeString)
-
-	if !h.bodyAsStatusMessage {
-		h.responseWriter.Header().Set(GrpcMessageHeaderName, "")
-	}
 }
 
 func (h *http2grpcModifier) checkResponseInGrpcFormat() bool {
@@ -228,15 +767,16 @@ func (h *http2grpcModifier) checkResponseInGrpcFormat() bool {
 	return contentTypeIsGrpc
 }
 
-func getGrpcStatusCode(httpStatusCode int) string {
-	var grpcCodeString string
+// DefaultStatusMapper maps HTTP status code to gRPC one by gRPC spec, unknown codes are mapped to UNKNOWN.
+func DefaultStatusMapper(httpStatusCode int) int {
+	var grpcCode int
 	if httpStatusCode == http.StatusOK {
-		grpcCodeString = strconv.Itoa(grpc.OK)
-	} else if grpcCode, ok := grpc.HTTP2grpc[httpStatusCode]; ok {
-		grpcCodeString = strconv.Itoa(grpcCode)
+		grpcCode = grpc.OK
+	} else if code, ok := grpc.HTTP2grpc[httpStatusCode]; ok {
+		grpcCode = code
 	} else {
-		grpcCodeString = strconv.Itoa(grpc.UNKNOWN)
+		grpcCode = grpc.UNKNOWN
 	}
 
-	return grpcCodeString
+	return grpcCode
 }
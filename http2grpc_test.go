@@ -1,12 +1,14 @@
 package http2grpc_test
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/v-electrolux/http2grpc"
@@ -442,3 +444,118 @@ func assertTrailer(t *testing.T, res *http.Response, key string, expected string
 		t.Errorf("expected trailer %s value: `%s`, got value: `%s`", key, expected, got)
 	}
 }
+
+type TestNotOkGrpcResData struct {
+	cfgBodyAsStatusMessage bool
+
+	backendHTTPResStatusCode int
+	backendGrpcResBody       []byte
+	backendGrpcResTrailers   map[string]string
+
+	expGrpcResStatusCode int
+	expGrpcResStatusMsg  string
+}
+
+func TestServiceUnavailableGrpcWithoutTrailersEnabledBodyAsMsg(t *testing.T) {
+	data := TestNotOkGrpcResData{
+		cfgBodyAsStatusMessage: true,
+
+		backendHTTPResStatusCode: 503,
+		backendGrpcResBody:       []byte("no healthy upstream"),
+
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "no healthy upstream",
+	}
+	testNotOkGrpcRequest(t, data)
+}
+
+func TestServiceUnavailableGrpcWithoutTrailersDisabledBodyAsMsg(t *testing.T) {
+	data := TestNotOkGrpcResData{
+		cfgBodyAsStatusMessage: false,
+
+		backendHTTPResStatusCode: 503,
+		backendGrpcResBody:       []byte("no healthy upstream"),
+
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "",
+	}
+	testNotOkGrpcRequest(t, data)
+}
+
+func TestNotFoundGrpcWithoutTrailers(t *testing.T) {
+	data := TestNotOkGrpcResData{
+		backendHTTPResStatusCode: 404,
+
+		expGrpcResStatusCode: 12,
+		expGrpcResStatusMsg:  "",
+	}
+	testNotOkGrpcRequest(t, data)
+}
+
+func TestServiceUnavailableGrpcWithTrailers(t *testing.T) {
+	data := TestNotOkGrpcResData{
+		cfgBodyAsStatusMessage: true,
+
+		backendHTTPResStatusCode: 503,
+		backendGrpcResTrailers: map[string]string{
+			"grpc-status":  "8",
+			"grpc-message": "quota exceeded",
+		},
+
+		expGrpcResStatusCode: 8,
+		expGrpcResStatusMsg:  "quota exceeded",
+	}
+	testNotOkGrpcRequest(t, data)
+}
+
+func TestServiceUnavailableGrpcWithoutTrailersLongBody(t *testing.T) {
+	data := TestNotOkGrpcResData{
+		cfgBodyAsStatusMessage: true,
+
+		backendHTTPResStatusCode: 503,
+		backendGrpcResBody:       bytes.Repeat([]byte("a"), 100*1024),
+
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  strings.Repeat("a", 256) + "...",
+	}
+	testNotOkGrpcRequest(t, data)
+}
+
+func testNotOkGrpcRequest(t *testing.T, data TestNotOkGrpcResData) {
+	t.Helper()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.BodyAsStatusMessage = data.cfgBodyAsStatusMessage
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/grpc")
+		rw.WriteHeader(data.backendHTTPResStatusCode)
+		rw.Write(data.backendGrpcResBody)
+
+		for key, value := range data.backendGrpcResTrailers {
+			rw.Header().Set(http.TrailerPrefix+key, value)
+		}
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertBody(t, resp, []byte{})
+	assertHeader(t, resp, "Content-Type", "application/grpc")
+	assertTrailer(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+	assertTrailer(t, resp, "grpc-message", data.expGrpcResStatusMsg)
+}
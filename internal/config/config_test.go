@@ -0,0 +1,256 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/v-electrolux/http2grpc/internal/config"
+)
+
+type testRule struct {
+	Methods []string `yaml:"methods"`
+	Average int      `yaml:"average"`
+	Period  string   `yaml:"period"`
+}
+
+type testSection struct {
+	Enabled    bool              `yaml:"enabled"`
+	Multiplier float64           `yaml:"multiplier"`
+	Headers    map[string]string `yaml:"headers"`
+}
+
+type testConfig struct {
+	Name    string      `yaml:"name"`
+	Size    int         `yaml:"size"`
+	Rules   []testRule  `yaml:"rules"`
+	Section testSection `yaml:"section"`
+	Body    string      `yaml:"body"`
+	Kept    string      `yaml:"kept"`
+}
+
+type TestParseData struct {
+	format string
+	doc    string
+
+	expErr    string
+	expConfig testConfig
+}
+
+func TestYAMLBlockCollections(t *testing.T) {
+	data := TestParseData{
+		format: "yaml",
+		doc: `---
+# comment
+name: "quoted # not comment" # comment
+size: 0x10
+rules:
+- methods:
+    - /a.A/*
+    - '/b.B/Get'
+  average: 5
+  period: 1s
+-   methods: [/c.C/*, "/d.D/*"]
+    average: 7
+section:
+  enabled: yes
+  multiplier: 1.5
+  headers: {x-a: "1", x-b: two}
+body: |
+  line 1
+    indented
+
+  line 3
+`,
+		expConfig: testConfig{
+			Name: "quoted # not comment",
+			Size: 16,
+			Rules: []testRule{
+				{Methods: []string{"/a.A/*", "/b.B/Get"}, Average: 5, Period: "1s"},
+				{Methods: []string{"/c.C/*", "/d.D/*"}, Average: 7},
+			},
+			Section: testSection{
+				Enabled: true, Multiplier: 1.5, Headers: map[string]string{"x-a": "1", "x-b": "two"},
+			},
+			Body: "line 1\n  indented\n\nline 3\n",
+			Kept: "default",
+		},
+	}
+	testParse(t, data)
+}
+
+func TestYAMLFoldedScalarAndMultilineFlow(t *testing.T) {
+	data := TestParseData{
+		format: "yaml",
+		doc: `body: >-
+  folded
+  text
+
+  para
+rules: [
+  {methods: [/a.A/*], average: 1},
+  {average: 2}
+]
+name: 'it''s'
+`,
+		expConfig: testConfig{
+			Name:  "it's",
+			Rules: []testRule{{Methods: []string{"/a.A/*"}, Average: 1}, {Average: 2}},
+			Body:  "folded text\npara",
+			Kept:  "default",
+		},
+	}
+	testParse(t, data)
+}
+
+func TestYAMLUnknownKey(t *testing.T) {
+	data := TestParseData{
+		format: "yaml",
+		doc:    "section:\n  enabled: true\n  unknown: 1\n",
+		expErr: "config: section.unknown: unknown key",
+	}
+	testParse(t, data)
+}
+
+func TestYAMLWrongType(t *testing.T) {
+	data := TestParseData{
+		format: "yaml",
+		doc:    "rules:\n  - average: many\n",
+		expErr: `config: rules[0].average: expected integer, got "many"`,
+	}
+	testParse(t, data)
+}
+
+func TestYAMLBadIndentation(t *testing.T) {
+	data := TestParseData{
+		format: "yaml",
+		doc:    "section:\n    enabled: true\n  multiplier: 2\n",
+		expErr: "config: yaml: line 3: bad indentation of mapping entry",
+	}
+	testParse(t, data)
+}
+
+func TestYAMLDuplicateKey(t *testing.T) {
+	data := TestParseData{
+		format: "yaml",
+		doc:    "name: a\nname: b\n",
+		expErr: `config: yaml: line 2: duplicate key "name"`,
+	}
+	testParse(t, data)
+}
+
+func TestTOMLTables(t *testing.T) {
+	data := TestParseData{
+		format: "toml",
+		doc: `# comment
+name = "esc\"aped!" # comment
+size = 1_024
+
+[section]
+enabled = true
+multiplier = 2.5
+headers = { x-a = "1", "x b" = 'raw\n' }
+
+[[rules]]
+methods = [
+  "/a.A/*", # comment
+  '/b.B/Get',
+]
+average = 5
+period = "1s"
+
+[[rules]]
+average = 7
+`,
+		expConfig: testConfig{
+			Name: `esc"aped!`,
+			Size: 1024,
+			Rules: []testRule{
+				{Methods: []string{"/a.A/*", "/b.B/Get"}, Average: 5, Period: "1s"},
+				{Average: 7},
+			},
+			Section: testSection{
+				Enabled: true, Multiplier: 2.5, Headers: map[string]string{"x-a": "1", "x b": `raw\n`},
+			},
+			Kept: "default",
+		},
+	}
+	testParse(t, data)
+}
+
+func TestTOMLDottedKeysAndMultilineStrings(t *testing.T) {
+	data := TestParseData{
+		format: "toml",
+		doc: `section.enabled = true
+section.headers.x-a = "1"
+body = """
+first \
+  second
+"""
+kept = '''raw\n'''
+`,
+		expConfig: testConfig{
+			Section: testSection{Enabled: true, Headers: map[string]string{"x-a": "1"}},
+			Body:    "first second\n",
+			Kept:    `raw\n`,
+		},
+	}
+	testParse(t, data)
+}
+
+func TestTOMLTableDefinedTwice(t *testing.T) {
+	data := TestParseData{
+		format: "toml",
+		doc:    "[section]\nenabled = true\n\n[section]\nmultiplier = 1\n",
+		expErr: "config: toml: line 4: table section is defined twice",
+	}
+	testParse(t, data)
+}
+
+func TestTOMLInvalidValue(t *testing.T) {
+	data := TestParseData{
+		format: "toml",
+		doc:    "size = many\n",
+		expErr: `config: toml: line 1: invalid value "many"`,
+	}
+	testParse(t, data)
+}
+
+func TestUnsupportedExtension(t *testing.T) {
+	data := TestParseData{
+		format: "json",
+		doc:    "{}",
+		expErr: `config: unsupported file extension ".json"`,
+	}
+	testParse(t, data)
+}
+
+func testParse(t *testing.T, data TestParseData) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config."+data.format)
+	if err := os.WriteFile(path, []byte(data.doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := testConfig{Kept: "default"}
+
+	err := config.Load(path, &cfg)
+	if data.expErr != "" {
+		if err == nil || !strings.HasPrefix(err.Error(), data.expErr) {
+			t.Fatalf("expected error %q, got %v", data.expErr, err)
+		}
+
+		return
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(cfg, data.expConfig) {
+		t.Errorf("expected config %+v, got %+v", data.expConfig, cfg)
+	}
+}
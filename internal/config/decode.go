@@ -0,0 +1,261 @@
+// Package config decodes YAML and TOML configuration files into structs with `yaml` field tags,
+// so standalone binaries share the configuration schema of the traefik plugin without third-party dependencies.
+// Only the subset of both formats needed for configuration files is supported.
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Decode assigns parsed document, made of map[string]interface{}, []interface{} and scalars, to struct pointer v.
+// Fields are matched by `yaml` tag, unknown keys are errors, absent keys keep current values.
+func Decode(doc interface{}, v interface{}) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("config: decode target must be non-nil pointer, got %T", v)
+	}
+
+	return decodeValue(doc, value.Elem(), "")
+}
+
+func decodeValue(doc interface{}, value reflect.Value, path string) error {
+	if doc == nil {
+		return nil
+	}
+
+	switch value.Kind() { //nolint:exhaustive // only kinds used in configuration are supported
+	case reflect.Struct:
+		return decodeStruct(doc, value, path)
+	case reflect.Map:
+		return decodeMap(doc, value, path)
+	case reflect.Slice:
+		return decodeSlice(doc, value, path)
+	case reflect.Ptr:
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+
+		return decodeValue(doc, value.Elem(), path)
+	case reflect.String:
+		s, err := scalarString(doc, path)
+		if err != nil {
+			return err
+		}
+
+		value.SetString(s)
+	case reflect.Bool:
+		b, err := scalarBool(doc, path)
+		if err != nil {
+			return err
+		}
+
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := scalarInt(doc, path)
+		if err != nil {
+			return err
+		}
+
+		if value.OverflowInt(i) {
+			return fmt.Errorf("config: %s: %d overflows %s", path, i, value.Type())
+		}
+
+		value.SetInt(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := scalarFloat(doc, path)
+		if err != nil {
+			return err
+		}
+
+		value.SetFloat(f)
+	default:
+		return fmt.Errorf("config: %s: unsupported type %s", path, value.Type())
+	}
+
+	return nil
+}
+
+func decodeStruct(doc interface{}, value reflect.Value, path string) error {
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("config: %s: expected mapping, got %s", displayPath(path), describe(doc))
+	}
+
+	fields := make(map[string]int, value.NumField())
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		fields[fieldName(field)] = i
+	}
+
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	// sorted, so the first error is deterministic
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		i, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("config: %s: unknown key", joinPath(path, key))
+		}
+
+		if err := decodeValue(m[key], value.Field(i), joinPath(path, key)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func decodeMap(doc interface{}, value reflect.Value, path string) error {
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("config: %s: expected mapping, got %s", displayPath(path), describe(doc))
+	}
+
+	if value.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("config: %s: unsupported map key type %s", displayPath(path), value.Type().Key())
+	}
+
+	if value.IsNil() {
+		value.Set(reflect.MakeMapWithSize(value.Type(), len(m)))
+	}
+
+	for key, item := range m {
+		elem := reflect.New(value.Type().Elem()).Elem()
+		if err := decodeValue(item, elem, joinPath(path, key)); err != nil {
+			return err
+		}
+
+		value.SetMapIndex(reflect.ValueOf(key).Convert(value.Type().Key()), elem)
+	}
+
+	return nil
+}
+
+func decodeSlice(doc interface{}, value reflect.Value, path string) error {
+	items, ok := doc.([]interface{})
+	if !ok {
+		return fmt.Errorf("config: %s: expected sequence, got %s", displayPath(path), describe(doc))
+	}
+
+	slice := reflect.MakeSlice(value.Type(), len(items), len(items))
+
+	for i, item := range items {
+		if err := decodeValue(item, slice.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+			return err
+		}
+	}
+
+	value.Set(slice)
+
+	return nil
+}
+
+func scalarString(doc interface{}, path string) (string, error) {
+	switch s := doc.(type) {
+	case string:
+		return s, nil
+	case bool:
+		return strconv.FormatBool(s), nil
+	case int64:
+		return strconv.FormatInt(s, 10), nil
+	case float64:
+		return strconv.FormatFloat(s, 'g', -1, 64), nil
+	default:
+		return "", fmt.Errorf("config: %s: expected string, got %s", displayPath(path), describe(doc))
+	}
+}
+
+func scalarBool(doc interface{}, path string) (bool, error) {
+	switch b := doc.(type) {
+	case bool:
+		return b, nil
+	case string:
+		switch strings.ToLower(b) {
+		case "true", "yes", "on":
+			return true, nil
+		case "false", "no", "off":
+			return false, nil
+		}
+	}
+
+	return false, fmt.Errorf("config: %s: expected bool, got %s", displayPath(path), describe(doc))
+}
+
+func scalarInt(doc interface{}, path string) (int64, error) {
+	switch i := doc.(type) {
+	case int64:
+		return i, nil
+	case string:
+		if parsed, err := strconv.ParseInt(strings.ReplaceAll(i, "_", ""), 0, 64); err == nil {
+			return parsed, nil
+		}
+	}
+
+	return 0, fmt.Errorf("config: %s: expected integer, got %s", displayPath(path), describe(doc))
+}
+
+func scalarFloat(doc interface{}, path string) (float64, error) {
+	switch f := doc.(type) {
+	case float64:
+		return f, nil
+	case int64:
+		return float64(f), nil
+	case string:
+		if parsed, err := strconv.ParseFloat(strings.ReplaceAll(f, "_", ""), 64); err == nil {
+			return parsed, nil
+		}
+	}
+
+	return 0, fmt.Errorf("config: %s: expected number, got %s", displayPath(path), describe(doc))
+}
+
+// fieldName returns name of field in `yaml` tag, or field name with lower first letter if there is no tag.
+func fieldName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("yaml"), ",")[0]; name != "" {
+		return name
+	}
+
+	return strings.ToLower(field.Name[:1]) + field.Name[1:]
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "document"
+	}
+
+	return path
+}
+
+func describe(doc interface{}) string {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		return "mapping"
+	case []interface{}:
+		return "sequence"
+	case string:
+		return strconv.Quote(v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
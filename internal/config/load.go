@@ -0,0 +1,33 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Load reads configuration file into struct pointer v, format is chosen by extension: .yaml, .yml or .toml.
+func Load(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	var doc interface{}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		doc, err = ParseYAML(data)
+	case ".toml":
+		doc, err = ParseTOML(data)
+	default:
+		return fmt.Errorf("config: unsupported file extension %q of %s, expected .yaml, .yml or .toml", ext, path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w (%s)", err, path)
+	}
+
+	return Decode(doc, v)
+}
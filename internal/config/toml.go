@@ -0,0 +1,553 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseTOML parses TOML document into map[string]interface{}, []interface{} and scalars.
+// Tables, arrays of tables, dotted keys, strings, integers, floats, booleans, arrays and inline tables are supported,
+// dates and times are returned as strings.
+func ParseTOML(data []byte) (interface{}, error) {
+	root := make(map[string]interface{})
+	p := &tomlParser{data: strings.ReplaceAll(string(data), "\r\n", "\n"), current: root, defined: map[string]bool{}}
+
+	for {
+		p.skipSpaceAndComments(true)
+
+		if p.pos >= len(p.data) {
+			return root, nil
+		}
+
+		var err error
+
+		if p.data[p.pos] == '[' {
+			err = p.parseTableHeader(root)
+		} else {
+			err = p.parseKeyValue(p.current)
+		}
+
+		if err != nil {
+			return nil, p.wrap(err)
+		}
+
+		if err = p.expectLineEnd(); err != nil {
+			return nil, p.wrap(err)
+		}
+	}
+}
+
+type tomlParser struct {
+	data    string
+	pos     int
+	current map[string]interface{}
+	// defined are paths of tables defined by headers, a table can be defined only once
+	defined map[string]bool
+}
+
+func (p *tomlParser) wrap(err error) error {
+	return fmt.Errorf("config: toml: line %d: %w", strings.Count(p.data[:p.pos], "\n")+1, err)
+}
+
+func (p *tomlParser) peek(prefix string) bool {
+	return strings.HasPrefix(p.data[p.pos:], prefix)
+}
+
+// skipSpaceAndComments skips spaces and comments, and line breaks too if newlines is set.
+func (p *tomlParser) skipSpaceAndComments(newlines bool) {
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case c == ' ' || c == '\t' || (newlines && c == '\n'):
+			p.pos++
+		case c == '#':
+			if end := strings.IndexByte(p.data[p.pos:], '\n'); end >= 0 {
+				p.pos += end
+			} else {
+				p.pos = len(p.data)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) expectLineEnd() error {
+	p.skipSpaceAndComments(false)
+
+	if p.pos < len(p.data) && p.data[p.pos] != '\n' {
+		return fmt.Errorf("expected end of line, got %q", p.data[p.pos])
+	}
+
+	return nil
+}
+
+func (p *tomlParser) parseTableHeader(root map[string]interface{}) error {
+	array := p.peek("[[")
+	if array {
+		p.pos += 2
+	} else {
+		p.pos++
+	}
+
+	path, err := p.parseKeyPath()
+	if err != nil {
+		return err
+	}
+
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+
+	if !p.peek(closing) {
+		return fmt.Errorf("expected %q after table name", closing)
+	}
+
+	p.pos += len(closing)
+
+	parent, err := walkTOMLTables(root, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+
+	name := strings.Join(path, ".")
+	last := path[len(path)-1]
+
+	if array {
+		table := make(map[string]interface{})
+
+		switch existing := parent[last].(type) {
+		case nil:
+			parent[last] = []interface{}{table}
+		case []interface{}:
+			if len(existing) > 0 {
+				if _, ok := existing[0].(map[string]interface{}); !ok {
+					return fmt.Errorf("%s is not an array of tables", name)
+				}
+			}
+
+			parent[last] = append(existing, table)
+		default:
+			return fmt.Errorf("%s is not an array of tables", name)
+		}
+
+		p.current = table
+
+		// subtables of the previous element may be defined again for the new one
+		for defined := range p.defined {
+			if strings.HasPrefix(defined, name+".") {
+				delete(p.defined, defined)
+			}
+		}
+
+		return nil
+	}
+
+	if p.defined[name] {
+		return fmt.Errorf("table %s is defined twice", name)
+	}
+
+	p.defined[name] = true
+
+	switch existing := parent[last].(type) {
+	case nil:
+		table := make(map[string]interface{})
+		parent[last] = table
+		p.current = table
+	case map[string]interface{}:
+		p.current = existing
+	default:
+		return fmt.Errorf("%s is not a table", name)
+	}
+
+	return nil
+}
+
+// walkTOMLTables returns table at path, creating missing ones, the last table of array of tables is used.
+func walkTOMLTables(table map[string]interface{}, path []string) (map[string]interface{}, error) {
+	for i, key := range path {
+		switch existing := table[key].(type) {
+		case nil:
+			child := make(map[string]interface{})
+			table[key] = child
+			table = child
+		case map[string]interface{}:
+			table = existing
+		case []interface{}:
+			last, ok := interface{}(nil), false
+			if len(existing) > 0 {
+				last = existing[len(existing)-1]
+			}
+
+			if table, ok = last.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("%s is not a table", strings.Join(path[:i+1], "."))
+			}
+		default:
+			return nil, fmt.Errorf("%s is not a table", strings.Join(path[:i+1], "."))
+		}
+	}
+
+	return table, nil
+}
+
+func (p *tomlParser) parseKeyValue(table map[string]interface{}) error {
+	path, err := p.parseKeyPath()
+	if err != nil {
+		return err
+	}
+
+	if !p.peek("=") {
+		return fmt.Errorf("expected '=' after key %s", strings.Join(path, "."))
+	}
+
+	p.pos++
+	p.skipSpaceAndComments(false)
+
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	parent, err := walkTOMLTables(table, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+
+	last := path[len(path)-1]
+	if _, ok := parent[last]; ok {
+		return fmt.Errorf("key %s is defined twice", strings.Join(path, "."))
+	}
+
+	parent[last] = value
+
+	return nil
+}
+
+// parseKeyPath parses bare, quoted and dotted keys with surrounding spaces.
+func (p *tomlParser) parseKeyPath() ([]string, error) {
+	var path []string
+
+	for {
+		p.skipSpaceAndComments(false)
+
+		var key string
+
+		switch {
+		case p.peek(`"`) || p.peek("'"):
+			value, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+
+			key = value
+		default:
+			start := p.pos
+			for p.pos < len(p.data) && isTOMLBareKeyChar(p.data[p.pos]) {
+				p.pos++
+			}
+
+			if start == p.pos {
+				return nil, errors.New("expected key")
+			}
+
+			key = p.data[start:p.pos]
+		}
+
+		path = append(path, key)
+
+		p.skipSpaceAndComments(false)
+
+		if !p.peek(".") {
+			return path, nil
+		}
+
+		p.pos++
+	}
+}
+
+func isTOMLBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *tomlParser) parseValue() (interface{}, error) {
+	if p.pos >= len(p.data) {
+		return nil, errors.New("expected value")
+	}
+
+	switch p.data[p.pos] {
+	case '"', '\'':
+		return p.parseString()
+	case '[':
+		return p.parseArray()
+	case '{':
+		return p.parseInlineTable()
+	default:
+		start := p.pos
+		for p.pos < len(p.data) && strings.IndexByte(" \t\n#,]}", p.data[p.pos]) < 0 {
+			p.pos++
+		}
+
+		// date and time may be separated by space
+		if p.pos+1 < len(p.data) && p.data[p.pos] == ' ' && isTOMLDate(p.data[start:p.pos]) &&
+			p.data[p.pos+1] >= '0' && p.data[p.pos+1] <= '9' {
+			p.pos++
+			for p.pos < len(p.data) && strings.IndexByte(" \t\n#,]}", p.data[p.pos]) < 0 {
+				p.pos++
+			}
+		}
+
+		return parseTOMLScalar(p.data[start:p.pos])
+	}
+}
+
+func isTOMLDate(token string) bool {
+	return len(token) == 10 && token[4] == '-' && token[7] == '-'
+}
+
+func parseTOMLScalar(token string) (interface{}, error) {
+	switch token {
+	case "":
+		return nil, errors.New("expected value")
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "inf", "+inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	case "nan", "+nan", "-nan":
+		return math.NaN(), nil
+	}
+
+	// dates and times are passed as strings
+	if isTOMLDate(token) || (len(token) >= 10 && isTOMLDate(token[:10])) || strings.Count(token, ":") == 2 {
+		return token, nil
+	}
+
+	digits := strings.ReplaceAll(token, "_", "")
+
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0o") || strings.HasPrefix(digits, "0b") {
+		if i, err := strconv.ParseInt(digits, 0, 64); err == nil {
+			return i, nil
+		}
+	} else if i, err := strconv.ParseInt(digits, 10, 64); err == nil {
+		return i, nil
+	}
+
+	if !strings.ContainsAny(digits, "xXpP") {
+		if f, err := strconv.ParseFloat(digits, 64); err == nil {
+			return f, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid value %q", token)
+}
+
+func (p *tomlParser) parseArray() (interface{}, error) {
+	p.pos++
+
+	items := []interface{}{}
+
+	for {
+		p.skipSpaceAndComments(true)
+
+		if p.peek("]") {
+			p.pos++
+
+			return items, nil
+		}
+
+		item, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+
+		p.skipSpaceAndComments(true)
+
+		switch {
+		case p.peek(","):
+			p.pos++
+		case p.peek("]"):
+		default:
+			return nil, errors.New("expected ',' or ']' in array")
+		}
+	}
+}
+
+func (p *tomlParser) parseInlineTable() (interface{}, error) {
+	p.pos++
+
+	table := make(map[string]interface{})
+
+	p.skipSpaceAndComments(false)
+
+	if p.peek("}") {
+		p.pos++
+
+		return table, nil
+	}
+
+	for {
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+
+		p.skipSpaceAndComments(false)
+
+		switch {
+		case p.peek(","):
+			p.pos++
+		case p.peek("}"):
+			p.pos++
+
+			return table, nil
+		default:
+			return nil, errors.New("expected ',' or '}' in inline table")
+		}
+	}
+}
+
+// parseString parses basic, literal and multi-line strings.
+func (p *tomlParser) parseString() (string, error) {
+	switch {
+	case p.peek(`"""`):
+		return p.parseMultilineString(`"""`)
+	case p.peek("'''"):
+		return p.parseMultilineString("'''")
+	case p.peek("'"):
+		end := strings.IndexAny(p.data[p.pos+1:], "'\n")
+		if end < 0 || p.data[p.pos+1+end] != '\'' {
+			return "", errors.New("unterminated literal string")
+		}
+
+		s := p.data[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+
+		return s, nil
+	default:
+		p.pos++
+
+		var b strings.Builder
+
+		for p.pos < len(p.data) {
+			c := p.data[p.pos]
+
+			switch c {
+			case '"':
+				p.pos++
+
+				return b.String(), nil
+			case '\n':
+				return "", errors.New("unterminated string")
+			case '\\':
+				if err := p.parseEscape(&b); err != nil {
+					return "", err
+				}
+			default:
+				b.WriteByte(c)
+				p.pos++
+			}
+		}
+
+		return "", errors.New("unterminated string")
+	}
+}
+
+func (p *tomlParser) parseMultilineString(delimiter string) (string, error) {
+	p.pos += len(delimiter)
+
+	// line break right after the opening delimiter is trimmed
+	if p.peek("\n") {
+		p.pos++
+	}
+
+	var b strings.Builder
+
+	for p.pos < len(p.data) {
+		if p.peek(delimiter) {
+			p.pos += len(delimiter)
+
+			// up to two quotes before the closing delimiter are content
+			for i := 0; i < 2 && p.peek(delimiter[:1]); i++ {
+				b.WriteByte(delimiter[0])
+				p.pos++
+			}
+
+			return b.String(), nil
+		}
+
+		c := p.data[p.pos]
+
+		switch {
+		case c == '\\' && delimiter == `"""`:
+			if p.pos+1 < len(p.data) && strings.IndexByte(" \t\n", p.data[p.pos+1]) >= 0 {
+				// line ending backslash trims the line break and following whitespace
+				rest := strings.TrimLeft(p.data[p.pos+1:], " \t")
+				if !strings.HasPrefix(rest, "\n") {
+					return "", errors.New("invalid escape in string")
+				}
+
+				p.pos = len(p.data) - len(strings.TrimLeft(rest, " \t\n"))
+
+				continue
+			}
+
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+
+	return "", errors.New("unterminated multi-line string")
+}
+
+// parseEscape parses escape sequence of basic string at the current backslash.
+func (p *tomlParser) parseEscape(b *strings.Builder) error {
+	if p.pos+1 >= len(p.data) {
+		return errors.New("unterminated string")
+	}
+
+	escapes := map[byte]string{'b': "\b", 't': "\t", 'n': "\n", 'f': "\f", 'r': "\r", '"': `"`, '\\': `\`}
+
+	c := p.data[p.pos+1]
+	if s, ok := escapes[c]; ok {
+		b.WriteString(s)
+		p.pos += 2
+
+		return nil
+	}
+
+	size := 0
+
+	switch c {
+	case 'u':
+		size = 4
+	case 'U':
+		size = 8
+	default:
+		return fmt.Errorf("invalid escape \\%c in string", c)
+	}
+
+	if p.pos+2+size > len(p.data) {
+		return errors.New("invalid unicode escape in string")
+	}
+
+	code, err := strconv.ParseUint(p.data[p.pos+2:p.pos+2+size], 16, 32)
+	if err != nil {
+		return errors.New("invalid unicode escape in string")
+	}
+
+	b.WriteRune(rune(code))
+	p.pos += 2 + size
+
+	return nil
+}
@@ -0,0 +1,628 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseYAML parses YAML document into map[string]interface{}, []interface{} and scalars.
+// Block and flow collections, plain and quoted scalars, comments and literal or folded block scalars are supported,
+// anchors, tags and multiple documents are not.
+func ParseYAML(data []byte) (interface{}, error) {
+	p := &yamlParser{}
+
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		text := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("config: yaml: line %d: tabs are not allowed in indentation", i+1)
+		}
+
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(raw) - len(text), text: text, raw: raw})
+	}
+
+	p.skipDirectives()
+
+	if !p.next() {
+		return nil, nil
+	}
+
+	doc, err := p.parseNode(p.lines[p.pos].indent)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.next() && p.lines[p.pos].text != "..." {
+		return nil, p.errorf("unexpected content")
+	}
+
+	return doc, nil
+}
+
+type yamlLine struct {
+	num    int
+	indent int
+	// text is the line without indentation
+	text string
+	raw  string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// next skips blank and comment lines and reports whether there is a line left.
+func (p *yamlParser) next() bool {
+	for ; p.pos < len(p.lines); p.pos++ {
+		text := p.lines[p.pos].text
+		if text != "" && !strings.HasPrefix(text, "#") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *yamlParser) skipDirectives() {
+	for p.next() {
+		text := p.lines[p.pos].text
+		if !strings.HasPrefix(text, "%") && stripYAMLComment(text) != "---" {
+			return
+		}
+
+		p.pos++
+	}
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	num := len(p.lines)
+	if p.pos < len(p.lines) {
+		num = p.lines[p.pos].num
+	}
+
+	return fmt.Errorf("config: yaml: line %d: %s", num, fmt.Sprintf(format, args...))
+}
+
+// parseNode parses block node starting at the current line with given indent.
+func (p *yamlParser) parseNode(indent int) (interface{}, error) {
+	text := p.lines[p.pos].text
+
+	switch {
+	case isYAMLSequenceItem(text):
+		return p.parseSequence(indent)
+	case strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{"):
+		return p.parseInlineValue(text)
+	case findYAMLMappingColon(text) >= 0:
+		return p.parseMapping(indent)
+	default:
+		return p.parseInlineValue(text)
+	}
+}
+
+func (p *yamlParser) parseSequence(indent int) ([]interface{}, error) {
+	items := []interface{}{}
+
+	for p.next() {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+
+		if line.indent > indent {
+			return nil, p.errorf("bad indentation of sequence item")
+		}
+
+		if !isYAMLSequenceItem(line.text) {
+			break
+		}
+
+		rest := strings.TrimLeft(line.text[1:], " ")
+		if stripYAMLComment(rest) == "" {
+			p.pos++
+
+			item, err := p.parseChild(indent)
+			if err != nil {
+				return nil, err
+			}
+
+			items = append(items, item)
+
+			continue
+		}
+
+		// content after the dash is parsed as a node indented to its column, so "- key: value" starts a mapping
+		itemIndent := line.indent + len(line.text) - len(rest)
+		p.lines[p.pos] = yamlLine{num: line.num, indent: itemIndent, text: rest, raw: line.raw}
+
+		item, err := p.parseNode(itemIndent)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func (p *yamlParser) parseMapping(indent int) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+
+	for p.next() {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+
+		if line.indent > indent {
+			return nil, p.errorf("bad indentation of mapping entry")
+		}
+
+		if isYAMLSequenceItem(line.text) {
+			break
+		}
+
+		colon := findYAMLMappingColon(line.text)
+		if colon < 0 {
+			return nil, p.errorf("expected mapping key, got %q", line.text)
+		}
+
+		key, err := parseYAMLKey(line.text[:colon])
+		if err != nil {
+			return nil, p.errorf("%s", err)
+		}
+
+		if _, ok := m[key]; ok {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+
+		rest := strings.TrimLeft(line.text[colon+1:], " ")
+
+		switch value := stripYAMLComment(rest); {
+		case value == "":
+			p.pos++
+
+			if m[key], err = p.parseChild(indent); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">"):
+			if m[key], err = p.parseBlockScalar(indent, value); err != nil {
+				return nil, err
+			}
+		default:
+			if m[key], err = p.parseInlineValue(rest); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// parseChild parses value of key or dash on its own line: nested node or null,
+// sequence of mapping entry may have the same indent as the key.
+func (p *yamlParser) parseChild(indent int) (interface{}, error) {
+	if !p.next() {
+		return nil, nil
+	}
+
+	line := p.lines[p.pos]
+
+	switch {
+	case line.indent > indent:
+		return p.parseNode(line.indent)
+	case line.indent == indent && isYAMLSequenceItem(line.text) && !p.inSequence(indent):
+		return p.parseSequence(indent)
+	default:
+		return nil, nil
+	}
+}
+
+// inSequence reports whether the line before the current one is an item of sequence with given indent,
+// in that case a dash at the same indent is the next item of it.
+func (p *yamlParser) inSequence(indent int) bool {
+	for i := p.pos - 1; i >= 0; i-- {
+		line := p.lines[i]
+		if line.text == "" || strings.HasPrefix(line.text, "#") {
+			continue
+		}
+
+		return isYAMLSequenceItem(strings.TrimLeft(line.raw, " ")) && len(line.raw)-len(strings.TrimLeft(line.raw, " ")) == indent
+	}
+
+	return false
+}
+
+// parseInlineValue parses flow collection or scalar, flow collection may continue on the following lines.
+func (p *yamlParser) parseInlineValue(text string) (interface{}, error) {
+	p.pos++
+
+	if !strings.HasPrefix(text, "[") && !strings.HasPrefix(text, "{") {
+		return parseYAMLScalar(text)
+	}
+
+	for {
+		value, rest, err := parseYAMLFlow(text)
+		if err == nil {
+			if stripYAMLComment(strings.TrimLeft(rest, " ")) != "" {
+				p.pos--
+
+				return nil, p.errorf("unexpected %q after flow collection", rest)
+			}
+
+			return value, nil
+		}
+
+		if err != errYAMLFlowUnterminated || !p.next() { //nolint:errorlint // sentinel is not wrapped
+			p.pos--
+
+			return nil, p.errorf("%s", err)
+		}
+
+		text += " " + stripYAMLComment(p.lines[p.pos].text)
+		p.pos++
+	}
+}
+
+// parseBlockScalar parses literal `|` or folded `>` scalar of mapping entry with given indent.
+func (p *yamlParser) parseBlockScalar(indent int, header string) (string, error) {
+	chomping := byte(0)
+	if len(header) > 1 {
+		chomping = header[1]
+	}
+
+	if len(header) > 2 || (chomping != 0 && chomping != '-' && chomping != '+') {
+		return "", p.errorf("unsupported block scalar header %q", header)
+	}
+
+	p.pos++
+
+	var lines []string
+
+	contentIndent := -1
+
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := p.lines[p.pos]
+		if line.text == "" {
+			lines = append(lines, "")
+
+			continue
+		}
+
+		if line.indent <= indent || (contentIndent >= 0 && line.indent < contentIndent) {
+			break
+		}
+
+		if contentIndent < 0 {
+			contentIndent = line.indent
+		}
+
+		lines = append(lines, line.raw[contentIndent:])
+	}
+
+	trailing := 0
+	for trailing < len(lines) && lines[len(lines)-1-trailing] == "" {
+		trailing++
+	}
+
+	content := lines[:len(lines)-trailing]
+
+	var value string
+
+	if header[0] == '|' {
+		value = strings.Join(content, "\n")
+	} else {
+		value = foldYAMLLines(content)
+	}
+
+	switch {
+	case len(content) == 0:
+		return "", nil
+	case chomping == '-':
+		return value, nil
+	case chomping == '+':
+		return value + strings.Repeat("\n", trailing+1), nil
+	default:
+		return value + "\n", nil
+	}
+}
+
+// foldYAMLLines joins lines of folded scalar with spaces, empty lines become line breaks.
+func foldYAMLLines(lines []string) string {
+	var b strings.Builder
+
+	for i, line := range lines {
+		switch {
+		case line == "":
+			b.WriteByte('\n')
+		case i == 0 || lines[i-1] == "":
+		default:
+			b.WriteByte(' ')
+		}
+
+		b.WriteString(line)
+	}
+
+	return b.String()
+}
+
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// findYAMLMappingColon returns index of colon ending mapping key, -1 if text is not mapping entry.
+func findYAMLMappingColon(text string) int {
+	i := 0
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'") {
+		end := findYAMLQuoteEnd(text)
+		if end < 0 {
+			return -1
+		}
+
+		i = end + 1
+	}
+
+	for ; i < len(text); i++ {
+		switch text[i] {
+		case '#':
+			if i > 0 && text[i-1] == ' ' {
+				return -1
+			}
+		case ':':
+			if i+1 == len(text) || text[i+1] == ' ' {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+func parseYAMLKey(text string) (string, error) {
+	text = strings.TrimRight(text, " ")
+	if text == "" {
+		return "", errors.New("empty mapping key")
+	}
+
+	if text[0] == '"' || text[0] == '\'' {
+		return unquoteYAML(text)
+	}
+
+	return text, nil
+}
+
+// findYAMLQuoteEnd returns index of quote closing quoted scalar at the start of text, -1 if it is not closed.
+func findYAMLQuoteEnd(text string) int {
+	quote := text[0]
+
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i
+		}
+	}
+
+	return -1
+}
+
+func unquoteYAML(text string) (string, error) {
+	end := findYAMLQuoteEnd(text)
+	if end != len(text)-1 {
+		return "", fmt.Errorf("invalid quoted scalar %s", text)
+	}
+
+	if text[0] == '\'' {
+		return strings.ReplaceAll(text[1:end], "''", "'"), nil
+	}
+
+	s, err := strconv.Unquote(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid quoted scalar %s", text)
+	}
+
+	return s, nil
+}
+
+// stripYAMLComment removes comment and trailing spaces from text of plain or quoted scalar.
+func stripYAMLComment(text string) string {
+	start := 0
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'") {
+		if end := findYAMLQuoteEnd(text); end >= 0 {
+			start = end + 1
+		}
+	}
+
+	for i := start; i < len(text); i++ {
+		if text[i] == '#' && (i == 0 || text[i-1] == ' ') {
+			return strings.TrimRight(text[:i], " ")
+		}
+	}
+
+	return strings.TrimRight(text, " ")
+}
+
+func parseYAMLScalar(text string) (interface{}, error) {
+	text = stripYAMLComment(text)
+	if text == "" {
+		return nil, nil
+	}
+
+	if text[0] == '"' || text[0] == '\'' {
+		return unquoteYAML(text)
+	}
+
+	return resolveYAMLPlain(text), nil
+}
+
+// resolveYAMLPlain resolves plain scalar to null, bool, int64, float64 or string by YAML core schema.
+func resolveYAMLPlain(text string) interface{} {
+	switch text {
+	case "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return math.Inf(1)
+	case "-.inf", "-.Inf", "-.INF":
+		return math.Inf(-1)
+	case ".nan", ".NaN", ".NAN":
+		return math.NaN()
+	}
+
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return i
+	}
+
+	if strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0o") {
+		if i, err := strconv.ParseInt(text, 0, 64); err == nil {
+			return i
+		}
+	}
+
+	if strings.ContainsAny(text, "0123456789") && !strings.ContainsAny(text, "xXpP_") {
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f
+		}
+	}
+
+	return text
+}
+
+// errYAMLFlowUnterminated is returned when flow collection continues on the next line.
+var errYAMLFlowUnterminated = errors.New("unterminated flow collection") //nolint:gochecknoglobals // sentinel error
+
+// parseYAMLFlow parses flow collection or scalar at the start of text and returns the rest of text.
+func parseYAMLFlow(text string) (interface{}, string, error) {
+	text = strings.TrimLeft(text, " ")
+	if text == "" {
+		return nil, "", errYAMLFlowUnterminated
+	}
+
+	switch text[0] {
+	case '[':
+		return parseYAMLFlowSequence(text[1:])
+	case '{':
+		return parseYAMLFlowMapping(text[1:])
+	case '"', '\'':
+		end := findYAMLQuoteEnd(text)
+		if end < 0 {
+			return nil, "", errYAMLFlowUnterminated
+		}
+
+		s, err := unquoteYAML(text[:end+1])
+
+		return s, text[end+1:], err
+	default:
+		end := strings.IndexAny(text, ",]}")
+		if end < 0 {
+			end = len(text)
+		}
+
+		// colon followed by space ends key of flow mapping entry
+		if colon := strings.Index(text[:end], ": "); colon >= 0 {
+			end = colon
+		}
+
+		if strings.HasSuffix(text[:end], ":") {
+			end--
+		}
+
+		return resolveYAMLPlain(strings.TrimRight(text[:end], " ")), text[end:], nil
+	}
+}
+
+func parseYAMLFlowSequence(text string) (interface{}, string, error) {
+	items := []interface{}{}
+
+	for {
+		text = strings.TrimLeft(text, " ")
+		if text == "" {
+			return nil, "", errYAMLFlowUnterminated
+		}
+
+		if text[0] == ']' {
+			return items, text[1:], nil
+		}
+
+		item, rest, err := parseYAMLFlow(text)
+		if err != nil {
+			return nil, "", err
+		}
+
+		items = append(items, item)
+
+		if text, err = skipYAMLFlowSeparator(rest, ']'); err != nil {
+			return nil, "", err
+		}
+	}
+}
+
+func parseYAMLFlowMapping(text string) (interface{}, string, error) {
+	m := make(map[string]interface{})
+
+	for {
+		text = strings.TrimLeft(text, " ")
+		if text == "" {
+			return nil, "", errYAMLFlowUnterminated
+		}
+
+		if text[0] == '}' {
+			return m, text[1:], nil
+		}
+
+		key, rest, err := parseYAMLFlow(text)
+		if err != nil {
+			return nil, "", err
+		}
+
+		keyString, ok := key.(string)
+		if !ok {
+			keyString = fmt.Sprint(key)
+		}
+
+		rest = strings.TrimLeft(rest, " ")
+		if !strings.HasPrefix(rest, ":") {
+			return nil, "", fmt.Errorf("expected ':' after flow mapping key %q", keyString)
+		}
+
+		value, rest, err := parseYAMLFlow(rest[1:])
+		if err != nil {
+			return nil, "", err
+		}
+
+		if _, ok := m[keyString]; ok {
+			return nil, "", fmt.Errorf("duplicate key %q", keyString)
+		}
+
+		m[keyString] = value
+
+		if text, err = skipYAMLFlowSeparator(rest, '}'); err != nil {
+			return nil, "", err
+		}
+	}
+}
+
+// skipYAMLFlowSeparator skips comma after flow collection entry, closing bracket is left for the caller.
+func skipYAMLFlowSeparator(text string, closing byte) (string, error) {
+	text = strings.TrimLeft(text, " ")
+
+	switch {
+	case text == "":
+		return "", errYAMLFlowUnterminated
+	case text[0] == ',':
+		return text[1:], nil
+	case text[0] == closing:
+		return text, nil
+	default:
+		return "", fmt.Errorf("unexpected %q in flow collection", text)
+	}
+}
@@ -0,0 +1,301 @@
+package pb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldType is google.protobuf.FieldDescriptorProto.Type enum.
+type FieldType int
+
+// field types from google/protobuf/descriptor.proto.
+const (
+	TypeDouble   FieldType = 1
+	TypeFloat    FieldType = 2
+	TypeInt64    FieldType = 3
+	TypeUint64   FieldType = 4
+	TypeInt32    FieldType = 5
+	TypeFixed64  FieldType = 6
+	TypeFixed32  FieldType = 7
+	TypeBool     FieldType = 8
+	TypeString   FieldType = 9
+	TypeGroup    FieldType = 10
+	TypeMessage  FieldType = 11
+	TypeBytes    FieldType = 12
+	TypeUint32   FieldType = 13
+	TypeEnum     FieldType = 14
+	TypeSfixed32 FieldType = 15
+	TypeSfixed64 FieldType = 16
+	TypeSint32   FieldType = 17
+	TypeSint64   FieldType = 18
+)
+
+const labelRepeated = 3
+
+// FieldDescriptor is the part of google.protobuf.FieldDescriptorProto needed for encoding.
+type FieldDescriptor struct {
+	Name     string
+	JSONName string
+	Number   int
+	Type     FieldType
+	Repeated bool
+	// TypeName is full name of message or enum type without leading dot
+	TypeName string
+}
+
+// MessageDescriptor is the part of google.protobuf.DescriptorProto needed for encoding.
+type MessageDescriptor struct {
+	FullName string
+	Fields   []*FieldDescriptor
+	// MapEntry is whether message is synthesized entry of map field
+	MapEntry bool
+}
+
+// EnumDescriptor is the part of google.protobuf.EnumDescriptorProto needed for encoding.
+type EnumDescriptor struct {
+	FullName string
+	Values   map[string]int32
+}
+
+// Descriptors indexes message and enum types of google.protobuf.FileDescriptorSet by full name.
+type Descriptors struct {
+	messages map[string]*MessageDescriptor
+	enums    map[string]*EnumDescriptor
+}
+
+// ParseFileDescriptorSet decodes google.protobuf.FileDescriptorSet,
+// e.g. produced by `protoc --include_imports --descriptor_set_out`.
+func ParseFileDescriptorSet(b []byte) (*Descriptors, error) {
+	descriptors := &Descriptors{
+		messages: make(map[string]*MessageDescriptor),
+		enums:    make(map[string]*EnumDescriptor),
+	}
+
+	fields, err := ParseFields(b)
+	if err != nil {
+		return nil, fmt.Errorf("pb: FileDescriptorSet: %w", err)
+	}
+
+	for _, field := range fields {
+		if field.Num != 1 || field.Type != WireBytes {
+			continue
+		}
+
+		if err = descriptors.addFile(field.Bytes); err != nil {
+			return nil, err
+		}
+	}
+
+	return descriptors, nil
+}
+
+// Message returns descriptor of message type by full name, leading dot is optional.
+func (d *Descriptors) Message(name string) *MessageDescriptor {
+	return d.messages[strings.TrimPrefix(name, ".")]
+}
+
+// Enum returns descriptor of enum type by full name, leading dot is optional.
+func (d *Descriptors) Enum(name string) *EnumDescriptor {
+	return d.enums[strings.TrimPrefix(name, ".")]
+}
+
+func (d *Descriptors) addFile(b []byte) error {
+	fields, err := ParseFields(b)
+	if err != nil {
+		return fmt.Errorf("pb: FileDescriptorProto: %w", err)
+	}
+
+	var pkg string
+
+	for _, field := range fields {
+		if field.Num == 2 && field.Type == WireBytes {
+			pkg = string(field.Bytes)
+		}
+	}
+
+	for _, field := range fields {
+		if field.Type != WireBytes {
+			continue
+		}
+
+		switch field.Num {
+		case 4:
+			err = d.addMessage(pkg, field.Bytes)
+		case 5:
+			err = d.addEnum(pkg, field.Bytes)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *Descriptors) addMessage(scope string, b []byte) error {
+	fields, err := ParseFields(b)
+	if err != nil {
+		return fmt.Errorf("pb: DescriptorProto: %w", err)
+	}
+
+	message := &MessageDescriptor{}
+
+	for _, field := range fields {
+		if field.Num == 1 && field.Type == WireBytes {
+			message.FullName = joinName(scope, string(field.Bytes))
+		}
+	}
+
+	for _, field := range fields {
+		if field.Type != WireBytes {
+			continue
+		}
+
+		switch field.Num {
+		case 2:
+			var fieldDescriptor *FieldDescriptor
+			if fieldDescriptor, err = parseFieldDescriptor(field.Bytes); err == nil {
+				message.Fields = append(message.Fields, fieldDescriptor)
+			}
+		case 3:
+			err = d.addMessage(message.FullName, field.Bytes)
+		case 4:
+			err = d.addEnum(message.FullName, field.Bytes)
+		case 7:
+			message.MapEntry, err = parseMapEntryOption(field.Bytes)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	d.messages[message.FullName] = message
+
+	return nil
+}
+
+func (d *Descriptors) addEnum(scope string, b []byte) error {
+	fields, err := ParseFields(b)
+	if err != nil {
+		return fmt.Errorf("pb: EnumDescriptorProto: %w", err)
+	}
+
+	enum := &EnumDescriptor{Values: make(map[string]int32)}
+
+	for _, field := range fields {
+		if field.Type != WireBytes {
+			continue
+		}
+
+		switch field.Num {
+		case 1:
+			enum.FullName = joinName(scope, string(field.Bytes))
+		case 2:
+			valueFields, err := ParseFields(field.Bytes)
+			if err != nil {
+				return fmt.Errorf("pb: EnumValueDescriptorProto: %w", err)
+			}
+
+			var name string
+
+			var number int32
+
+			for _, valueField := range valueFields {
+				switch {
+				case valueField.Num == 1 && valueField.Type == WireBytes:
+					name = string(valueField.Bytes)
+				case valueField.Num == 2 && valueField.Type == WireVarint:
+					number = int32(valueField.Value)
+				}
+			}
+
+			enum.Values[name] = number
+		}
+	}
+
+	d.enums[enum.FullName] = enum
+
+	return nil
+}
+
+func parseFieldDescriptor(b []byte) (*FieldDescriptor, error) {
+	fields, err := ParseFields(b)
+	if err != nil {
+		return nil, fmt.Errorf("pb: FieldDescriptorProto: %w", err)
+	}
+
+	descriptor := &FieldDescriptor{}
+
+	for _, field := range fields {
+		switch {
+		case field.Num == 1 && field.Type == WireBytes:
+			descriptor.Name = string(field.Bytes)
+		case field.Num == 3 && field.Type == WireVarint:
+			descriptor.Number = int(field.Value)
+		case field.Num == 4 && field.Type == WireVarint:
+			descriptor.Repeated = field.Value == labelRepeated
+		case field.Num == 5 && field.Type == WireVarint:
+			descriptor.Type = FieldType(field.Value)
+		case field.Num == 6 && field.Type == WireBytes:
+			descriptor.TypeName = strings.TrimPrefix(string(field.Bytes), ".")
+		case field.Num == 10 && field.Type == WireBytes:
+			descriptor.JSONName = string(field.Bytes)
+		}
+	}
+
+	if descriptor.JSONName == "" {
+		descriptor.JSONName = jsonCamelCase(descriptor.Name)
+	}
+
+	return descriptor, nil
+}
+
+// parseMapEntryOption returns map_entry of google.protobuf.MessageOptions.
+func parseMapEntryOption(b []byte) (bool, error) {
+	fields, err := ParseFields(b)
+	if err != nil {
+		return false, fmt.Errorf("pb: MessageOptions: %w", err)
+	}
+
+	for _, field := range fields {
+		if field.Num == 7 && field.Type == WireVarint {
+			return field.Value != 0, nil
+		}
+	}
+
+	return false, nil
+}
+
+func joinName(scope string, name string) string {
+	if scope == "" {
+		return name
+	}
+
+	return scope + "." + name
+}
+
+// jsonCamelCase converts field name to lowerCamelCase as protoc does for json_name.
+func jsonCamelCase(name string) string {
+	var builder strings.Builder
+
+	upper := false
+
+	for _, r := range name {
+		if r == '_' {
+			upper = true
+			continue
+		}
+
+		if upper && r >= 'a' && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+
+		upper = false
+
+		builder.WriteRune(r)
+	}
+
+	return builder.String()
+}
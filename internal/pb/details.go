@@ -0,0 +1,557 @@
+package pb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Message is the protobuf message with a well known full name.
+type Message interface {
+	Marshal() []byte
+	FullName() string
+}
+
+// DetailMessage is the standard error detail message from google/rpc/error_details.proto.
+type DetailMessage interface {
+	Message
+	Unmarshal(b []byte) error
+}
+
+// NewAny packs message to Any with default type URL prefix.
+func NewAny(m Message) *Any {
+	return &Any{
+		TypeURL: TypeURLPrefix + m.FullName(),
+		Value:   m.Marshal(),
+	}
+}
+
+// newDetailMessage returns empty detail message by its full name, nil if it is not a standard one.
+func newDetailMessage(fullName string) DetailMessage {
+	switch fullName {
+	case "google.rpc.ErrorInfo":
+		return &ErrorInfo{}
+	case "google.rpc.RetryInfo":
+		return &RetryInfo{}
+	case "google.rpc.DebugInfo":
+		return &DebugInfo{}
+	case "google.rpc.QuotaFailure":
+		return &QuotaFailure{}
+	case "google.rpc.PreconditionFailure":
+		return &PreconditionFailure{}
+	case "google.rpc.BadRequest":
+		return &BadRequest{}
+	case "google.rpc.RequestInfo":
+		return &RequestInfo{}
+	case "google.rpc.ResourceInfo":
+		return &ResourceInfo{}
+	case "google.rpc.Help":
+		return &Help{}
+	case "google.rpc.LocalizedMessage":
+		return &LocalizedMessage{}
+	default:
+		return nil
+	}
+}
+
+// UnmarshalDetail decodes standard error detail message packed to Any,
+// the message full name is the part of type URL after the last slash.
+func UnmarshalDetail(a *Any) (DetailMessage, error) {
+	fullName := a.TypeURL[strings.LastIndex(a.TypeURL, "/")+1:]
+
+	detail := newDetailMessage(fullName)
+	if detail == nil {
+		return nil, fmt.Errorf("pb: unknown error detail type %q", a.TypeURL)
+	}
+
+	if err := detail.Unmarshal(a.Value); err != nil {
+		return nil, fmt.Errorf("pb: %s: %w", fullName, err)
+	}
+
+	return detail, nil
+}
+
+// ErrorInfo is google.rpc.ErrorInfo from google/rpc/error_details.proto.
+type ErrorInfo struct {
+	Reason   string
+	Domain   string
+	Metadata map[string]string
+}
+
+// FullName returns protobuf full name of ErrorInfo.
+func (e *ErrorInfo) FullName() string {
+	return "google.rpc.ErrorInfo"
+}
+
+// Marshal encodes ErrorInfo to protobuf wire format.
+func (e *ErrorInfo) Marshal() []byte {
+	var b []byte
+	b = AppendStringField(b, 1, e.Reason)
+	b = AppendStringField(b, 2, e.Domain)
+	b = AppendStringMapField(b, 3, e.Metadata)
+
+	return b
+}
+
+// Unmarshal decodes ErrorInfo from protobuf wire format.
+func (e *ErrorInfo) Unmarshal(b []byte) error {
+	fields, err := ParseFields(b)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		if field.Type != WireBytes {
+			continue
+		}
+
+		switch field.Num {
+		case 1:
+			e.Reason = string(field.Bytes)
+		case 2:
+			e.Domain = string(field.Bytes)
+		case 3:
+			key, value, err := ParseStringMapEntry(field.Bytes)
+			if err != nil {
+				return err
+			}
+
+			if e.Metadata == nil {
+				e.Metadata = make(map[string]string)
+			}
+
+			e.Metadata[key] = value
+		}
+	}
+
+	return nil
+}
+
+// RetryInfo is google.rpc.RetryInfo from google/rpc/error_details.proto.
+type RetryInfo struct {
+	RetryDelay *Duration
+}
+
+// FullName returns protobuf full name of RetryInfo.
+func (r *RetryInfo) FullName() string {
+	return "google.rpc.RetryInfo"
+}
+
+// Marshal encodes RetryInfo to protobuf wire format.
+func (r *RetryInfo) Marshal() []byte {
+	var b []byte
+	if r.RetryDelay != nil {
+		b = AppendMessageField(b, 1, r.RetryDelay.Marshal())
+	}
+
+	return b
+}
+
+// Unmarshal decodes RetryInfo from protobuf wire format.
+func (r *RetryInfo) Unmarshal(b []byte) error {
+	fields, err := ParseFields(b)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		if field.Num == 1 && field.Type == WireBytes {
+			r.RetryDelay = &Duration{}
+			if err = r.RetryDelay.Unmarshal(field.Bytes); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// DebugInfo is google.rpc.DebugInfo from google/rpc/error_details.proto.
+type DebugInfo struct {
+	StackEntries []string
+	Detail       string
+}
+
+// FullName returns protobuf full name of DebugInfo.
+func (d *DebugInfo) FullName() string {
+	return "google.rpc.DebugInfo"
+}
+
+// Marshal encodes DebugInfo to protobuf wire format.
+func (d *DebugInfo) Marshal() []byte {
+	var b []byte
+	for _, entry := range d.StackEntries {
+		// repeated string elements are written even if empty
+		b = AppendMessageField(b, 1, []byte(entry))
+	}
+
+	b = AppendStringField(b, 2, d.Detail)
+
+	return b
+}
+
+// Unmarshal decodes DebugInfo from protobuf wire format.
+func (d *DebugInfo) Unmarshal(b []byte) error {
+	fields, err := ParseFields(b)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		switch {
+		case field.Num == 1 && field.Type == WireBytes:
+			d.StackEntries = append(d.StackEntries, string(field.Bytes))
+		case field.Num == 2 && field.Type == WireBytes:
+			d.Detail = string(field.Bytes)
+		}
+	}
+
+	return nil
+}
+
+// QuotaViolation is google.rpc.QuotaFailure.Violation from google/rpc/error_details.proto.
+type QuotaViolation struct {
+	Subject     string
+	Description string
+}
+
+// Marshal encodes QuotaViolation to protobuf wire format.
+func (v *QuotaViolation) Marshal() []byte {
+	var b []byte
+	b = AppendStringField(b, 1, v.Subject)
+	b = AppendStringField(b, 2, v.Description)
+
+	return b
+}
+
+// Unmarshal decodes QuotaViolation from protobuf wire format.
+func (v *QuotaViolation) Unmarshal(b []byte) error {
+	return unmarshalStrings(b, &v.Subject, &v.Description)
+}
+
+// QuotaFailure is google.rpc.QuotaFailure from google/rpc/error_details.proto.
+type QuotaFailure struct {
+	Violations []*QuotaViolation
+}
+
+// FullName returns protobuf full name of QuotaFailure.
+func (q *QuotaFailure) FullName() string {
+	return "google.rpc.QuotaFailure"
+}
+
+// Marshal encodes QuotaFailure to protobuf wire format.
+func (q *QuotaFailure) Marshal() []byte {
+	var b []byte
+	for _, violation := range q.Violations {
+		b = AppendMessageField(b, 1, violation.Marshal())
+	}
+
+	return b
+}
+
+// Unmarshal decodes QuotaFailure from protobuf wire format.
+func (q *QuotaFailure) Unmarshal(b []byte) error {
+	return unmarshalRepeated(b, 1, func(element []byte) error {
+		violation := &QuotaViolation{}
+		q.Violations = append(q.Violations, violation)
+
+		return violation.Unmarshal(element)
+	})
+}
+
+// PreconditionViolation is google.rpc.PreconditionFailure.Violation from google/rpc/error_details.proto.
+type PreconditionViolation struct {
+	Type        string
+	Subject     string
+	Description string
+}
+
+// Marshal encodes PreconditionViolation to protobuf wire format.
+func (v *PreconditionViolation) Marshal() []byte {
+	var b []byte
+	b = AppendStringField(b, 1, v.Type)
+	b = AppendStringField(b, 2, v.Subject)
+	b = AppendStringField(b, 3, v.Description)
+
+	return b
+}
+
+// Unmarshal decodes PreconditionViolation from protobuf wire format.
+func (v *PreconditionViolation) Unmarshal(b []byte) error {
+	return unmarshalStrings(b, &v.Type, &v.Subject, &v.Description)
+}
+
+// PreconditionFailure is google.rpc.PreconditionFailure from google/rpc/error_details.proto.
+type PreconditionFailure struct {
+	Violations []*PreconditionViolation
+}
+
+// FullName returns protobuf full name of PreconditionFailure.
+func (p *PreconditionFailure) FullName() string {
+	return "google.rpc.PreconditionFailure"
+}
+
+// Marshal encodes PreconditionFailure to protobuf wire format.
+func (p *PreconditionFailure) Marshal() []byte {
+	var b []byte
+	for _, violation := range p.Violations {
+		b = AppendMessageField(b, 1, violation.Marshal())
+	}
+
+	return b
+}
+
+// Unmarshal decodes PreconditionFailure from protobuf wire format.
+func (p *PreconditionFailure) Unmarshal(b []byte) error {
+	return unmarshalRepeated(b, 1, func(element []byte) error {
+		violation := &PreconditionViolation{}
+		p.Violations = append(p.Violations, violation)
+
+		return violation.Unmarshal(element)
+	})
+}
+
+// FieldViolation is google.rpc.BadRequest.FieldViolation from google/rpc/error_details.proto.
+type FieldViolation struct {
+	Field            string
+	Description      string
+	Reason           string
+	LocalizedMessage *LocalizedMessage
+}
+
+// Marshal encodes FieldViolation to protobuf wire format.
+func (v *FieldViolation) Marshal() []byte {
+	var b []byte
+	b = AppendStringField(b, 1, v.Field)
+	b = AppendStringField(b, 2, v.Description)
+	b = AppendStringField(b, 3, v.Reason)
+
+	if v.LocalizedMessage != nil {
+		b = AppendMessageField(b, 4, v.LocalizedMessage.Marshal())
+	}
+
+	return b
+}
+
+// Unmarshal decodes FieldViolation from protobuf wire format.
+func (v *FieldViolation) Unmarshal(b []byte) error {
+	fields, err := ParseFields(b)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		if field.Type != WireBytes {
+			continue
+		}
+
+		switch field.Num {
+		case 1:
+			v.Field = string(field.Bytes)
+		case 2:
+			v.Description = string(field.Bytes)
+		case 3:
+			v.Reason = string(field.Bytes)
+		case 4:
+			v.LocalizedMessage = &LocalizedMessage{}
+			if err = v.LocalizedMessage.Unmarshal(field.Bytes); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// BadRequest is google.rpc.BadRequest from google/rpc/error_details.proto.
+type BadRequest struct {
+	FieldViolations []*FieldViolation
+}
+
+// FullName returns protobuf full name of BadRequest.
+func (r *BadRequest) FullName() string {
+	return "google.rpc.BadRequest"
+}
+
+// Marshal encodes BadRequest to protobuf wire format.
+func (r *BadRequest) Marshal() []byte {
+	var b []byte
+	for _, violation := range r.FieldViolations {
+		b = AppendMessageField(b, 1, violation.Marshal())
+	}
+
+	return b
+}
+
+// Unmarshal decodes BadRequest from protobuf wire format.
+func (r *BadRequest) Unmarshal(b []byte) error {
+	return unmarshalRepeated(b, 1, func(element []byte) error {
+		violation := &FieldViolation{}
+		r.FieldViolations = append(r.FieldViolations, violation)
+
+		return violation.Unmarshal(element)
+	})
+}
+
+// RequestInfo is google.rpc.RequestInfo from google/rpc/error_details.proto.
+type RequestInfo struct {
+	RequestID   string
+	ServingData string
+}
+
+// FullName returns protobuf full name of RequestInfo.
+func (r *RequestInfo) FullName() string {
+	return "google.rpc.RequestInfo"
+}
+
+// Marshal encodes RequestInfo to protobuf wire format.
+func (r *RequestInfo) Marshal() []byte {
+	var b []byte
+	b = AppendStringField(b, 1, r.RequestID)
+	b = AppendStringField(b, 2, r.ServingData)
+
+	return b
+}
+
+// Unmarshal decodes RequestInfo from protobuf wire format.
+func (r *RequestInfo) Unmarshal(b []byte) error {
+	return unmarshalStrings(b, &r.RequestID, &r.ServingData)
+}
+
+// ResourceInfo is google.rpc.ResourceInfo from google/rpc/error_details.proto.
+type ResourceInfo struct {
+	ResourceType string
+	ResourceName string
+	Owner        string
+	Description  string
+}
+
+// FullName returns protobuf full name of ResourceInfo.
+func (r *ResourceInfo) FullName() string {
+	return "google.rpc.ResourceInfo"
+}
+
+// Marshal encodes ResourceInfo to protobuf wire format.
+func (r *ResourceInfo) Marshal() []byte {
+	var b []byte
+	b = AppendStringField(b, 1, r.ResourceType)
+	b = AppendStringField(b, 2, r.ResourceName)
+	b = AppendStringField(b, 3, r.Owner)
+	b = AppendStringField(b, 4, r.Description)
+
+	return b
+}
+
+// Unmarshal decodes ResourceInfo from protobuf wire format.
+func (r *ResourceInfo) Unmarshal(b []byte) error {
+	return unmarshalStrings(b, &r.ResourceType, &r.ResourceName, &r.Owner, &r.Description)
+}
+
+// Link is google.rpc.Help.Link from google/rpc/error_details.proto.
+type Link struct {
+	Description string
+	URL         string
+}
+
+// Marshal encodes Link to protobuf wire format.
+func (l *Link) Marshal() []byte {
+	var b []byte
+	b = AppendStringField(b, 1, l.Description)
+	b = AppendStringField(b, 2, l.URL)
+
+	return b
+}
+
+// Unmarshal decodes Link from protobuf wire format.
+func (l *Link) Unmarshal(b []byte) error {
+	return unmarshalStrings(b, &l.Description, &l.URL)
+}
+
+// Help is google.rpc.Help from google/rpc/error_details.proto.
+type Help struct {
+	Links []*Link
+}
+
+// FullName returns protobuf full name of Help.
+func (h *Help) FullName() string {
+	return "google.rpc.Help"
+}
+
+// Marshal encodes Help to protobuf wire format.
+func (h *Help) Marshal() []byte {
+	var b []byte
+	for _, link := range h.Links {
+		b = AppendMessageField(b, 1, link.Marshal())
+	}
+
+	return b
+}
+
+// Unmarshal decodes Help from protobuf wire format.
+func (h *Help) Unmarshal(b []byte) error {
+	return unmarshalRepeated(b, 1, func(element []byte) error {
+		link := &Link{}
+		h.Links = append(h.Links, link)
+
+		return link.Unmarshal(element)
+	})
+}
+
+// LocalizedMessage is google.rpc.LocalizedMessage from google/rpc/error_details.proto.
+type LocalizedMessage struct {
+	// Locale is BCP-47 locale, like en-US
+	Locale  string
+	Message string
+}
+
+// FullName returns protobuf full name of LocalizedMessage.
+func (m *LocalizedMessage) FullName() string {
+	return "google.rpc.LocalizedMessage"
+}
+
+// Marshal encodes LocalizedMessage to protobuf wire format.
+func (m *LocalizedMessage) Marshal() []byte {
+	var b []byte
+	b = AppendStringField(b, 1, m.Locale)
+	b = AppendStringField(b, 2, m.Message)
+
+	return b
+}
+
+// Unmarshal decodes LocalizedMessage from protobuf wire format.
+func (m *LocalizedMessage) Unmarshal(b []byte) error {
+	return unmarshalStrings(b, &m.Locale, &m.Message)
+}
+
+// unmarshalStrings decodes message of string fields only, numbered from 1 in order of targets.
+func unmarshalStrings(b []byte, targets ...*string) error {
+	fields, err := ParseFields(b)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		if field.Type == WireBytes && field.Num <= len(targets) {
+			*targets[field.Num-1] = string(field.Bytes)
+		}
+	}
+
+	return nil
+}
+
+// unmarshalRepeated calls decode for every element of repeated message field num.
+func unmarshalRepeated(b []byte, num int, decode func(element []byte) error) error {
+	fields, err := ParseFields(b)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		if field.Num == num && field.Type == WireBytes {
+			if err = decode(field.Bytes); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,264 @@
+package pb_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/v-electrolux/http2grpc/internal/pb"
+)
+
+type TestDetailData struct {
+	message pb.DetailMessage
+
+	expBytes []byte
+}
+
+// Golden bytes of details tests are produced by protojson.Unmarshal and deterministic proto.Marshal
+// of google/rpc/error_details.proto messages, JSON is in comment of each test.
+
+// {"reason":"API_DISABLED","domain":"example.com","metadata":{"service":"pubsub","consumer":"projects/1"}}.
+func TestDetailErrorInfo(t *testing.T) {
+	data := TestDetailData{
+		message: &pb.ErrorInfo{
+			Reason:   "API_DISABLED",
+			Domain:   "example.com",
+			Metadata: map[string]string{"service": "pubsub", "consumer": "projects/1"},
+		},
+
+		expBytes: []byte{
+			0xa, 0xc, 0x41, 0x50, 0x49, 0x5f, 0x44, 0x49, 0x53, 0x41, 0x42, 0x4c, 0x45, 0x44, 0x12, 0xb, 0x65, 0x78,
+			0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x1a, 0x16, 0xa, 0x8, 0x63, 0x6f, 0x6e, 0x73,
+			0x75, 0x6d, 0x65, 0x72, 0x12, 0xa, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x73, 0x2f, 0x31, 0x1a,
+			0x11, 0xa, 0x7, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x6, 0x70, 0x75, 0x62, 0x73, 0x75,
+			0x62,
+		},
+	}
+	testDetail(t, data)
+}
+
+// {"retryDelay":"3600s"}.
+func TestDetailRetryInfo(t *testing.T) {
+	data := TestDetailData{
+		message: &pb.RetryInfo{RetryDelay: pb.NewDuration(time.Hour)},
+
+		expBytes: []byte{0xa, 0x3, 0x8, 0x90, 0x1c},
+	}
+	testDetail(t, data)
+}
+
+// {"retryDelay":"-1.5s"}.
+func TestDetailRetryInfoNegativeDelay(t *testing.T) {
+	data := TestDetailData{
+		message: &pb.RetryInfo{RetryDelay: pb.NewDuration(-1500 * time.Millisecond)},
+
+		expBytes: []byte{
+			0xa, 0x16, 0x8, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x1, 0x10, 0x80, 0xb6, 0xca, 0x91,
+			0xfe, 0xff, 0xff, 0xff, 0xff, 0x1,
+		},
+	}
+	testDetail(t, data)
+}
+
+// {"stackEntries":["main.go:10",""],"detail":"panic"}.
+func TestDetailDebugInfo(t *testing.T) {
+	data := TestDetailData{
+		message: &pb.DebugInfo{StackEntries: []string{"main.go:10", ""}, Detail: "panic"},
+
+		expBytes: []byte{
+			0xa, 0xa, 0x6d, 0x61, 0x69, 0x6e, 0x2e, 0x67, 0x6f, 0x3a, 0x31, 0x30, 0xa, 0x0, 0x12, 0x5, 0x70, 0x61,
+			0x6e, 0x69, 0x63,
+		},
+	}
+	testDetail(t, data)
+}
+
+// {"violations":[{"subject":"clientip:127.0.0.1","description":"Daily limit"},{}]}.
+func TestDetailQuotaFailure(t *testing.T) {
+	data := TestDetailData{
+		message: &pb.QuotaFailure{Violations: []*pb.QuotaViolation{
+			{Subject: "clientip:127.0.0.1", Description: "Daily limit"},
+			{},
+		}},
+
+		expBytes: []byte{
+			0xa, 0x21, 0xa, 0x12, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x69, 0x70, 0x3a, 0x31, 0x32, 0x37, 0x2e,
+			0x30, 0x2e, 0x30, 0x2e, 0x31, 0x12, 0xb, 0x44, 0x61, 0x69, 0x6c, 0x79, 0x20, 0x6c, 0x69, 0x6d, 0x69,
+			0x74, 0xa, 0x0,
+		},
+	}
+	testDetail(t, data)
+}
+
+// {"violations":[{"type":"TOS","subject":"google.com/cloud","description":"Terms of service not accepted"}]}.
+func TestDetailPreconditionFailure(t *testing.T) {
+	data := TestDetailData{
+		message: &pb.PreconditionFailure{Violations: []*pb.PreconditionViolation{
+			{Type: "TOS", Subject: "google.com/cloud", Description: "Terms of service not accepted"},
+		}},
+
+		expBytes: []byte{
+			0xa, 0x36, 0xa, 0x3, 0x54, 0x4f, 0x53, 0x12, 0x10, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x63,
+			0x6f, 0x6d, 0x2f, 0x63, 0x6c, 0x6f, 0x75, 0x64, 0x1a, 0x1d, 0x54, 0x65, 0x72, 0x6d, 0x73, 0x20, 0x6f,
+			0x66, 0x20, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x20, 0x6e, 0x6f, 0x74, 0x20, 0x61, 0x63, 0x63,
+			0x65, 0x70, 0x74, 0x65, 0x64,
+		},
+	}
+	testDetail(t, data)
+}
+
+// {"fieldViolations":[{"field":"user.email","description":"must be valid","reason":"INVALID_EMAIL",
+// "localizedMessage":{"locale":"ru-RU","message":"неверный адрес"}},{"field":"age"}]}.
+func TestDetailBadRequest(t *testing.T) {
+	data := TestDetailData{
+		message: &pb.BadRequest{FieldViolations: []*pb.FieldViolation{
+			{
+				Field:            "user.email",
+				Description:      "must be valid",
+				Reason:           "INVALID_EMAIL",
+				LocalizedMessage: &pb.LocalizedMessage{Locale: "ru-RU", Message: "неверный адрес"},
+			},
+			{Field: "age"},
+		}},
+
+		expBytes: []byte{
+			0xa, 0x50, 0xa, 0xa, 0x75, 0x73, 0x65, 0x72, 0x2e, 0x65, 0x6d, 0x61, 0x69, 0x6c, 0x12, 0xd, 0x6d, 0x75,
+			0x73, 0x74, 0x20, 0x62, 0x65, 0x20, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x1a, 0xd, 0x49, 0x4e, 0x56, 0x41,
+			0x4c, 0x49, 0x44, 0x5f, 0x45, 0x4d, 0x41, 0x49, 0x4c, 0x22, 0x24, 0xa, 0x5, 0x72, 0x75, 0x2d, 0x52,
+			0x55, 0x12, 0x1b, 0xd0, 0xbd, 0xd0, 0xb5, 0xd0, 0xb2, 0xd0, 0xb5, 0xd1, 0x80, 0xd0, 0xbd, 0xd1, 0x8b,
+			0xd0, 0xb9, 0x20, 0xd0, 0xb0, 0xd0, 0xb4, 0xd1, 0x80, 0xd0, 0xb5, 0xd1, 0x81, 0xa, 0x5, 0xa, 0x3,
+			0x61, 0x67, 0x65,
+		},
+	}
+	testDetail(t, data)
+}
+
+// {"requestId":"req-1","servingData":"stack"}.
+func TestDetailRequestInfo(t *testing.T) {
+	data := TestDetailData{
+		message: &pb.RequestInfo{RequestID: "req-1", ServingData: "stack"},
+
+		expBytes: []byte{0xa, 0x5, 0x72, 0x65, 0x71, 0x2d, 0x31, 0x12, 0x5, 0x73, 0x74, 0x61, 0x63, 0x6b},
+	}
+	testDetail(t, data)
+}
+
+// {"resourceType":"book","resourceName":"shelves/1/books/2","owner":"user:me","description":"not found"}.
+func TestDetailResourceInfo(t *testing.T) {
+	data := TestDetailData{
+		message: &pb.ResourceInfo{
+			ResourceType: "book", ResourceName: "shelves/1/books/2", Owner: "user:me", Description: "not found",
+		},
+
+		expBytes: []byte{
+			0xa, 0x4, 0x62, 0x6f, 0x6f, 0x6b, 0x12, 0x11, 0x73, 0x68, 0x65, 0x6c, 0x76, 0x65, 0x73, 0x2f, 0x31,
+			0x2f, 0x62, 0x6f, 0x6f, 0x6b, 0x73, 0x2f, 0x32, 0x1a, 0x7, 0x75, 0x73, 0x65, 0x72, 0x3a, 0x6d, 0x65,
+			0x22, 0x9, 0x6e, 0x6f, 0x74, 0x20, 0x66, 0x6f, 0x75, 0x6e, 0x64,
+		},
+	}
+	testDetail(t, data)
+}
+
+// {"links":[{"description":"docs","url":"https://example.com/docs"}]}.
+func TestDetailHelp(t *testing.T) {
+	data := TestDetailData{
+		message: &pb.Help{Links: []*pb.Link{{Description: "docs", URL: "https://example.com/docs"}}},
+
+		expBytes: []byte{
+			0xa, 0x20, 0xa, 0x4, 0x64, 0x6f, 0x63, 0x73, 0x12, 0x18, 0x68, 0x74, 0x74, 0x70, 0x73, 0x3a, 0x2f,
+			0x2f, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x64, 0x6f, 0x63, 0x73,
+		},
+	}
+	testDetail(t, data)
+}
+
+// {"locale":"en-US","message":"Hello"}.
+func TestDetailLocalizedMessage(t *testing.T) {
+	data := TestDetailData{
+		message: &pb.LocalizedMessage{Locale: "en-US", Message: "Hello"},
+
+		expBytes: []byte{0xa, 0x5, 0x65, 0x6e, 0x2d, 0x55, 0x53, 0x12, 0x5, 0x48, 0x65, 0x6c, 0x6c, 0x6f},
+	}
+	testDetail(t, data)
+}
+
+func testDetail(t *testing.T, data TestDetailData) {
+	t.Helper()
+
+	encoded := data.message.Marshal()
+	if !bytes.Equal(encoded, data.expBytes) {
+		t.Errorf("expected bytes %#v, got %#v", data.expBytes, encoded)
+	}
+
+	decoded, err := pb.UnmarshalDetail(&pb.Any{TypeURL: pb.TypeURLPrefix + data.message.FullName(), Value: data.expBytes})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decoded, data.message) {
+		t.Errorf("expected decoded message %+v, got %+v", data.message, decoded)
+	}
+}
+
+// {"code":9,"message":"failed","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo",
+// "retryDelay":"0.000000001s"},{"@type":"type.googleapis.com/google.rpc.LocalizedMessage",
+// "locale":"en","message":"Hi"}]}.
+func TestStatusWithDetails(t *testing.T) {
+	expBytes := []byte{
+		0x8, 0x9, 0x12, 0x6, 0x66, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x1a, 0x30, 0xa, 0x28, 0x74, 0x79, 0x70, 0x65,
+		0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x61, 0x70, 0x69, 0x73, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x67,
+		0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x72, 0x70, 0x63, 0x2e, 0x52, 0x65, 0x74, 0x72, 0x79, 0x49, 0x6e,
+		0x66, 0x6f, 0x12, 0x4, 0xa, 0x2, 0x10, 0x1, 0x1a, 0x3b, 0xa, 0x2f, 0x74, 0x79, 0x70, 0x65, 0x2e, 0x67,
+		0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x61, 0x70, 0x69, 0x73, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x67, 0x6f, 0x6f,
+		0x67, 0x6c, 0x65, 0x2e, 0x72, 0x70, 0x63, 0x2e, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x69, 0x7a, 0x65, 0x64,
+		0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x8, 0xa, 0x2, 0x65, 0x6e, 0x12, 0x2, 0x48, 0x69,
+	}
+	details := []pb.DetailMessage{
+		&pb.RetryInfo{RetryDelay: pb.NewDuration(time.Nanosecond)},
+		&pb.LocalizedMessage{Locale: "en", Message: "Hi"},
+	}
+
+	status := &pb.Status{Code: 9, Message: "failed"}
+	for _, detail := range details {
+		status.Details = append(status.Details, pb.NewAny(detail))
+	}
+
+	if encoded := status.Marshal(); !bytes.Equal(encoded, expBytes) {
+		t.Errorf("expected bytes %#v, got %#v", expBytes, encoded)
+	}
+
+	var decoded pb.Status
+	if err := decoded.Unmarshal(expBytes); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Code != 9 || decoded.Message != "failed" || len(decoded.Details) != len(details) {
+		t.Fatalf("unexpected decoded status %+v", decoded)
+	}
+
+	for i, detail := range decoded.Details {
+		message, err := pb.UnmarshalDetail(detail)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(message, details[i]) {
+			t.Errorf("expected detail %+v, got %+v", details[i], message)
+		}
+	}
+}
+
+func TestUnmarshalDetailErrors(t *testing.T) {
+	if _, err := pb.UnmarshalDetail(&pb.Any{TypeURL: "type.googleapis.com/stub.v1.Reply"}); err == nil {
+		t.Error("expected error for unknown detail type")
+	}
+
+	if _, err := pb.UnmarshalDetail(&pb.Any{TypeURL: "google.rpc.ErrorInfo", Value: []byte{0xa, 0x5, 0x41}}); err == nil {
+		t.Error("expected error for truncated detail")
+	}
+
+	if d := (&pb.Duration{Seconds: -1, Nanos: -500000000}).AsDuration(); d != -1500*time.Millisecond {
+		t.Errorf("expected -1.5s, got %s", d)
+	}
+}
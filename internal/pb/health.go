@@ -0,0 +1,48 @@
+package pb
+
+// ServingStatus is grpc.health.v1.HealthCheckResponse.ServingStatus enum.
+type ServingStatus int32
+
+// serving statuses from grpc/health/v1/health.proto.
+const (
+	ServingStatusUnknown        ServingStatus = 0
+	ServingStatusServing        ServingStatus = 1
+	ServingStatusNotServing     ServingStatus = 2
+	ServingStatusServiceUnknown ServingStatus = 3
+)
+
+// HealthCheckRequest is grpc.health.v1.HealthCheckRequest from grpc/health/v1/health.proto.
+type HealthCheckRequest struct {
+	Service string
+}
+
+// Unmarshal decodes HealthCheckRequest from protobuf wire format.
+func (r *HealthCheckRequest) Unmarshal(b []byte) error {
+	fields, err := ParseFields(b)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		if field.Num == 1 && field.Type == WireBytes {
+			r.Service = string(field.Bytes)
+		}
+	}
+
+	return nil
+}
+
+// HealthCheckResponse is grpc.health.v1.HealthCheckResponse from grpc/health/v1/health.proto.
+type HealthCheckResponse struct {
+	Status ServingStatus
+}
+
+// FullName returns protobuf full name of HealthCheckResponse.
+func (r *HealthCheckResponse) FullName() string {
+	return "grpc.health.v1.HealthCheckResponse"
+}
+
+// Marshal encodes HealthCheckResponse to protobuf wire format.
+func (r *HealthCheckResponse) Marshal() []byte {
+	return AppendVarintField(nil, 1, uint64(r.Status))
+}
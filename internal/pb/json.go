@@ -0,0 +1,452 @@
+package pb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EncodeJSON encodes JSON object as protobuf message of messageType by proto3 JSON mapping
+// https://protobuf.dev/programming-guides/proto3/#json
+// Well-known types with special JSON representation (Timestamp, Duration, Struct, wrappers) are not supported,
+// and zero values of singular scalar fields are omitted as proto3 does.
+func (d *Descriptors) EncodeJSON(messageType string, doc []byte) ([]byte, error) {
+	message := d.Message(messageType)
+	if message == nil {
+		return nil, fmt.Errorf("pb: unknown message type %q", messageType)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("pb: %s: %w", messageType, err)
+	}
+
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("pb: %s: JSON object expected", messageType)
+	}
+
+	return d.encodeMessage(message, object)
+}
+
+func (d *Descriptors) encodeMessage(message *MessageDescriptor, object map[string]interface{}) ([]byte, error) {
+	fields := make([]*FieldDescriptor, len(message.Fields))
+	copy(fields, message.Fields)
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Number < fields[j].Number })
+
+	known := make(map[string]bool, 2*len(fields))
+
+	var b []byte
+
+	for _, field := range fields {
+		known[field.JSONName] = true
+		known[field.Name] = true
+
+		value, ok := object[field.JSONName]
+		if !ok {
+			value, ok = object[field.Name]
+		}
+
+		if !ok || value == nil {
+			continue
+		}
+
+		var err error
+		if b, err = d.encodeField(b, field, value); err != nil {
+			return nil, fmt.Errorf("pb: %s.%s: %w", message.FullName, field.Name, err)
+		}
+	}
+
+	for key := range object {
+		if !known[key] {
+			return nil, fmt.Errorf("pb: %s: unknown field %q", message.FullName, key)
+		}
+	}
+
+	return b, nil
+}
+
+func (d *Descriptors) encodeField(b []byte, field *FieldDescriptor, value interface{}) ([]byte, error) {
+	if field.Type == TypeGroup {
+		return nil, errors.New("groups are not supported")
+	}
+
+	if field.Type == TypeMessage {
+		if entry := d.Message(field.TypeName); entry != nil && entry.MapEntry {
+			return d.encodeMap(b, field, entry, value)
+		}
+	}
+
+	if !field.Repeated {
+		return d.encodeSingular(b, field, value, true)
+	}
+
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, errors.New("JSON array expected")
+	}
+
+	if field.Type == TypeMessage || field.Type == TypeString || field.Type == TypeBytes {
+		for _, item := range values {
+			var err error
+			if b, err = d.encodeSingular(b, field, item, false); err != nil {
+				return nil, err
+			}
+		}
+
+		return b, nil
+	}
+
+	// repeated scalar numeric fields are packed by default in proto3
+	var packed []byte
+
+	for _, item := range values {
+		typ, number, _, err := d.encodeScalar(field, item)
+		if err != nil {
+			return nil, err
+		}
+
+		packed = appendNumber(packed, typ, number)
+	}
+
+	return AppendMessageField(b, field.Number, packed), nil
+}
+
+func (d *Descriptors) encodeMap(b []byte, field *FieldDescriptor, entry *MessageDescriptor,
+	value interface{},
+) ([]byte, error) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("JSON object expected for map")
+	}
+
+	var keyField, valueField *FieldDescriptor
+
+	for _, entryField := range entry.Fields {
+		switch entryField.Number {
+		case 1:
+			keyField = entryField
+		case 2:
+			valueField = entryField
+		}
+	}
+
+	if keyField == nil || valueField == nil {
+		return nil, fmt.Errorf("invalid map entry %s", entry.FullName)
+	}
+
+	keys, err := sortedMapKeys(keyField.Type, object)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		var keyValue interface{} = key
+		if keyField.Type == TypeBool {
+			keyValue = key == "true"
+		}
+
+		// map entries have key and value even if they are zero values
+		var entryBytes []byte
+		if entryBytes, err = d.encodeSingular(nil, keyField, keyValue, false); err != nil {
+			return nil, fmt.Errorf("map key %q: %w", key, err)
+		}
+
+		if entryBytes, err = d.encodeSingular(entryBytes, valueField, object[key], false); err != nil {
+			return nil, fmt.Errorf("map value of %q: %w", key, err)
+		}
+
+		b = AppendMessageField(b, field.Number, entryBytes)
+	}
+
+	return b, nil
+}
+
+func (d *Descriptors) encodeSingular(b []byte, field *FieldDescriptor, value interface{},
+	omitZero bool,
+) ([]byte, error) {
+	if field.Type == TypeMessage {
+		message := d.Message(field.TypeName)
+		if message == nil {
+			return nil, fmt.Errorf("unknown message type %q", field.TypeName)
+		}
+
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("JSON object expected for %s", field.TypeName)
+		}
+
+		encoded, err := d.encodeMessage(message, object)
+		if err != nil {
+			return nil, err
+		}
+
+		return AppendMessageField(b, field.Number, encoded), nil
+	}
+
+	typ, number, raw, err := d.encodeScalar(field, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ == WireBytes {
+		if omitZero && len(raw) == 0 {
+			return b, nil
+		}
+
+		return AppendMessageField(b, field.Number, raw), nil
+	}
+
+	if omitZero && number == 0 {
+		return b, nil
+	}
+
+	b = AppendTag(b, field.Number, typ)
+
+	return appendNumber(b, typ, number), nil
+}
+
+// encodeScalar returns wire type and either number for varint and fixed types or raw bytes.
+//
+//nolint:cyclop // switch over all scalar types
+func (d *Descriptors) encodeScalar(field *FieldDescriptor, value interface{}) (WireType, uint64, []byte, error) {
+	switch field.Type {
+	case TypeString:
+		s, ok := value.(string)
+		if !ok {
+			return 0, 0, nil, errors.New("JSON string expected")
+		}
+
+		return WireBytes, 0, []byte(s), nil
+	case TypeBytes:
+		s, ok := value.(string)
+		if !ok {
+			return 0, 0, nil, errors.New("JSON base64 string expected")
+		}
+
+		raw, err := decodeBase64(s)
+
+		return WireBytes, 0, raw, err
+	case TypeBool:
+		v, ok := value.(bool)
+		if !ok {
+			return 0, 0, nil, errors.New("JSON boolean expected")
+		}
+
+		if v {
+			return WireVarint, 1, nil, nil
+		}
+
+		return WireVarint, 0, nil, nil
+	case TypeEnum:
+		number, err := d.enumNumber(field, value)
+		return WireVarint, uint64(int64(number)), nil, err
+	case TypeDouble:
+		v, err := jsonFloat(value, 64)
+		return WireFixed64, math.Float64bits(v), nil, err
+	case TypeFloat:
+		v, err := jsonFloat(value, 32)
+		return WireFixed32, uint64(math.Float32bits(float32(v))), nil, err
+	case TypeInt32, TypeInt64:
+		v, err := jsonInt(value, bitSize(field.Type))
+		return WireVarint, uint64(v), nil, err
+	case TypeSint32, TypeSint64:
+		v, err := jsonInt(value, bitSize(field.Type))
+		return WireVarint, uint64(v<<1) ^ uint64(v>>63), nil, err
+	case TypeSfixed32:
+		v, err := jsonInt(value, 32)
+		return WireFixed32, uint64(uint32(v)), nil, err
+	case TypeSfixed64:
+		v, err := jsonInt(value, 64)
+		return WireFixed64, uint64(v), nil, err
+	case TypeUint32, TypeUint64:
+		v, err := jsonUint(value, bitSize(field.Type))
+		return WireVarint, v, nil, err
+	case TypeFixed32:
+		v, err := jsonUint(value, 32)
+		return WireFixed32, v, nil, err
+	case TypeFixed64:
+		v, err := jsonUint(value, 64)
+		return WireFixed64, v, nil, err
+	default:
+		return 0, 0, nil, fmt.Errorf("unsupported field type %d", field.Type)
+	}
+}
+
+func (d *Descriptors) enumNumber(field *FieldDescriptor, value interface{}) (int32, error) {
+	if name, ok := value.(string); ok {
+		enum := d.Enum(field.TypeName)
+		if enum == nil {
+			return 0, fmt.Errorf("unknown enum type %q", field.TypeName)
+		}
+
+		number, ok := enum.Values[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown value %q of enum %s", name, field.TypeName)
+		}
+
+		return number, nil
+	}
+
+	number, err := jsonInt(value, 32)
+
+	return int32(number), err
+}
+
+func appendNumber(b []byte, typ WireType, number uint64) []byte {
+	switch typ {
+	case WireFixed32:
+		var fixed [4]byte
+		binary.LittleEndian.PutUint32(fixed[:], uint32(number))
+
+		return append(b, fixed[:]...)
+	case WireFixed64:
+		var fixed [8]byte
+		binary.LittleEndian.PutUint64(fixed[:], number)
+
+		return append(b, fixed[:]...)
+	default:
+		return AppendVarint(b, number)
+	}
+}
+
+func bitSize(typ FieldType) int {
+	switch typ {
+	case TypeInt32, TypeSint32, TypeUint32, TypeFixed32, TypeSfixed32:
+		return 32
+	default:
+		return 64
+	}
+}
+
+// jsonNumberString returns number as string, proto3 JSON allows numbers in strings.
+func jsonNumberString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.String(), nil
+	case string:
+		return v, nil
+	default:
+		return "", errors.New("JSON number expected")
+	}
+}
+
+func jsonInt(value interface{}, bits int) (int64, error) {
+	s, err := jsonNumberString(value)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseInt(s, 10, bits)
+	if err != nil {
+		// integer can be written in exponent notation, e.g. 1e3
+		f, floatErr := strconv.ParseFloat(s, 64)
+		if floatErr != nil || f != math.Trunc(f) {
+			return 0, err
+		}
+
+		return strconv.ParseInt(strconv.FormatFloat(f, 'f', -1, 64), 10, bits)
+	}
+
+	return v, nil
+}
+
+func jsonUint(value interface{}, bits int) (uint64, error) {
+	s, err := jsonNumberString(value)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseUint(s, 10, bits)
+	if err != nil {
+		f, floatErr := strconv.ParseFloat(s, 64)
+		if floatErr != nil || f != math.Trunc(f) {
+			return 0, err
+		}
+
+		return strconv.ParseUint(strconv.FormatFloat(f, 'f', -1, 64), 10, bits)
+	}
+
+	return v, nil
+}
+
+func jsonFloat(value interface{}, bits int) (float64, error) {
+	s, err := jsonNumberString(value)
+	if err != nil {
+		return 0, err
+	}
+
+	switch s {
+	case "NaN":
+		return math.NaN(), nil
+	case "Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	}
+
+	return strconv.ParseFloat(s, bits)
+}
+
+// decodeBase64 accepts standard and URL-safe base64 with or without padding.
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+// sortedMapKeys sorts keys as deterministic protobuf encoding does, numeric keys by value.
+func sortedMapKeys(keyType FieldType, object map[string]interface{}) ([]string, error) {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+
+	switch keyType {
+	case TypeString:
+		sort.Strings(keys)
+	case TypeBool:
+		sort.Slice(keys, func(i, j int) bool { return keys[i] == "false" && keys[j] == "true" })
+	case TypeUint32, TypeUint64, TypeFixed32, TypeFixed64:
+		numbers := make(map[string]uint64, len(keys))
+
+		for _, key := range keys {
+			number, err := strconv.ParseUint(key, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("map key %q: %w", key, err)
+			}
+
+			numbers[key] = number
+		}
+
+		sort.Slice(keys, func(i, j int) bool { return numbers[keys[i]] < numbers[keys[j]] })
+	default:
+		numbers := make(map[string]int64, len(keys))
+
+		for _, key := range keys {
+			number, err := strconv.ParseInt(key, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("map key %q: %w", key, err)
+			}
+
+			numbers[key] = number
+		}
+
+		sort.Slice(keys, func(i, j int) bool { return numbers[keys[i]] < numbers[keys[j]] })
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,127 @@
+package pb_test
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"github.com/v-electrolux/http2grpc/internal/pb"
+)
+
+// stubDescriptorSet is FileDescriptorSet of stub/v1/stub.proto produced by google.golang.org/protobuf:
+//
+//	syntax = "proto3";
+//	package stub.v1;
+//	message Reply {
+//	  enum Kind { KIND_UNSPECIFIED = 0; KIND_A = 1; KIND_B = 2; }
+//	  string name = 1; int32 count = 2; repeated int64 ids = 3; Kind kind = 4; Item item = 5;
+//	  repeated Item items = 6; map<string, int32> labels = 7; bytes data = 8; double ratio = 9;
+//	  bool ok = 10; sint32 delta = 11; fixed64 fx = 12; float score = 13; string user_id = 14;
+//	}
+//	message Item { string id = 1; uint32 qty = 2; }
+const stubDescriptorSet = "CroEChJzdHViL3YxL3N0dWIucHJvdG8SB3N0dWIudjEi6AMKBVJlcGx5EhIKBG5hbWUYASABKAlSBG5hbWUSFAoFY291" +
+	"bnQYAiABKAVSBWNvdW50EhAKA2lkcxgDIAMoA1IDaWRzEicKBGtpbmQYBCABKA4yEy5zdHViLnYxLlJlcGx5LktpbmRSBGtpbmQSIQoE" +
+	"aXRlbRgFIAEoCzINLnN0dWIudjEuSXRlbVIEaXRlbRIjCgVpdGVtcxgGIAMoCzINLnN0dWIudjEuSXRlbVIFaXRlbXMSMgoGbGFiZWxz" +
+	"GAcgAygLMhouc3R1Yi52MS5SZXBseS5MYWJlbHNFbnRyeVIGbGFiZWxzEhIKBGRhdGEYCCABKAxSBGRhdGESFAoFcmF0aW8YCSABKAFS" +
+	"BXJhdGlvEg4KAm9rGAogASgIUgJvaxIUCgVkZWx0YRgLIAEoEVIFZGVsdGESDgoCZngYDCABKAZSAmZ4EhQKBXNjb3JlGA0gASgCUgVz" +
+	"Y29yZRIXCgd1c2VyX2lkGA4gASgJUgZ1c2VySWQaOQoLTGFiZWxzRW50cnkSEAoDa2V5GAEgASgJUgNrZXkSFAoFdmFsdWUYAiABKAVS" +
+	"BXZhbHVlOgI4ASI0CgRLaW5kEhQKEEtJTkRfVU5TUEVDSUZJRUQQABIKCgZLSU5EX0EQARIKCgZLSU5EX0IQAiIoCgRJdGVtEg4KAmlk" +
+	"GAEgASgJUgJpZBIQCgNxdHkYAiABKA1SA3F0eWIGcHJvdG8z"
+
+type TestEncodeJSONData struct {
+	messageType string
+	doc         string
+
+	expErr   bool
+	expBytes []byte
+}
+
+// TestEncodeJSONAllTypes golden bytes are produced by protojson.Unmarshal and deterministic proto.Marshal.
+func TestEncodeJSONAllTypes(t *testing.T) {
+	data := TestEncodeJSONData{
+		messageType: "stub.v1.Reply",
+		doc: `{"name":"stub","count":-3,"ids":[1,"2",300],"kind":"KIND_B","item":{"id":"a","qty":2},` +
+			`"items":[{"id":"b"},{"qty":5}],"labels":{"z":1,"a":0},"data":"AAEC","ratio":0.5,"ok":true,` +
+			`"delta":-2,"fx":"18446744073709551615","score":1.5,"userId":"u-1"}`,
+
+		expBytes: []byte{
+			0xa, 0x4, 0x73, 0x74, 0x75, 0x62, 0x10, 0xfd, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x1, 0x1a,
+			0x4, 0x1, 0x2, 0xac, 0x2, 0x20, 0x2, 0x2a, 0x5, 0xa, 0x1, 0x61, 0x10, 0x2, 0x32, 0x3, 0xa, 0x1, 0x62,
+			0x32, 0x2, 0x10, 0x5, 0x3a, 0x5, 0xa, 0x1, 0x61, 0x10, 0x0, 0x3a, 0x5, 0xa, 0x1, 0x7a, 0x10, 0x1, 0x42,
+			0x3, 0x0, 0x1, 0x2, 0x49, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xe0, 0x3f, 0x50, 0x1, 0x58, 0x3, 0x61, 0xff,
+			0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x6d, 0x0, 0x0, 0xc0, 0x3f, 0x72, 0x3, 0x75, 0x2d, 0x31,
+		},
+	}
+	testEncodeJSON(t, data)
+}
+
+func TestEncodeJSONProtoNamesAndZeroValues(t *testing.T) {
+	data := TestEncodeJSONData{
+		messageType: ".stub.v1.Reply",
+		doc:         `{"user_id":"u-2","count":0,"kind":1,"item":{}}`,
+
+		expBytes: []byte{0x20, 0x1, 0x2a, 0x0, 0x72, 0x3, 0x75, 0x2d, 0x32},
+	}
+	testEncodeJSON(t, data)
+}
+
+func TestEncodeJSONUnknownField(t *testing.T) {
+	data := TestEncodeJSONData{
+		messageType: "stub.v1.Reply",
+		doc:         `{"unknown":1}`,
+
+		expErr: true,
+	}
+	testEncodeJSON(t, data)
+}
+
+func TestEncodeJSONUnknownEnumValue(t *testing.T) {
+	data := TestEncodeJSONData{
+		messageType: "stub.v1.Reply",
+		doc:         `{"kind":"KIND_C"}`,
+
+		expErr: true,
+	}
+	testEncodeJSON(t, data)
+}
+
+func TestEncodeJSONUnknownMessageType(t *testing.T) {
+	data := TestEncodeJSONData{
+		messageType: "stub.v1.Request",
+		doc:         `{}`,
+
+		expErr: true,
+	}
+	testEncodeJSON(t, data)
+}
+
+func testEncodeJSON(t *testing.T, data TestEncodeJSONData) {
+	t.Helper()
+
+	raw, err := base64.StdEncoding.DecodeString(stubDescriptorSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	descriptors, err := pb.ParseFileDescriptorSet(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := descriptors.EncodeJSON(data.messageType, []byte(data.doc))
+	if data.expErr {
+		if err == nil {
+			t.Errorf("expected error, got bytes: `%#v`", got)
+		}
+
+		return
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, data.expBytes) {
+		t.Errorf("expected bytes: `%#v`, got: `%#v`", data.expBytes, got)
+	}
+}
@@ -0,0 +1,135 @@
+package pb
+
+import "time"
+
+// TypeURLPrefix is the default prefix of google.protobuf.Any type URL.
+const TypeURLPrefix = "type.googleapis.com/"
+
+// Any is google.protobuf.Any from google/protobuf/any.proto.
+type Any struct {
+	TypeURL string
+	Value   []byte
+}
+
+// Marshal encodes Any to protobuf wire format.
+func (a *Any) Marshal() []byte {
+	var b []byte
+	b = AppendStringField(b, 1, a.TypeURL)
+	b = AppendBytesField(b, 2, a.Value)
+
+	return b
+}
+
+// Unmarshal decodes Any from protobuf wire format.
+func (a *Any) Unmarshal(b []byte) error {
+	fields, err := ParseFields(b)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		switch {
+		case field.Num == 1 && field.Type == WireBytes:
+			a.TypeURL = string(field.Bytes)
+		case field.Num == 2 && field.Type == WireBytes:
+			a.Value = append([]byte(nil), field.Bytes...)
+		}
+	}
+
+	return nil
+}
+
+// Status is google.rpc.Status from google/rpc/status.proto,
+// it is sent base64 encoded in grpc-status-details-bin trailer.
+type Status struct {
+	Code    int32
+	Message string
+	Details []*Any
+}
+
+// Marshal encodes Status to protobuf wire format.
+func (s *Status) Marshal() []byte {
+	var b []byte
+	// int32 negative values are encoded as ten bytes varint by spec
+	b = AppendVarintField(b, 1, uint64(int64(s.Code)))
+	b = AppendStringField(b, 2, s.Message)
+
+	for _, detail := range s.Details {
+		b = AppendMessageField(b, 3, detail.Marshal())
+	}
+
+	return b
+}
+
+// Unmarshal decodes Status from protobuf wire format.
+func (s *Status) Unmarshal(b []byte) error {
+	fields, err := ParseFields(b)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		switch {
+		case field.Num == 1 && field.Type == WireVarint:
+			s.Code = int32(field.Value)
+		case field.Num == 2 && field.Type == WireBytes:
+			s.Message = string(field.Bytes)
+		case field.Num == 3 && field.Type == WireBytes:
+			detail := &Any{}
+			if err = detail.Unmarshal(field.Bytes); err != nil {
+				return err
+			}
+
+			s.Details = append(s.Details, detail)
+		}
+	}
+
+	return nil
+}
+
+// Duration is google.protobuf.Duration from google/protobuf/duration.proto.
+type Duration struct {
+	Seconds int64
+	Nanos   int32
+}
+
+// NewDuration converts time.Duration to Duration.
+func NewDuration(d time.Duration) *Duration {
+	return &Duration{
+		Seconds: int64(d / time.Second),
+		Nanos:   int32(d % time.Second),
+	}
+}
+
+// Marshal encodes Duration to protobuf wire format.
+func (d *Duration) Marshal() []byte {
+	var b []byte
+	b = AppendVarintField(b, 1, uint64(d.Seconds))
+	b = AppendVarintField(b, 2, uint64(int64(d.Nanos)))
+
+	return b
+}
+
+// Unmarshal decodes Duration from protobuf wire format.
+func (d *Duration) Unmarshal(b []byte) error {
+	fields, err := ParseFields(b)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		switch {
+		case field.Num == 1 && field.Type == WireVarint:
+			d.Seconds = int64(field.Value)
+		case field.Num == 2 && field.Type == WireVarint:
+			d.Nanos = int32(field.Value)
+		}
+	}
+
+	return nil
+}
+
+// AsDuration converts Duration to time.Duration.
+func (d *Duration) AsDuration() time.Duration {
+	return time.Duration(d.Seconds)*time.Second + time.Duration(d.Nanos)
+}
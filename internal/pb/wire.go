@@ -0,0 +1,188 @@
+// Package pb is a minimal protobuf wire format codec without third-party dependencies,
+// because traefik plugins are interpreted by yaegi.
+// Encoding rules are from https://protobuf.dev/programming-guides/encoding/
+package pb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// WireType is the type of encoded field value.
+type WireType int
+
+// wire types from protobuf encoding spec.
+const (
+	WireVarint  WireType = 0
+	WireFixed64 WireType = 1
+	WireBytes   WireType = 2
+	WireFixed32 WireType = 5
+)
+
+// AppendVarint appends v as base 128 varint.
+func AppendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+
+	return append(b, byte(v))
+}
+
+// AppendTag appends field number and wire type.
+func AppendTag(b []byte, num int, typ WireType) []byte {
+	return AppendVarint(b, uint64(num)<<3|uint64(typ))
+}
+
+// AppendVarintField appends varint field, zero value is omitted as proto3 does.
+func AppendVarintField(b []byte, num int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+
+	b = AppendTag(b, num, WireVarint)
+
+	return AppendVarint(b, v)
+}
+
+// AppendBytesField appends length-delimited field, empty value is omitted as proto3 does.
+func AppendBytesField(b []byte, num int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+
+	return AppendMessageField(b, num, v)
+}
+
+// AppendStringField appends string field, empty value is omitted as proto3 does.
+func AppendStringField(b []byte, num int, v string) []byte {
+	return AppendBytesField(b, num, []byte(v))
+}
+
+// AppendMessageField appends embedded message field, it is written even if empty,
+// because presence of message field matters.
+func AppendMessageField(b []byte, num int, v []byte) []byte {
+	b = AppendTag(b, num, WireBytes)
+	b = AppendVarint(b, uint64(len(v)))
+
+	return append(b, v...)
+}
+
+// AppendStringMapField appends map<string, string> field with sorted keys,
+// so encoding is deterministic.
+func AppendStringMapField(b []byte, num int, m map[string]string) []byte {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var entry []byte
+		entry = AppendStringField(entry, 1, key)
+		entry = AppendStringField(entry, 2, m[key])
+		b = AppendMessageField(b, num, entry)
+	}
+
+	return b
+}
+
+// Field is one decoded field, Value holds varint and fixed values, Bytes holds length-delimited value.
+type Field struct {
+	Num   int
+	Type  WireType
+	Value uint64
+	Bytes []byte
+}
+
+// ParseFields decodes all top level fields of message in wire order,
+// Bytes of length-delimited fields refer to b.
+func ParseFields(b []byte) ([]Field, error) {
+	var fields []Field
+
+	for len(b) > 0 {
+		tag, n := ConsumeVarint(b)
+		if n < 0 {
+			return nil, errors.New("pb: truncated field tag")
+		}
+
+		b = b[n:]
+		field := Field{Num: int(tag >> 3), Type: WireType(tag & 0x07)}
+
+		if field.Num <= 0 {
+			return nil, fmt.Errorf("pb: invalid field number %d", field.Num)
+		}
+
+		switch field.Type {
+		case WireVarint:
+			if field.Value, n = ConsumeVarint(b); n < 0 {
+				return nil, fmt.Errorf("pb: field %d: truncated varint", field.Num)
+			}
+		case WireFixed64:
+			if n = 8; len(b) < n {
+				return nil, fmt.Errorf("pb: field %d: truncated fixed64", field.Num)
+			}
+
+			field.Value = binary.LittleEndian.Uint64(b)
+		case WireFixed32:
+			if n = 4; len(b) < n {
+				return nil, fmt.Errorf("pb: field %d: truncated fixed32", field.Num)
+			}
+
+			field.Value = uint64(binary.LittleEndian.Uint32(b))
+		case WireBytes:
+			length, m := ConsumeVarint(b)
+			if m < 0 || uint64(len(b)-m) < length {
+				return nil, fmt.Errorf("pb: field %d: truncated bytes", field.Num)
+			}
+
+			field.Bytes = b[m : m+int(length)]
+			n = m + int(length)
+		default:
+			return nil, fmt.Errorf("pb: field %d: unsupported wire type %d", field.Num, field.Type)
+		}
+
+		b = b[n:]
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// ConsumeVarint decodes base 128 varint and returns its length, which is negative on error.
+func ConsumeVarint(b []byte) (uint64, int) {
+	var v uint64
+
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * uint(i))
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+
+	return 0, -1
+}
+
+// ParseStringMapEntry decodes key and value of map<string, string> entry.
+func ParseStringMapEntry(b []byte) (string, string, error) {
+	fields, err := ParseFields(b)
+	if err != nil {
+		return "", "", err
+	}
+
+	var key, value string
+
+	for _, field := range fields {
+		switch {
+		case field.Num == 1 && field.Type == WireBytes:
+			key = string(field.Bytes)
+		case field.Num == 2 && field.Type == WireBytes:
+			value = string(field.Bytes)
+		}
+	}
+
+	return key, value, nil
+}
@@ -0,0 +1,184 @@
+package http2grpc
+
+import (
+	"fmt"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"github.com/v-electrolux/http2grpc/internal/pb"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const AcceptLanguageHeaderName = "Accept-Language"
+
+// LocalizationConfig describes catalogs of user-facing status messages,
+// sent in google.rpc.LocalizedMessage detail alongside developer-facing grpc-message.
+type LocalizationConfig struct {
+	// Catalogs are messages by locale, like ru-RU, and by gRPC status code name or number, like UNAUTHENTICATED
+	Catalogs map[string]map[string]string `yaml:"catalogs"`
+	// LocaleHeader is request header (gRPC metadata), like `x-locale`, which takes precedence over Accept-Language
+	LocaleHeader string `yaml:"localeHeader"`
+	// DefaultLocale is used if none of requested locales has message of the code, no detail is sent if empty
+	DefaultLocale string `yaml:"defaultLocale"`
+}
+
+type localeCatalog struct {
+	// locale is as configured, it is sent in LocalizedMessage
+	locale   string
+	messages map[grpc.Code]string
+}
+
+type localizer struct {
+	config *LocalizationConfig
+	// catalogs are keyed by lower case locale
+	catalogs map[string]*localeCatalog
+}
+
+// newLocalizer returns nil if there are no catalogs.
+func newLocalizer(config *LocalizationConfig) (*localizer, error) {
+	if len(config.Catalogs) == 0 {
+		return nil, nil //nolint:nilnil // disabled feature
+	}
+
+	l := &localizer{config: config, catalogs: make(map[string]*localeCatalog, len(config.Catalogs))}
+
+	for locale, messages := range config.Catalogs {
+		catalog := &localeCatalog{locale: locale, messages: make(map[grpc.Code]string, len(messages))}
+
+		for name, message := range messages {
+			code, err := grpc.ParseCode(name)
+			if err != nil || code == grpc.OK {
+				return nil, fmt.Errorf("ERROR: http2grpc: localization.catalogs.%s: %q is not gRPC error code", locale, name)
+			}
+
+			catalog.messages[code] = message
+		}
+
+		l.catalogs[strings.ToLower(locale)] = catalog
+	}
+
+	if config.DefaultLocale != "" {
+		if _, ok := l.catalogs[strings.ToLower(config.DefaultLocale)]; !ok {
+			return nil, fmt.Errorf("ERROR: http2grpc: localization.defaultLocale %q has no catalog", config.DefaultLocale)
+		}
+	}
+
+	return l, nil
+}
+
+// requestLocales returns locales requested by locale header or by Accept-Language in order of preference,
+// followed by default locale.
+func (l *localizer) requestLocales(req *http.Request) []string {
+	var locales []string
+
+	if l.config.LocaleHeader != "" {
+		if locale := strings.TrimSpace(req.Header.Get(l.config.LocaleHeader)); locale != "" {
+			locales = append(locales, locale)
+		}
+	}
+
+	if len(locales) == 0 {
+		locales = parseAcceptLanguage(req.Header.Values(AcceptLanguageHeaderName))
+	}
+
+	if l.config.DefaultLocale != "" {
+		locales = append(locales, l.config.DefaultLocale)
+	}
+
+	return locales
+}
+
+// message returns the first message of code from catalogs of locales, a locale matches its catalog
+// or the catalog of its language, like ru-RU matches ru.
+func (l *localizer) message(code grpc.Code, locales []string) (*pb.LocalizedMessage, bool) {
+	for _, locale := range locales {
+		locale = strings.ToLower(locale)
+
+		for _, candidate := range []string{locale, strings.SplitN(locale, "-", 2)[0]} {
+			if catalog, ok := l.catalogs[candidate]; ok {
+				if message, ok := catalog.messages[code]; ok {
+					return &pb.LocalizedMessage{Locale: catalog.locale, Message: message}, true
+				}
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// parseAcceptLanguage returns language ranges of Accept-Language header sorted by quality,
+// the ones with zero quality and wildcard are dropped.
+func parseAcceptLanguage(values []string) []string {
+	type languageRange struct {
+		tag     string
+		quality float64
+	}
+
+	var ranges []languageRange
+
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			params := strings.Split(part, ";")
+			tag := strings.TrimSpace(params[0])
+			quality := 1.0
+
+			for _, param := range params[1:] {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+						quality = q
+					}
+				}
+			}
+
+			if tag != "" && tag != "*" && quality > 0 {
+				ranges = append(ranges, languageRange{tag: tag, quality: quality})
+			}
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	tags := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		tags = append(tags, r.tag)
+	}
+
+	return tags
+}
+
+// finishLocalization adds LocalizedMessage detail to not OK status, unless the status already has one,
+// it must be called after all other trailers are set.
+func (h *http2grpcModifier) finishLocalization() {
+	if h.localizer == nil || !h.headerSent {
+		return
+	}
+
+	header := h.responseWriter.Header()
+
+	status, err := grpc.FromHeader(header)
+	if err != nil || status.Code == grpc.OK {
+		return
+	}
+
+	localized, ok := h.localizer.message(status.Code, h.locales)
+	if !ok {
+		return
+	}
+
+	for _, detail := range status.Details {
+		if detail.TypeURL == pb.TypeURLPrefix+localized.FullName() {
+			LoggerDEBUG.Printf("finish() status already has localized message")
+			return
+		}
+	}
+
+	LoggerDEBUG.Printf("finish() localized message %s: %s", localized.Locale, localized.Message)
+
+	packed := pb.NewAny(localized)
+	status.Details = append(status.Details, grpc.Any{TypeURL: packed.TypeURL, Value: packed.Value})
+	status.SetTrailers(header)
+}
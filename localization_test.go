@@ -0,0 +1,226 @@
+package http2grpc_test
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/v-electrolux/http2grpc"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"github.com/v-electrolux/http2grpc/internal/pb"
+)
+
+type TestLocalizationData struct {
+	cfgLocaleHeader  string
+	cfgDefaultLocale string
+	cfgDeny          []string
+
+	reqHeaders map[string]string
+
+	// backendGrpcStatus is sent by gRPC backend with ErrorInfo detail, HTTP 401 is sent if it is empty
+	backendGrpcStatus string
+
+	expGrpcResStatusCode int
+	expGrpcResStatusMsg  string
+	expDetails           []pb.DetailMessage
+}
+
+// testCatalogs are shared by localization tests.
+func testCatalogs() map[string]map[string]string {
+	return map[string]map[string]string{
+		"ru": {
+			"UNAUTHENTICATED": "Войдите снова",
+			"7":               "Доступ запрещён",
+		},
+		"en-US": {
+			"unauthenticated": "Please sign in again",
+			"NOT_FOUND":       "Nothing here",
+		},
+	}
+}
+
+func TestLocalizationAcceptLanguage(t *testing.T) {
+	data := TestLocalizationData{
+		reqHeaders: map[string]string{"Accept-Language": "de;q=0.5, ru-RU, en-US;q=0.8"},
+
+		expGrpcResStatusCode: grpc.UNAUTHENTICATED,
+		expGrpcResStatusMsg:  "token expired",
+		expDetails:           []pb.DetailMessage{&pb.LocalizedMessage{Locale: "ru", Message: "Войдите снова"}},
+	}
+	testLocalizationRequest(t, data)
+}
+
+func TestLocalizationLocaleHeaderTakesPrecedence(t *testing.T) {
+	data := TestLocalizationData{
+		cfgLocaleHeader: "x-locale",
+
+		reqHeaders: map[string]string{"Accept-Language": "ru", "X-Locale": "EN-us"},
+
+		expGrpcResStatusCode: grpc.UNAUTHENTICATED,
+		expGrpcResStatusMsg:  "token expired",
+		expDetails:           []pb.DetailMessage{&pb.LocalizedMessage{Locale: "en-US", Message: "Please sign in again"}},
+	}
+	testLocalizationRequest(t, data)
+}
+
+func TestLocalizationDefaultLocale(t *testing.T) {
+	data := TestLocalizationData{
+		cfgDefaultLocale: "en-US",
+
+		reqHeaders: map[string]string{"Accept-Language": "fr-FR, *;q=0.1, ru;q=0"},
+
+		expGrpcResStatusCode: grpc.UNAUTHENTICATED,
+		expGrpcResStatusMsg:  "token expired",
+		expDetails:           []pb.DetailMessage{&pb.LocalizedMessage{Locale: "en-US", Message: "Please sign in again"}},
+	}
+	testLocalizationRequest(t, data)
+}
+
+func TestLocalizationNoMatchingLocale(t *testing.T) {
+	data := TestLocalizationData{
+		reqHeaders: map[string]string{"Accept-Language": "fr"},
+
+		expGrpcResStatusCode: grpc.UNAUTHENTICATED,
+		expGrpcResStatusMsg:  "token expired",
+		expDetails:           nil,
+	}
+	testLocalizationRequest(t, data)
+}
+
+func TestLocalizationGrpcBackendStatusKeepsDetails(t *testing.T) {
+	data := TestLocalizationData{
+		reqHeaders: map[string]string{"Accept-Language": "en-us"},
+
+		backendGrpcStatus: "5",
+
+		expGrpcResStatusCode: grpc.NOT_FOUND,
+		expGrpcResStatusMsg:  "no such user",
+		expDetails: []pb.DetailMessage{
+			&pb.ErrorInfo{Reason: "USER_NOT_FOUND"},
+			&pb.LocalizedMessage{Locale: "en-US", Message: "Nothing here"},
+		},
+	}
+	testLocalizationRequest(t, data)
+}
+
+func TestLocalizationGrpcBackendOkStatus(t *testing.T) {
+	data := TestLocalizationData{
+		reqHeaders: map[string]string{"Accept-Language": "ru"},
+
+		backendGrpcStatus: "0",
+
+		expGrpcResStatusCode: grpc.OK,
+		expGrpcResStatusMsg:  "no such user",
+		expDetails:           []pb.DetailMessage{&pb.ErrorInfo{Reason: "USER_NOT_FOUND"}},
+	}
+	testLocalizationRequest(t, data)
+}
+
+func TestLocalizationInterceptedCall(t *testing.T) {
+	data := TestLocalizationData{
+		cfgDeny: []string{"/pkg.v1.Svc/*"},
+
+		reqHeaders: map[string]string{"Accept-Language": "ru"},
+
+		expGrpcResStatusCode: grpc.PERMISSION_DENIED,
+		expGrpcResStatusMsg:  "method is not allowed",
+		expDetails:           []pb.DetailMessage{&pb.LocalizedMessage{Locale: "ru", Message: "Доступ запрещён"}},
+	}
+	testLocalizationRequest(t, data)
+}
+
+func TestLocalizationInvalidConfig(t *testing.T) {
+	cfg := http2grpc.CreateConfig()
+	cfg.Localization.Catalogs = map[string]map[string]string{"ru": {"OK": "Хорошо"}}
+
+	_, err := http2grpc.New(context.Background(), http.NotFoundHandler(), cfg, "http2grpc")
+	if err == nil {
+		t.Errorf("expected error for OK code in catalog")
+	}
+
+	cfg.Localization.Catalogs = testCatalogs()
+	cfg.Localization.DefaultLocale = "de"
+
+	_, err = http2grpc.New(context.Background(), http.NotFoundHandler(), cfg, "http2grpc")
+	if err == nil {
+		t.Errorf("expected error for default locale without catalog")
+	}
+}
+
+func testLocalizationRequest(t *testing.T, data TestLocalizationData) {
+	t.Helper()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.BodyAsStatusMessage = true
+	cfg.Localization.Catalogs = testCatalogs()
+	cfg.Localization.LocaleHeader = data.cfgLocaleHeader
+	cfg.Localization.DefaultLocale = data.cfgDefaultLocale
+	cfg.MethodACL.Deny = data.cfgDeny
+
+	errorInfo := pb.NewAny(&pb.ErrorInfo{Reason: "USER_NOT_FOUND"})
+	backendStatus := &pb.Status{Code: 5, Message: "no such user", Details: []*pb.Any{errorInfo}}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if data.backendGrpcStatus == "" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			rw.Write([]byte("token expired"))
+
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/grpc")
+		rw.WriteHeader(http.StatusOK)
+		rw.Header().Set(http.TrailerPrefix+"grpc-status", data.backendGrpcStatus)
+		rw.Header().Set(http.TrailerPrefix+"grpc-message", "no such user")
+		rw.Header().Set(http.TrailerPrefix+"grpc-status-details-bin",
+			base64.RawStdEncoding.EncodeToString(backendStatus.Marshal()))
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost/pkg.v1.Svc/Get", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, value := range data.reqHeaders {
+		req.Header.Set(key, value)
+	}
+
+	handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertTrailer(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+	assertTrailer(t, resp, "grpc-message", data.expGrpcResStatusMsg)
+
+	status, err := grpc.FromHeader(resp.Trailer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	details := make([]pb.DetailMessage, 0, len(status.Details))
+
+	for _, packed := range status.Details {
+		detail, err := pb.UnmarshalDetail(&pb.Any{TypeURL: packed.TypeURL, Value: packed.Value})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		details = append(details, detail)
+	}
+
+	if len(details) != len(data.expDetails) || (len(details) > 0 && !reflect.DeepEqual(details, data.expDetails)) {
+		t.Errorf("expected details %+v, got %+v", data.expDetails, details)
+	}
+}
@@ -0,0 +1,185 @@
+package http2grpc
+
+import (
+	"fmt"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaintenanceConfig describes planned maintenance, when every gRPC call is answered with UNAVAILABLE
+// without calling backend, except allowed ones.
+type MaintenanceConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Message is gRPC status message
+	Message string `yaml:"message"`
+	// RetryPushback is sent in grpc-retry-pushback-ms trailer as go duration string, not sent if empty
+	RetryPushback string `yaml:"retryPushback"`
+	// AllowMethods are glob patterns of gRPC methods still passed to backend, e.g. health checks
+	AllowMethods []string `yaml:"allowMethods"`
+	// AllowClientIPs are IPs or CIDRs of clients still passed to backend
+	AllowClientIPs []string `yaml:"allowClientIPs"`
+	// AllowHeaders are request headers (gRPC metadata) with values, any of them passes request to backend
+	AllowHeaders map[string]string `yaml:"allowHeaders"`
+	// Start of maintenance window in RFC 3339 format, not limited if empty
+	Start string `yaml:"start"`
+	// End of maintenance window in RFC 3339 format, not limited if empty
+	End string `yaml:"end"`
+}
+
+type maintenance struct {
+	config       *MaintenanceConfig
+	allowMethods methodMatcher
+	allowNets    []*net.IPNet
+	start        time.Time
+	end          time.Time
+	// retryPushbackMs is grpc-retry-pushback-ms trailer value, empty if not sent
+	retryPushbackMs string
+}
+
+// newMaintenance returns nil if maintenance is disabled.
+func newMaintenance(config *MaintenanceConfig) (*maintenance, error) {
+	if !config.Enabled {
+		return nil, nil //nolint:nilnil // disabled feature
+	}
+
+	allowMethods, err := newMethodMatcher(config.AllowMethods)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &maintenance{
+		config:       config,
+		allowMethods: allowMethods,
+		allowNets:    make([]*net.IPNet, 0, len(config.AllowClientIPs)),
+	}
+
+	for _, allowed := range config.AllowClientIPs {
+		ipNet, err := parseIPNet(allowed)
+		if err != nil {
+			return nil, fmt.Errorf("ERROR: http2grpc: maintenance.allowClientIPs: %w", err)
+		}
+
+		m.allowNets = append(m.allowNets, ipNet)
+	}
+
+	for name, value := range config.AllowHeaders {
+		// empty value would match every request without the header and turn maintenance off
+		if value == "" {
+			return nil, fmt.Errorf("ERROR: http2grpc: maintenance.allowHeaders: empty value of %s", name)
+		}
+	}
+
+	if m.start, err = parseOptionalTime(config.Start); err != nil {
+		return nil, fmt.Errorf("ERROR: http2grpc: maintenance.start: %w", err)
+	}
+
+	if m.end, err = parseOptionalTime(config.End); err != nil {
+		return nil, fmt.Errorf("ERROR: http2grpc: maintenance.end: %w", err)
+	}
+
+	if config.RetryPushback != "" {
+		pushback, err := time.ParseDuration(config.RetryPushback)
+		if err != nil {
+			return nil, fmt.Errorf("ERROR: http2grpc: maintenance.retryPushback: %w", err)
+		}
+
+		m.retryPushbackMs = strconv.FormatInt(pushback.Milliseconds(), 10)
+	}
+
+	return m, nil
+}
+
+// intercept answers with UNAVAILABLE during maintenance window, except allowed requests.
+func (m *maintenance) intercept(rw *http2grpcModifier, req *http.Request) bool {
+	if !m.active(time.Now()) || m.allowed(req) {
+		return false
+	}
+
+	LoggerDEBUG.Printf("maintenance: %s rejected", req.URL.Path)
+	rw.writeGrpcStatus(grpc.UNAVAILABLE, m.config.Message)
+
+	if m.retryPushbackMs != "" {
+		grpc.SetTrailer(rw.Header(), GrpcRetryPushbackHeaderName, m.retryPushbackMs)
+	}
+
+	return true
+}
+
+func (m *maintenance) active(now time.Time) bool {
+	if !m.start.IsZero() && now.Before(m.start) {
+		return false
+	}
+
+	if !m.end.IsZero() && !now.Before(m.end) {
+		return false
+	}
+
+	return true
+}
+
+func (m *maintenance) allowed(req *http.Request) bool {
+	if m.allowMethods.match(grpcMethod(req)) {
+		return true
+	}
+
+	for name, value := range m.config.AllowHeaders {
+		if req.Header.Get(name) == value {
+			return true
+		}
+	}
+
+	if len(m.allowNets) == 0 {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	clientIP := net.ParseIP(host)
+	if clientIP == nil {
+		return false
+	}
+
+	for _, allowNet := range m.allowNets {
+		if allowNet.Contains(clientIP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseIPNet parses CIDR or single IP.
+func parseIPNet(value string) (*net.IPNet, error) {
+	if strings.Contains(value, "/") {
+		_, ipNet, err := net.ParseCIDR(value)
+		return ipNet, err
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP %q", value)
+	}
+
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bits = 8 * net.IPv4len
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+func parseOptionalTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
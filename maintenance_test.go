@@ -0,0 +1,204 @@
+package http2grpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/v-electrolux/http2grpc"
+)
+
+type TestMaintenanceData struct {
+	cfgMaintenance http2grpc.MaintenanceConfig
+
+	reqPath       string
+	reqRemoteAddr string
+	reqHeaders    map[string]string
+
+	expBackendCalled     bool
+	expGrpcResStatusCode int
+	expGrpcResStatusMsg  string
+	expRetryPushback     string
+}
+
+func TestMaintenanceRejects(t *testing.T) {
+	data := TestMaintenanceData{
+		cfgMaintenance: http2grpc.MaintenanceConfig{
+			Enabled: true, Message: "planned maintenance", RetryPushback: "1m",
+		},
+
+		expBackendCalled:     false,
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "planned maintenance",
+		expRetryPushback:     "60000",
+	}
+	testMaintenanceRequest(t, data)
+}
+
+func TestMaintenanceAllowedMethod(t *testing.T) {
+	data := TestMaintenanceData{
+		cfgMaintenance: http2grpc.MaintenanceConfig{
+			Enabled: true, Message: "planned maintenance", AllowMethods: []string{"/grpc.health.v1.Health/*"},
+		},
+
+		reqPath: "/grpc.health.v1.Health/Check",
+
+		expBackendCalled:     true,
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testMaintenanceRequest(t, data)
+}
+
+func TestMaintenanceAllowedClientIP(t *testing.T) {
+	data := TestMaintenanceData{
+		cfgMaintenance: http2grpc.MaintenanceConfig{
+			Enabled: true, Message: "planned maintenance", AllowClientIPs: []string{"10.0.0.0/8", "192.168.1.1"},
+		},
+
+		reqRemoteAddr: "192.168.1.1:51000",
+
+		expBackendCalled:     true,
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testMaintenanceRequest(t, data)
+}
+
+func TestMaintenanceNotAllowedClientIP(t *testing.T) {
+	data := TestMaintenanceData{
+		cfgMaintenance: http2grpc.MaintenanceConfig{
+			Enabled: true, Message: "planned maintenance", AllowClientIPs: []string{"10.0.0.0/8"},
+		},
+
+		reqRemoteAddr: "192.168.1.1:51000",
+
+		expBackendCalled:     false,
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "planned maintenance",
+	}
+	testMaintenanceRequest(t, data)
+}
+
+func TestMaintenanceAllowedHeader(t *testing.T) {
+	data := TestMaintenanceData{
+		cfgMaintenance: http2grpc.MaintenanceConfig{
+			Enabled: true, Message: "planned maintenance", AllowHeaders: map[string]string{"X-Maintainer": "yes"},
+		},
+
+		reqHeaders: map[string]string{"X-Maintainer": "yes"},
+
+		expBackendCalled:     true,
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testMaintenanceRequest(t, data)
+}
+
+func TestMaintenanceBeforeWindow(t *testing.T) {
+	data := TestMaintenanceData{
+		cfgMaintenance: http2grpc.MaintenanceConfig{
+			Enabled: true, Message: "planned maintenance", Start: time.Now().Add(time.Hour).Format(time.RFC3339),
+		},
+
+		expBackendCalled:     true,
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testMaintenanceRequest(t, data)
+}
+
+func TestMaintenanceInsideWindow(t *testing.T) {
+	data := TestMaintenanceData{
+		cfgMaintenance: http2grpc.MaintenanceConfig{
+			Enabled: true, Message: "planned maintenance",
+			Start: time.Now().Add(-time.Hour).Format(time.RFC3339),
+			End:   time.Now().Add(time.Hour).Format(time.RFC3339),
+		},
+
+		expBackendCalled:     false,
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "planned maintenance",
+	}
+	testMaintenanceRequest(t, data)
+}
+
+func TestMaintenanceAfterWindow(t *testing.T) {
+	data := TestMaintenanceData{
+		cfgMaintenance: http2grpc.MaintenanceConfig{
+			Enabled: true, Message: "planned maintenance", End: time.Now().Add(-time.Hour).Format(time.RFC3339),
+		},
+
+		expBackendCalled:     true,
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testMaintenanceRequest(t, data)
+}
+
+func TestMaintenanceEmptyAllowHeaderValue(t *testing.T) {
+	cfg := http2grpc.CreateConfig()
+	cfg.Maintenance = http2grpc.MaintenanceConfig{Enabled: true, AllowHeaders: map[string]string{"X-Maintainer": ""}}
+
+	_, err := http2grpc.New(context.Background(), http.NotFoundHandler(), cfg, "http2grpc")
+	if err == nil {
+		t.Errorf("expected error for empty allowHeaders value")
+	}
+}
+
+func testMaintenanceRequest(t *testing.T, data TestMaintenanceData) {
+	t.Helper()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.Maintenance = data.cfgMaintenance
+
+	backendCalled := false
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		backendCalled = true
+
+		rw.Header().Set("Content-Type", "application/grpc")
+		rw.Header().Set("Trailer", "grpc-status")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte{0x00, 0x00, 0x00, 0x00, 0x00})
+		rw.Header().Set("grpc-status", "0")
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	reqPath := data.reqPath
+	if reqPath == "" {
+		reqPath = "/pkg.Service/Method"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost"+reqPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.RemoteAddr = data.reqRemoteAddr
+	for key, value := range data.reqHeaders {
+		req.Header.Set(key, value)
+	}
+
+	handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+
+	if backendCalled != data.expBackendCalled {
+		t.Errorf("expected backend called: `%t`, got: `%t`", data.expBackendCalled, backendCalled)
+	}
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertHeader(t, resp, "Content-Type", "application/grpc")
+	assertTrailer(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+	assertTrailer(t, resp, "grpc-message", data.expGrpcResStatusMsg)
+	assertTrailer(t, resp, "grpc-retry-pushback-ms", data.expRetryPushback)
+}
@@ -0,0 +1,150 @@
+package http2grpc
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// MessageSizeLimitConfig describes max sizes of gRPC messages of methods.
+type MessageSizeLimitConfig struct {
+	// Methods are glob patterns of gRPC methods, all methods if empty
+	Methods []string `yaml:"methods"`
+	// MaxRequestMessageSize is max length of one request message, not limited if 0
+	MaxRequestMessageSize int `yaml:"maxRequestMessageSize"`
+	// MaxResponseMessageSize is max length of one response message, not limited if 0
+	MaxResponseMessageSize int `yaml:"maxResponseMessageSize"`
+}
+
+// MessageSizeLimitsConfig describes max sizes of gRPC messages checked by middleware,
+// the call is terminated with RESOURCE_EXHAUSTED as soon as a message exceeds the limit.
+type MessageSizeLimitsConfig struct {
+	// Limits are checked in order, the first one matching method is applied
+	Limits []MessageSizeLimitConfig `yaml:"limits"`
+}
+
+type messageSizeLimit struct {
+	config  *MessageSizeLimitConfig
+	methods methodMatcher
+}
+
+type messageSizeLimits struct {
+	limits []messageSizeLimit
+}
+
+// newMessageSizeLimits returns nil if there are no limits.
+func newMessageSizeLimits(config *MessageSizeLimitsConfig) (*messageSizeLimits, error) {
+	if len(config.Limits) == 0 {
+		return nil, nil //nolint:nilnil // disabled feature
+	}
+
+	l := &messageSizeLimits{limits: make([]messageSizeLimit, 0, len(config.Limits))}
+
+	for i := range config.Limits {
+		limitConfig := &config.Limits[i]
+		if limitConfig.MaxRequestMessageSize < 0 || limitConfig.MaxResponseMessageSize < 0 {
+			return nil, fmt.Errorf("ERROR: http2grpc: messageSizeLimits.limits[%d]: negative size", i)
+		}
+
+		methods, err := newMethodMatcher(limitConfig.Methods)
+		if err != nil {
+			return nil, err
+		}
+
+		l.limits = append(l.limits, messageSizeLimit{config: limitConfig, methods: methods})
+	}
+
+	return l, nil
+}
+
+// apply enables response framing check with the limit of the first matching method limit
+// and replaces request body to check request messages while backend reads it,
+// returned body is nil if request messages are not limited.
+func (l *messageSizeLimits) apply(rw *http2grpcModifier, req *http.Request) *limitedGrpcBody {
+	method := grpcMethod(req)
+
+	for _, limit := range l.limits {
+		if !limit.methods.matchOrEmpty(method) {
+			continue
+		}
+
+		if limit.config.MaxResponseMessageSize > 0 {
+			rw.enableFrameValidation(limit.config.MaxResponseMessageSize)
+		}
+
+		if limit.config.MaxRequestMessageSize == 0 || req.Body == nil || req.Body == http.NoBody {
+			return nil
+		}
+
+		body := &limitedGrpcBody{
+			body:   req.Body,
+			parser: newGrpcFrameParser(req.Header.Get(GrpcEncodingHeaderName), limit.config.MaxRequestMessageSize),
+		}
+		req.Body = body
+
+		return body
+	}
+
+	return nil
+}
+
+// limitedGrpcBody checks length-prefixed messages of request body as it is read,
+// read fails on the first violation, so backend call is aborted.
+type limitedGrpcBody struct {
+	body   io.ReadCloser
+	parser *grpcFrameParser
+	// pending are checked bytes not read yet, parser holds back prefix bytes until it is complete
+	pending []byte
+	readErr error
+
+	// body is read by transport in its own goroutine, so violation is guarded by mutex
+	mu        sync.Mutex
+	violation *grpcStatusError
+}
+
+func (b *limitedGrpcBody) Read(p []byte) (int, error) {
+	for len(b.pending) == 0 {
+		if b.readErr != nil {
+			return 0, b.readErr
+		}
+
+		n, err := b.body.Read(p)
+		if n > 0 {
+			out, violation := b.parser.parse(p[:n])
+			b.pending = out
+
+			if violation != nil {
+				LoggerINFO.Printf("invalid grpc request message: %s", violation)
+
+				b.mu.Lock()
+				b.violation = violation
+				b.mu.Unlock()
+
+				// messages before the violation are still read
+				b.readErr = violation
+
+				continue
+			}
+		}
+
+		b.readErr = err
+	}
+
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+
+	return n, nil
+}
+
+func (b *limitedGrpcBody) Close() error {
+	return b.body.Close()
+}
+
+// err returns violation of request message limit, if any.
+func (b *limitedGrpcBody) err() *grpcStatusError {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.violation
+}
@@ -0,0 +1,157 @@
+package http2grpc_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/v-electrolux/http2grpc"
+)
+
+type TestMessageSizeData struct {
+	cfgLimits []http2grpc.MessageSizeLimitConfig
+
+	reqPath string
+	reqBody []byte
+
+	backendGrpcBody []byte
+
+	expBackendReqBody    []byte
+	expGrpcResBody       []byte
+	expGrpcResStatusCode int
+	expGrpcResStatusMsg  string
+}
+
+func TestMessageSizeRequestTooLarge(t *testing.T) {
+	data := TestMessageSizeData{
+		cfgLimits: []http2grpc.MessageSizeLimitConfig{
+			{Methods: []string{"/pkg.v1.Service/*"}, MaxRequestMessageSize: 4},
+		},
+
+		reqPath: "/pkg.v1.Service/Upload",
+		reqBody: append(frame([]byte("ok")), frame([]byte("too large"))...),
+
+		backendGrpcBody: frame([]byte("reply")),
+
+		expBackendReqBody:    frame([]byte("ok")),
+		expGrpcResBody:       []byte{0x00, 0x00, 0x00, 0x00, 0x00},
+		expGrpcResStatusCode: 8,
+		expGrpcResStatusMsg:  "grpc: received message larger than max (9 vs. 4)",
+	}
+	testMessageSizeRequest(t, data)
+}
+
+func TestMessageSizeRequestWithinLimit(t *testing.T) {
+	data := TestMessageSizeData{
+		cfgLimits: []http2grpc.MessageSizeLimitConfig{
+			{MaxRequestMessageSize: 9},
+		},
+
+		reqPath: "/pkg.v1.Service/Upload",
+		reqBody: append(frame([]byte("ok")), frame([]byte("too large"))...),
+
+		backendGrpcBody: frame([]byte("reply")),
+
+		expBackendReqBody:    append(frame([]byte("ok")), frame([]byte("too large"))...),
+		expGrpcResBody:       frame([]byte("reply")),
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testMessageSizeRequest(t, data)
+}
+
+func TestMessageSizeResponseTooLarge(t *testing.T) {
+	data := TestMessageSizeData{
+		cfgLimits: []http2grpc.MessageSizeLimitConfig{
+			{Methods: []string{"/pkg.v1.Service/List"}, MaxResponseMessageSize: 5},
+		},
+
+		reqPath: "/pkg.v1.Service/List",
+		reqBody: frame([]byte("ok")),
+
+		backendGrpcBody: append(frame([]byte("reply")), frame([]byte("large reply"))...),
+
+		expBackendReqBody:    frame([]byte("ok")),
+		expGrpcResBody:       frame([]byte("reply")),
+		expGrpcResStatusCode: 8,
+		expGrpcResStatusMsg:  "grpc: received message larger than max (11 vs. 5)",
+	}
+	testMessageSizeRequest(t, data)
+}
+
+func TestMessageSizeOtherMethodNotLimited(t *testing.T) {
+	data := TestMessageSizeData{
+		cfgLimits: []http2grpc.MessageSizeLimitConfig{
+			{Methods: []string{"/pkg.v1.Other/*"}, MaxRequestMessageSize: 1, MaxResponseMessageSize: 1},
+		},
+
+		reqPath: "/pkg.v1.Service/List",
+		reqBody: frame([]byte("ok")),
+
+		backendGrpcBody: frame([]byte("large reply")),
+
+		expBackendReqBody:    frame([]byte("ok")),
+		expGrpcResBody:       frame([]byte("large reply")),
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testMessageSizeRequest(t, data)
+}
+
+func testMessageSizeRequest(t *testing.T, data TestMessageSizeData) {
+	t.Helper()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.MessageSizeLimits.Limits = data.cfgLimits
+
+	var backendReqBody []byte
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var err error
+
+		backendReqBody, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			// like traefik proxy does when request body can not be sent to backend
+			rw.WriteHeader(http.StatusBadGateway)
+			rw.Write([]byte(http.StatusText(http.StatusBadGateway)))
+
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/grpc")
+		rw.Header().Set("Trailer", "grpc-status")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write(data.backendGrpcBody)
+		rw.Header().Set("grpc-status", "0")
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost"+data.reqPath, bytes.NewReader(data.reqBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+
+	if !bytes.Equal(backendReqBody, data.expBackendReqBody) {
+		t.Errorf("expected backend request body: `%v`, got: `%v`", data.expBackendReqBody, backendReqBody)
+	}
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertBody(t, resp, data.expGrpcResBody)
+	assertHeader(t, resp, "Content-Type", "application/grpc")
+	assertTrailer(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+	assertTrailer(t, resp, "grpc-message", data.expGrpcResStatusMsg)
+}
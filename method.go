@@ -0,0 +1,69 @@
+package http2grpc
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// grpcMethod returns full gRPC method name `/package.Service/Method`, it is the request path by gRPC spec,
+// or empty string if the path is not in this format, service and method parts must not be empty.
+func grpcMethod(req *http.Request) string {
+	method := req.URL.Path
+	if strings.Count(method, "/") != 2 || !strings.HasPrefix(method, "/") {
+		return ""
+	}
+
+	separator := strings.LastIndexByte(method, '/')
+	if separator == 1 || separator == len(method)-1 {
+		return ""
+	}
+
+	return method
+}
+
+// methodMatcher matches full gRPC method names by glob patterns like `/package.Service/*`,
+// leading slash in patterns is optional.
+type methodMatcher []string
+
+func newMethodMatcher(patterns []string) (methodMatcher, error) {
+	matcher := make(methodMatcher, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		if !strings.HasPrefix(pattern, "/") {
+			pattern = "/" + pattern
+		}
+
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("ERROR: http2grpc: method pattern %q: %w", pattern, err)
+		}
+
+		matcher = append(matcher, pattern)
+	}
+
+	return matcher, nil
+}
+
+// match is whether method matches any of patterns.
+func (m methodMatcher) match(method string) bool {
+	_, ok := m.matchPattern(method)
+
+	return ok
+}
+
+// matchPattern returns the first pattern matching method.
+func (m methodMatcher) matchPattern(method string) (string, bool) {
+	for _, pattern := range m {
+		if matched, _ := path.Match(pattern, method); matched {
+			return pattern, true
+		}
+	}
+
+	return "", false
+}
+
+// matchOrEmpty is whether method matches any of patterns or there are no patterns at all.
+func (m methodMatcher) matchOrEmpty(method string) bool {
+	return len(m) == 0 || m.match(method)
+}
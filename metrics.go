@@ -0,0 +1,150 @@
+package http2grpc
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	metricCounter = "counter"
+	metricGauge   = "gauge"
+
+	// ContentTypeHeaderMetricsValue is Prometheus text exposition format
+	ContentTypeHeaderMetricsValue = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// MetricsConfig describes exposition of metrics collected by middleware.
+type MetricsConfig struct {
+	// Path is request path answered by middleware with metrics in Prometheus text format, not exposed if empty
+	Path string `yaml:"path"`
+}
+
+type metricFamily struct {
+	kind string
+	help string
+	// series are values by rendered labels, like `method="/pkg.Service/Method"`
+	series map[string]float64
+}
+
+// metrics is registry of counters and gauges of one middleware instance,
+// traefik plugins have no access to traefik metrics, so they are exposed by middleware itself.
+type metrics struct {
+	mu       sync.Mutex
+	families map[string]*metricFamily
+}
+
+func newMetrics() *metrics {
+	return &metrics{families: make(map[string]*metricFamily)}
+}
+
+// register declares metric family, so it is exposed with help even before the first value.
+func (m *metrics) register(name string, kind string, help string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.families[name]; !ok {
+		m.families[name] = &metricFamily{kind: kind, help: help, series: make(map[string]float64)}
+	}
+}
+
+// add changes value of registered metric by delta, labels are name and value pairs.
+func (m *metrics) add(name string, delta float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	family, ok := m.families[name]
+	if !ok {
+		LoggerDEBUG.Printf("metrics: %s is not registered", name)
+		return
+	}
+
+	family.series[renderLabels(labels)] += delta
+}
+
+// value returns current value of metric, it is used to check gauges.
+func (m *metrics) value(name string, labels ...string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	family, ok := m.families[name]
+	if !ok {
+		return 0
+	}
+
+	return family.series[renderLabels(labels)]
+}
+
+// writeTo writes all metrics in Prometheus text format sorted by name and labels.
+func (m *metrics) writeTo(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var builder strings.Builder
+
+	for _, name := range names {
+		family := m.families[name]
+		fmt.Fprintf(&builder, "# HELP %s %s\n# TYPE %s %s\n", name, family.help, name, family.kind)
+
+		series := make([]string, 0, len(family.series))
+		for labels := range family.series {
+			series = append(series, labels)
+		}
+
+		sort.Strings(series)
+
+		for _, labels := range series {
+			value := strconv.FormatFloat(family.series[labels], 'g', -1, 64)
+			if labels == "" {
+				fmt.Fprintf(&builder, "%s %s\n", name, value)
+			} else {
+				fmt.Fprintf(&builder, "%s{%s} %s\n", name, labels, value)
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, builder.String())
+
+	return err
+}
+
+func (m *metrics) serveHTTP(rw http.ResponseWriter) {
+	rw.Header().Set(ContentTypeHeaderName, ContentTypeHeaderMetricsValue)
+	rw.WriteHeader(http.StatusOK)
+
+	if err := m.writeTo(rw); err != nil {
+		LoggerDEBUG.Printf("metrics: write failed: %s", err)
+	}
+}
+
+func renderLabels(labels []string) string {
+	var builder strings.Builder
+
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			builder.WriteByte(',')
+		}
+
+		builder.WriteString(labels[i])
+		builder.WriteString(`="`)
+		builder.WriteString(escapeLabelValue(labels[i+1]))
+		builder.WriteByte('"')
+	}
+
+	return builder.String()
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
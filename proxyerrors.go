@@ -0,0 +1,101 @@
+package http2grpc
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"github.com/v-electrolux/http2grpc/internal/pb"
+	"net/http"
+)
+
+const (
+	ProxyErrorReasonUnavailable = "BACKEND_UNAVAILABLE"
+	ProxyErrorReasonTimeout     = "BACKEND_TIMEOUT"
+)
+
+// ProxyErrorsConfig describes how to recognize errors generated by traefik itself,
+// e.g. when the gRPC backend is down, so they are not mixed with application (forward auth) errors.
+type ProxyErrorsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MarkerHeader is response header which marks proxy originated error regardless of the body,
+	// it is removed from response
+	MarkerHeader string `yaml:"markerHeader"`
+	// Domain is set to ErrorInfo domain of proxy originated errors
+	Domain string `yaml:"domain"`
+}
+
+// proxyErrorGrpcCodes is gRPC codes for proxy originated errors, different from HTTP2grpc spec map,
+// because timeout of the backend is a deadline for the client.
+//
+//nolint:gochecknoglobals // static map
+var proxyErrorGrpcCodes = map[int]int{
+	http.StatusBadGateway:         grpc.UNAVAILABLE,
+	http.StatusServiceUnavailable: grpc.UNAVAILABLE,
+	http.StatusGatewayTimeout:     grpc.DEADLINE_EXCEEDED,
+}
+
+// markProxyError checks marker header, must be called before headers are sent.
+func (h *http2grpcModifier) markProxyError(statusCode int) {
+	if h.proxyErrors == nil || h.proxyErrors.MarkerHeader == "" {
+		return
+	}
+
+	if _, ok := proxyErrorGrpcCodes[statusCode]; !ok {
+		return
+	}
+
+	if hasProxyErrorMarker(h.proxyErrors, h.responseWriter.Header()) {
+		h.proxyErrorMarked = true
+		h.responseWriter.Header().Del(h.proxyErrors.MarkerHeader)
+	}
+}
+
+// hasProxyErrorMarker is whether response header has marker header of proxy error.
+func hasProxyErrorMarker(config *ProxyErrorsConfig, header http.Header) bool {
+	if config.MarkerHeader == "" {
+		return false
+	}
+
+	_, ok := header[http.CanonicalHeaderKey(config.MarkerHeader)]
+
+	return ok
+}
+
+// finishProxyError replaces grpc status of proxy originated error,
+// which is recognized by marker header or by traefik body fingerprint.
+func (h *http2grpcModifier) finishProxyError() {
+	if h.proxyErrors == nil || h.backendUseGrpc {
+		return
+	}
+
+	grpcCode, ok := proxyErrorGrpcCodes[h.sentHTTPStatusCode]
+	if !ok {
+		return
+	}
+
+	if !h.proxyErrorMarked && (h.droppedBodyTruncated || !isTraefikErrorBody(h.sentHTTPStatusCode, h.droppedBody)) {
+		return
+	}
+
+	reason := ProxyErrorReasonUnavailable
+	if grpcCode == grpc.DEADLINE_EXCEEDED {
+		reason = ProxyErrorReasonTimeout
+	}
+
+	message := fmt.Sprintf("proxy: %d %s", h.sentHTTPStatusCode, http.StatusText(h.sentHTTPStatusCode))
+	LoggerDEBUG.Printf("finish() proxy originated error detected: %s", message)
+
+	setGrpcStatusTrailers(h.responseWriter.Header(), grpcCode, message, &pb.ErrorInfo{
+		Reason: reason,
+		Domain: h.proxyErrors.Domain,
+		Metadata: map[string]string{
+			"httpStatus": fmt.Sprint(h.sentHTTPStatusCode),
+		},
+	})
+}
+
+// isTraefikErrorBody is whether body is the one traefik writes on its own errors,
+// it is just status text, optionally with line feed, e.g. "Bad Gateway" or "Service Unavailable\n".
+func isTraefikErrorBody(statusCode int, body []byte) bool {
+	return string(bytes.TrimSuffix(body, []byte("\n"))) == http.StatusText(statusCode)
+}
@@ -0,0 +1,221 @@
+package http2grpc_test
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/v-electrolux/http2grpc"
+	"github.com/v-electrolux/http2grpc/internal/pb"
+)
+
+type TestProxyErrorData struct {
+	cfgBodyAsStatusMessage bool
+	cfgProxyErrorsEnabled  bool
+	cfgMarkerHeader        string
+
+	backendHTTPResStatusCode int
+	backendHTTPResHeaders    map[string]string
+	backendHTTPResBody       []byte
+
+	expGrpcResStatusCode int
+	expGrpcResStatusMsg  string
+	expErrorInfo         *pb.ErrorInfo
+}
+
+func TestTraefikBadGatewayIsProxyError(t *testing.T) {
+	data := TestProxyErrorData{
+		cfgBodyAsStatusMessage: true,
+		cfgProxyErrorsEnabled:  true,
+
+		backendHTTPResStatusCode: 502,
+		backendHTTPResBody:       []byte("Bad Gateway"),
+
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "proxy: 502 Bad Gateway",
+		expErrorInfo: &pb.ErrorInfo{
+			Reason:   "BACKEND_UNAVAILABLE",
+			Domain:   "traefik.io",
+			Metadata: map[string]string{"httpStatus": "502"},
+		},
+	}
+	testProxyErrorRequest(t, data)
+}
+
+func TestTraefikServiceUnavailableIsProxyError(t *testing.T) {
+	data := TestProxyErrorData{
+		cfgBodyAsStatusMessage: true,
+		cfgProxyErrorsEnabled:  true,
+
+		backendHTTPResStatusCode: 503,
+		backendHTTPResBody:       []byte("Service Unavailable\n"),
+
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "proxy: 503 Service Unavailable",
+		expErrorInfo: &pb.ErrorInfo{
+			Reason:   "BACKEND_UNAVAILABLE",
+			Domain:   "traefik.io",
+			Metadata: map[string]string{"httpStatus": "503"},
+		},
+	}
+	testProxyErrorRequest(t, data)
+}
+
+func TestTraefikGatewayTimeoutIsProxyError(t *testing.T) {
+	data := TestProxyErrorData{
+		cfgBodyAsStatusMessage: true,
+		cfgProxyErrorsEnabled:  true,
+
+		backendHTTPResStatusCode: 504,
+		backendHTTPResBody:       []byte("Gateway Timeout"),
+
+		expGrpcResStatusCode: 4,
+		expGrpcResStatusMsg:  "proxy: 504 Gateway Timeout",
+		expErrorInfo: &pb.ErrorInfo{
+			Reason:   "BACKEND_TIMEOUT",
+			Domain:   "traefik.io",
+			Metadata: map[string]string{"httpStatus": "504"},
+		},
+	}
+	testProxyErrorRequest(t, data)
+}
+
+func TestMarkedServiceUnavailableIsProxyError(t *testing.T) {
+	data := TestProxyErrorData{
+		cfgBodyAsStatusMessage: true,
+		cfgProxyErrorsEnabled:  true,
+		cfgMarkerHeader:        "X-Proxy-Error",
+
+		backendHTTPResStatusCode: 503,
+		backendHTTPResHeaders:    map[string]string{"X-Proxy-Error": "1"},
+		backendHTTPResBody:       []byte("<html>maintenance</html>"),
+
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "proxy: 503 Service Unavailable",
+		expErrorInfo: &pb.ErrorInfo{
+			Reason:   "BACKEND_UNAVAILABLE",
+			Domain:   "traefik.io",
+			Metadata: map[string]string{"httpStatus": "503"},
+		},
+	}
+	testProxyErrorRequest(t, data)
+}
+
+func TestAuthServiceUnavailableIsNotProxyError(t *testing.T) {
+	data := TestProxyErrorData{
+		cfgBodyAsStatusMessage: true,
+		cfgProxyErrorsEnabled:  true,
+
+		backendHTTPResStatusCode: 503,
+		backendHTTPResBody:       []byte("auth storage is down"),
+
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "auth storage is down",
+		expErrorInfo:         nil,
+	}
+	testProxyErrorRequest(t, data)
+}
+
+func TestTraefikBadGatewayWithDisabledProxyErrors(t *testing.T) {
+	data := TestProxyErrorData{
+		cfgBodyAsStatusMessage: true,
+		cfgProxyErrorsEnabled:  false,
+
+		backendHTTPResStatusCode: 504,
+		backendHTTPResBody:       []byte("Gateway Timeout"),
+
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "Gateway Timeout",
+		expErrorInfo:         nil,
+	}
+	testProxyErrorRequest(t, data)
+}
+
+func TestBadGatewayWithLongerBodyIsNotProxyError(t *testing.T) {
+	data := TestProxyErrorData{
+		cfgBodyAsStatusMessage: false,
+		cfgProxyErrorsEnabled:  true,
+
+		backendHTTPResStatusCode: 502,
+		backendHTTPResBody:       []byte("Bad Gateway\nupstream connect error"),
+
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "",
+		expErrorInfo:         nil,
+	}
+	testProxyErrorRequest(t, data)
+}
+
+func TestTraefikBadGatewayWithDisabledBodyAsMsgIsProxyError(t *testing.T) {
+	data := TestProxyErrorData{
+		cfgBodyAsStatusMessage: false,
+		cfgProxyErrorsEnabled:  true,
+
+		backendHTTPResStatusCode: 502,
+		backendHTTPResBody:       []byte("Bad Gateway\n"),
+
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "proxy: 502 Bad Gateway",
+		expErrorInfo: &pb.ErrorInfo{
+			Reason:   "BACKEND_UNAVAILABLE",
+			Domain:   "traefik.io",
+			Metadata: map[string]string{"httpStatus": "502"},
+		},
+	}
+	testProxyErrorRequest(t, data)
+}
+
+func testProxyErrorRequest(t *testing.T, data TestProxyErrorData) {
+	t.Helper()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.BodyAsStatusMessage = data.cfgBodyAsStatusMessage
+	cfg.ProxyErrors.Enabled = data.cfgProxyErrorsEnabled
+	cfg.ProxyErrors.MarkerHeader = data.cfgMarkerHeader
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		for key, value := range data.backendHTTPResHeaders {
+			rw.Header().Set(key, value)
+		}
+
+		rw.WriteHeader(data.backendHTTPResStatusCode)
+		rw.Write(data.backendHTTPResBody)
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertBody(t, resp, []byte{0x00, 0x00, 0x00, 0x00, 0x00})
+	assertHeader(t, resp, "Content-Type", "application/grpc")
+	assertHeader(t, resp, data.cfgMarkerHeader, "")
+	assertTrailer(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+	assertTrailer(t, resp, "grpc-message", data.expGrpcResStatusMsg)
+
+	expDetails := ""
+	if data.expErrorInfo != nil {
+		status := &pb.Status{
+			Code:    int32(data.expGrpcResStatusCode),
+			Message: data.expGrpcResStatusMsg,
+			Details: []*pb.Any{pb.NewAny(data.expErrorInfo)},
+		}
+		expDetails = base64.RawStdEncoding.EncodeToString(status.Marshal())
+	}
+	assertTrailer(t, resp, "grpc-status-details-bin", expDetails)
+}
@@ -0,0 +1,235 @@
+package http2grpc
+
+import (
+	"fmt"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"github.com/v-electrolux/http2grpc/internal/pb"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	metricRateLimitedCalls = "http2grpc_rate_limited_calls_total"
+
+	// maxRateLimitBuckets limits memory of buckets of every limit, refilled buckets are evicted above it,
+	// and calls of keys not fitting it share one overflow bucket
+	maxRateLimitBuckets = 10000
+	// rateLimitEvictInterval is min interval between scans for refilled buckets of full limit
+	rateLimitEvictInterval = time.Second
+	// rateLimitRuleAll labels limited calls of limit without method patterns
+	rateLimitRuleAll = "other"
+)
+
+// RateLimitRuleConfig describes token bucket limit of gRPC calls.
+type RateLimitRuleConfig struct {
+	// Methods are glob patterns of gRPC methods, all methods if empty,
+	// every method has its own bucket
+	Methods []string `yaml:"methods"`
+	// Average is number of calls allowed per period
+	Average int `yaml:"average"`
+	// Period of average as go duration string, 1s if empty
+	Period string `yaml:"period"`
+	// Burst is max number of calls allowed at once, equal to average if 0
+	Burst int `yaml:"burst"`
+	// KeyHeader is request header (gRPC metadata), like `x-api-key`, every value of it has its own bucket
+	KeyHeader string `yaml:"keyHeader"`
+}
+
+// RateLimitConfig describes rate limiting of gRPC calls answered with RESOURCE_EXHAUSTED without calling backend.
+type RateLimitConfig struct {
+	// Limits are checked in order, the first one matching method is applied
+	Limits []RateLimitRuleConfig `yaml:"limits"`
+	// Message is gRPC status message of limited call
+	Message string `yaml:"message"`
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimitRule struct {
+	config  *RateLimitRuleConfig
+	methods methodMatcher
+	// rate is tokens added per second
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	overflow  *tokenBucket
+	lastEvict time.Time
+}
+
+type rateLimiter struct {
+	config  *RateLimitConfig
+	rules   []*rateLimitRule
+	metrics *metrics
+}
+
+// newRateLimiter returns nil if there are no limits.
+func newRateLimiter(config *RateLimitConfig, m *metrics) (*rateLimiter, error) {
+	if len(config.Limits) == 0 {
+		return nil, nil //nolint:nilnil // disabled feature
+	}
+
+	limiter := &rateLimiter{
+		config:  config,
+		rules:   make([]*rateLimitRule, 0, len(config.Limits)),
+		metrics: m,
+	}
+
+	for i := range config.Limits {
+		rule, err := newRateLimitRule(&config.Limits[i])
+		if err != nil {
+			return nil, fmt.Errorf("ERROR: http2grpc: rateLimit.limits[%d]: %w", i, err)
+		}
+
+		limiter.rules = append(limiter.rules, rule)
+	}
+
+	m.register(metricRateLimitedCalls, metricCounter, "gRPC calls rejected by rate limit.")
+
+	return limiter, nil
+}
+
+func newRateLimitRule(config *RateLimitRuleConfig) (*rateLimitRule, error) {
+	methods, err := newMethodMatcher(config.Methods)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Average <= 0 {
+		return nil, fmt.Errorf("average must be positive, got %d", config.Average)
+	}
+
+	if config.Burst < 0 {
+		return nil, fmt.Errorf("negative burst %d", config.Burst)
+	}
+
+	period := time.Second
+	if config.Period != "" {
+		if period, err = time.ParseDuration(config.Period); err != nil {
+			return nil, fmt.Errorf("period: %w", err)
+		}
+
+		if period <= 0 {
+			return nil, fmt.Errorf("period must be positive, got %s", period)
+		}
+	}
+
+	burst := config.Burst
+	if burst == 0 {
+		burst = config.Average
+	}
+
+	return &rateLimitRule{
+		config:   config,
+		methods:  methods,
+		rate:     float64(config.Average) / period.Seconds(),
+		burst:    float64(burst),
+		buckets:  make(map[string]*tokenBucket),
+		overflow: &tokenBucket{tokens: float64(burst), last: time.Now()},
+	}, nil
+}
+
+// intercept rejects call if bucket of the first matching limit is empty.
+func (l *rateLimiter) intercept(rw *http2grpcModifier, req *http.Request) bool {
+	method := grpcMethod(req)
+
+	for _, rule := range l.rules {
+		if !rule.methods.matchOrEmpty(method) {
+			continue
+		}
+
+		key := method
+		if rule.config.KeyHeader != "" {
+			key += "\x00" + req.Header.Get(rule.config.KeyHeader)
+		}
+
+		retryDelay, allowed := rule.take(key, time.Now())
+		if allowed {
+			return false
+		}
+
+		pattern, ok := rule.methods.matchPattern(method)
+		if !ok {
+			pattern = rateLimitRuleAll
+		}
+
+		LoggerINFO.Printf("rate limit: method %q exceeded %d per %s", method, rule.config.Average, rule.period())
+		l.metrics.add(metricRateLimitedCalls, 1, "rule", pattern)
+
+		subject := "method:" + method
+		if rule.config.KeyHeader != "" {
+			subject += " " + rule.config.KeyHeader + ":" + req.Header.Get(rule.config.KeyHeader)
+		}
+
+		quotaFailure := &pb.QuotaFailure{Violations: []*pb.QuotaViolation{{
+			Subject:     subject,
+			Description: fmt.Sprintf("limit of %d calls per %s exceeded", rule.config.Average, rule.period()),
+		}}}
+		retryInfo := &pb.RetryInfo{RetryDelay: pb.NewDuration(retryDelay)}
+
+		rw.writeGrpcStatus(grpc.RESOURCE_EXHAUSTED, l.config.Message, quotaFailure, retryInfo)
+		grpc.SetTrailer(rw.Header(), GrpcRetryPushbackHeaderName, strconv.FormatInt(retryDelay.Milliseconds(), 10))
+
+		return true
+	}
+
+	return false
+}
+
+// take removes one token from bucket of key, or returns delay until the token is available.
+func (r *rateLimitRule) take(key string, now time.Time) (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bucket, ok := r.buckets[key]
+	if !ok {
+		if len(r.buckets) >= maxRateLimitBuckets && now.Sub(r.lastEvict) >= rateLimitEvictInterval {
+			r.lastEvict = now
+			r.evictFull(now)
+		}
+
+		if len(r.buckets) < maxRateLimitBuckets {
+			bucket = &tokenBucket{tokens: r.burst, last: now}
+			r.buckets[key] = bucket
+		} else {
+			bucket = r.overflow
+		}
+	}
+
+	bucket.tokens = math.Min(r.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*r.rate)
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return 0, true
+	}
+
+	// rounded up to milliseconds of grpc-retry-pushback-ms, so retry after it surely succeeds
+	delayMs := math.Ceil((1 - bucket.tokens) / r.rate * float64(time.Second/time.Millisecond))
+
+	return time.Duration(delayMs) * time.Millisecond, false
+}
+
+// evictFull removes buckets refilled up to burst, they are equal to new ones.
+func (r *rateLimitRule) evictFull(now time.Time) {
+	for key, bucket := range r.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*r.rate >= r.burst {
+			delete(r.buckets, key)
+		}
+	}
+}
+
+func (r *rateLimitRule) period() string {
+	if r.config.Period == "" {
+		return "1s"
+	}
+
+	return r.config.Period
+}
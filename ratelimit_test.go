@@ -0,0 +1,209 @@
+package http2grpc_test
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/v-electrolux/http2grpc"
+	"github.com/v-electrolux/http2grpc/internal/pb"
+)
+
+type TestRateLimitData struct {
+	cfgLimits []http2grpc.RateLimitRuleConfig
+
+	reqPath    string
+	reqHeaders []map[string]string
+
+	expBackendCalls  int
+	expLimitedSubj   string
+	expLimitedDesc   string
+	expMaxPushbackMs int64
+	expMetrics       []string
+}
+
+func TestRateLimitExceeded(t *testing.T) {
+	data := TestRateLimitData{
+		cfgLimits: []http2grpc.RateLimitRuleConfig{
+			{Methods: []string{"/pkg.v1.Service/*"}, Average: 2, Period: "1m"},
+		},
+
+		reqPath:    "/pkg.v1.Service/Get",
+		reqHeaders: []map[string]string{nil, nil, nil},
+
+		expBackendCalls:  2,
+		expLimitedSubj:   "method:/pkg.v1.Service/Get",
+		expLimitedDesc:   "limit of 2 calls per 1m exceeded",
+		expMaxPushbackMs: 30000,
+		expMetrics: []string{
+			`http2grpc_rate_limited_calls_total{rule="/pkg.v1.Service/*"} 1`,
+		},
+	}
+	testRateLimitRequest(t, data)
+}
+
+func TestRateLimitByKeyHeader(t *testing.T) {
+	data := TestRateLimitData{
+		cfgLimits: []http2grpc.RateLimitRuleConfig{
+			{Average: 1, Period: "1h", KeyHeader: "x-api-key"},
+		},
+
+		reqPath: "/pkg.v1.Service/Get",
+		reqHeaders: []map[string]string{
+			{"x-api-key": "first"},
+			{"x-api-key": "second"},
+			{"x-api-key": "first"},
+		},
+
+		expBackendCalls:  2,
+		expLimitedSubj:   "method:/pkg.v1.Service/Get x-api-key:first",
+		expLimitedDesc:   "limit of 1 calls per 1h exceeded",
+		expMaxPushbackMs: 3600000,
+	}
+	testRateLimitRequest(t, data)
+}
+
+func TestRateLimitKeysAboveMaxShareBucket(t *testing.T) {
+	reqHeaders := make([]map[string]string, 0, 10002)
+	for i := 0; i < 10002; i++ {
+		reqHeaders = append(reqHeaders, map[string]string{"x-api-key": "key" + strconv.Itoa(i)})
+	}
+
+	data := TestRateLimitData{
+		cfgLimits: []http2grpc.RateLimitRuleConfig{
+			{Average: 1, Period: "1h", KeyHeader: "x-api-key"},
+		},
+
+		reqPath:    "/pkg.v1.Service/Get",
+		reqHeaders: reqHeaders,
+
+		expBackendCalls:  10001,
+		expLimitedSubj:   "method:/pkg.v1.Service/Get x-api-key:key10001",
+		expLimitedDesc:   "limit of 1 calls per 1h exceeded",
+		expMaxPushbackMs: 3600000,
+		expMetrics: []string{
+			`http2grpc_rate_limited_calls_total{rule="other"} 1`,
+		},
+	}
+	testRateLimitRequest(t, data)
+}
+
+func TestRateLimitBurst(t *testing.T) {
+	data := TestRateLimitData{
+		cfgLimits: []http2grpc.RateLimitRuleConfig{
+			{Average: 1, Period: "1h", Burst: 3},
+		},
+
+		reqPath:    "/pkg.v1.Service/Get",
+		reqHeaders: []map[string]string{nil, nil, nil},
+
+		expBackendCalls: 3,
+	}
+	testRateLimitRequest(t, data)
+}
+
+func TestRateLimitOtherMethodPassed(t *testing.T) {
+	data := TestRateLimitData{
+		cfgLimits: []http2grpc.RateLimitRuleConfig{
+			{Methods: []string{"/pkg.v1.Other/*"}, Average: 1, Period: "1h"},
+		},
+
+		reqPath:    "/pkg.v1.Service/Get",
+		reqHeaders: []map[string]string{nil, nil},
+
+		expBackendCalls: 2,
+	}
+	testRateLimitRequest(t, data)
+}
+
+func TestRateLimitInvalidAverage(t *testing.T) {
+	cfg := http2grpc.CreateConfig()
+	cfg.RateLimit.Limits = []http2grpc.RateLimitRuleConfig{{Average: 0}}
+
+	_, err := http2grpc.New(context.Background(), http.NotFoundHandler(), cfg, "http2grpc")
+	if err == nil {
+		t.Errorf("expected error for zero average")
+	}
+}
+
+func testRateLimitRequest(t *testing.T, data TestRateLimitData) {
+	t.Helper()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.RateLimit.Limits = data.cfgLimits
+	cfg.Metrics.Path = "/metrics"
+
+	backendCalls := 0
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		backendCalls++
+
+		rw.Header().Set("Content-Type", "application/grpc")
+		rw.Header().Set("Trailer", "grpc-status")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte{0x00, 0x00, 0x00, 0x00, 0x00})
+		rw.Header().Set("grpc-status", "0")
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resp *http.Response
+
+	for _, headers := range data.reqHeaders {
+		recorder := httptest.NewRecorder()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost"+data.reqPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		handler.ServeHTTP(recorder, req)
+		resp = recorder.Result()
+	}
+
+	if backendCalls != data.expBackendCalls {
+		t.Errorf("expected backend calls: `%d`, got: `%d`", data.expBackendCalls, backendCalls)
+	}
+
+	assertMetrics(t, handler, data.expMetrics)
+
+	if data.expLimitedSubj == "" {
+		assertTrailer(t, resp, "grpc-status", "0")
+		return
+	}
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertBody(t, resp, []byte{0x00, 0x00, 0x00, 0x00, 0x00})
+	assertTrailer(t, resp, "grpc-status", "8")
+	assertTrailer(t, resp, "grpc-message", "rate limit exceeded")
+
+	pushbackMs, err := strconv.ParseInt(resp.Trailer.Get("grpc-retry-pushback-ms"), 10, 64)
+	if err != nil || pushbackMs <= 0 || pushbackMs > data.expMaxPushbackMs {
+		t.Errorf("expected trailer `grpc-retry-pushback-ms` in (0, %d], got: `%s`",
+			data.expMaxPushbackMs, resp.Trailer.Get("grpc-retry-pushback-ms"))
+	}
+
+	status := &pb.Status{
+		Code:    8,
+		Message: "rate limit exceeded",
+		Details: []*pb.Any{
+			pb.NewAny(&pb.QuotaFailure{Violations: []*pb.QuotaViolation{{
+				Subject:     data.expLimitedSubj,
+				Description: data.expLimitedDesc,
+			}}}),
+			pb.NewAny(&pb.RetryInfo{RetryDelay: pb.NewDuration(time.Duration(pushbackMs) * time.Millisecond)}),
+		},
+	}
+	assertTrailer(t, resp, "grpc-status-details-bin", base64.RawStdEncoding.EncodeToString(status.Marshal()))
+}
@@ -0,0 +1,437 @@
+package http2grpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	GrpcPreviousRPCAttemptsHeaderName = "grpc-previous-rpc-attempts"
+
+	// maxRetryAttempts is the limit of maxAttempts from gRPC retry design, larger values are treated as it
+	maxRetryAttempts = 5
+)
+
+// RetryPolicyConfig describes retries of idempotent unary gRPC methods like retryPolicy of gRPC service config.
+type RetryPolicyConfig struct {
+	// Methods are glob patterns of idempotent unary gRPC methods
+	Methods []string `yaml:"methods"`
+	// MaxAttempts is max number of attempts including the original one, from 2 to 5
+	MaxAttempts int `yaml:"maxAttempts"`
+	// InitialBackoff is backoff before the first retry as go duration string
+	InitialBackoff string `yaml:"initialBackoff"`
+	// MaxBackoff limits backoff growth as go duration string
+	MaxBackoff string `yaml:"maxBackoff"`
+	// BackoffMultiplier is backoff growth after every attempt
+	BackoffMultiplier float64 `yaml:"backoffMultiplier"`
+	// RetryableStatusCodes are names or numbers of gRPC status codes retried, UNAVAILABLE if empty
+	RetryableStatusCodes []string `yaml:"retryableStatusCodes"`
+}
+
+// RetryConfig describes retries of backend calls made by middleware.
+type RetryConfig struct {
+	// Policies are checked in order, the first one matching method is applied
+	Policies []RetryPolicyConfig `yaml:"policies"`
+	// MaxRequestSize is max size of request body buffered for retries, larger requests are not retried
+	MaxRequestSize int `yaml:"maxRequestSize"`
+	// MaxResponseSize is max size of error response buffered until retry decision, larger responses are not retried
+	MaxResponseSize int `yaml:"maxResponseSize"`
+}
+
+type retryPolicy struct {
+	config         *RetryPolicyConfig
+	methods        methodMatcher
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	retryableCodes map[int]bool
+}
+
+type retrier struct {
+	config   *RetryConfig
+	policies []retryPolicy
+	// mapStatus maps HTTP status code of failed attempt like conversion does, DefaultStatusMapper by default
+	mapStatus StatusMapper
+	// proxyErrors recognizes proxy originated errors like conversion does, nil if disabled
+	proxyErrors *ProxyErrorsConfig
+
+	// random is not safe for concurrent use, so it is guarded by mutex
+	randomMu sync.Mutex
+	random   *rand.Rand
+}
+
+// newRetrier returns nil if there are no retry policies.
+func newRetrier(config *RetryConfig) (*retrier, error) {
+	if len(config.Policies) == 0 {
+		return nil, nil //nolint:nilnil // disabled feature
+	}
+
+	if config.MaxRequestSize <= 0 || config.MaxResponseSize <= 0 {
+		return nil, fmt.Errorf("ERROR: http2grpc: retry.maxRequestSize and retry.maxResponseSize must be positive")
+	}
+
+	r := &retrier{
+		config:    config,
+		policies:  make([]retryPolicy, 0, len(config.Policies)),
+		mapStatus: DefaultStatusMapper,
+		random:    rand.New(rand.NewSource(time.Now().UnixNano())), //nolint:gosec // not for security
+	}
+
+	for i := range config.Policies {
+		policy, err := newRetryPolicy(&config.Policies[i])
+		if err != nil {
+			return nil, fmt.Errorf("ERROR: http2grpc: retry.policies[%d]: %w", i, err)
+		}
+
+		r.policies = append(r.policies, policy)
+	}
+
+	return r, nil
+}
+
+func newRetryPolicy(config *RetryPolicyConfig) (retryPolicy, error) {
+	methods, err := newMethodMatcher(config.Methods)
+	if err != nil {
+		return retryPolicy{}, err
+	}
+
+	if config.MaxAttempts < 2 {
+		return retryPolicy{}, fmt.Errorf("maxAttempts must be at least 2, got %d", config.MaxAttempts)
+	}
+
+	policy := retryPolicy{
+		config:         config,
+		methods:        methods,
+		maxAttempts:    config.MaxAttempts,
+		retryableCodes: make(map[int]bool),
+	}
+
+	if policy.maxAttempts > maxRetryAttempts {
+		policy.maxAttempts = maxRetryAttempts
+	}
+
+	if policy.initialBackoff, err = time.ParseDuration(config.InitialBackoff); err != nil || policy.initialBackoff <= 0 {
+		return retryPolicy{}, fmt.Errorf("initialBackoff must be positive duration, got %q", config.InitialBackoff)
+	}
+
+	if policy.maxBackoff, err = time.ParseDuration(config.MaxBackoff); err != nil || policy.maxBackoff <= 0 {
+		return retryPolicy{}, fmt.Errorf("maxBackoff must be positive duration, got %q", config.MaxBackoff)
+	}
+
+	if config.BackoffMultiplier <= 0 {
+		return retryPolicy{}, fmt.Errorf("backoffMultiplier must be positive, got %g", config.BackoffMultiplier)
+	}
+
+	codes := config.RetryableStatusCodes
+	if len(codes) == 0 {
+		codes = []string{"UNAVAILABLE"}
+	}
+
+	for _, name := range codes {
+		code, err := parseRetryableCode(name)
+		if err != nil {
+			return retryPolicy{}, err
+		}
+
+		policy.retryableCodes[code] = true
+	}
+
+	return policy, nil
+}
+
+func parseRetryableCode(name string) (int, error) {
+	code, err := grpc.ParseCode(name)
+	if err != nil || code == grpc.OK {
+		return 0, fmt.Errorf("retryableStatusCodes: %q is not gRPC error code", name)
+	}
+
+	return int(code), nil
+}
+
+func (r *retrier) policy(method string) *retryPolicy {
+	for i := range r.policies {
+		if r.policies[i].methods.match(method) {
+			return &r.policies[i]
+		}
+	}
+
+	return nil
+}
+
+// serveHTTP calls backend and retries the call while it fails with retryable status,
+// response of the failed attempt is held back until retry decision and never reaches the client.
+func (r *retrier) serveHTTP(next http.Handler, rw *http2grpcModifier, req *http.Request) {
+	method := grpcMethod(req)
+
+	policy := r.policy(method)
+	if policy == nil {
+		next.ServeHTTP(rw, req)
+		return
+	}
+
+	body, complete, err := bufferRequestBody(req, r.config.MaxRequestSize)
+	if err != nil {
+		rw.writeGrpcStatus(grpc.INTERNAL, fmt.Sprintf("retry: request read failed: %s", err))
+		return
+	}
+
+	if !complete {
+		LoggerDEBUG.Printf("retry: %s request is larger than %d, not retried", method, r.config.MaxRequestSize)
+		next.ServeHTTP(rw, req)
+
+		return
+	}
+
+	initialHeader := rw.Header().Clone()
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req.Clone(req.Context())
+		attemptReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+		attemptReq.ContentLength = int64(len(body))
+
+		if attempt > 1 {
+			attemptReq.Header.Set(GrpcPreviousRPCAttemptsHeaderName, strconv.Itoa(attempt-1))
+
+			// every attempt has only the rest of the call deadline
+			if deadline, ok := req.Context().Deadline(); ok {
+				attemptReq.Header.Set(GrpcTimeoutHeaderName, formatGrpcTimeout(time.Until(deadline)))
+			}
+		}
+
+		recorder := newRetryRecorder(rw, r)
+		next.ServeHTTP(recorder, attemptReq)
+
+		if recorder.committed || attempt >= policy.maxAttempts {
+			recorder.commit()
+			return
+		}
+
+		delay, retry := r.retryDelay(policy, recorder.grpcCode(), recorder.Header(), attempt)
+		if !retry {
+			recorder.commit()
+			return
+		}
+
+		if deadline, ok := req.Context().Deadline(); ok && time.Until(deadline) <= delay {
+			LoggerDEBUG.Printf("retry: %s deadline is exceeded before attempt %d, not retried", method, attempt+1)
+			recorder.commit()
+
+			return
+		}
+
+		LoggerINFO.Printf("retry: %s attempt %d failed with code %d, retrying in %s",
+			method, attempt, recorder.grpcCode(), delay)
+
+		if !waitRetry(req.Context(), delay) || req.Context().Err() != nil {
+			recorder.commit()
+			return
+		}
+
+		resetHeader(rw.Header(), initialHeader)
+	}
+}
+
+// httpCode returns gRPC status code, which HTTP response of failed attempt is converted to by status mapper
+// and by proxy errors recognition, changes of OnConvert hook and validation errors are not known before conversion.
+func (r *retrier) httpCode(statusCode int, header http.Header, body []byte) int {
+	if r.proxyErrors != nil {
+		code, ok := proxyErrorGrpcCodes[statusCode]
+		if ok && (hasProxyErrorMarker(r.proxyErrors, header) || isTraefikErrorBody(statusCode, body)) {
+			return code
+		}
+	}
+
+	return r.mapStatus(statusCode)
+}
+
+// retryDelay returns backoff before the next attempt, which failed with code and header,
+// or false if the attempt must not be retried.
+func (r *retrier) retryDelay(policy *retryPolicy, code int, header http.Header, attempt int) (time.Duration, bool) {
+	if !policy.retryableCodes[code] {
+		return 0, false
+	}
+
+	// server pushback overrides backoff, negative or invalid one means do not retry by gRPC retry design
+	if pushback := grpc.Trailer(header, GrpcRetryPushbackHeaderName); pushback != "" {
+		ms, err := strconv.Atoi(pushback)
+		if err != nil || ms < 0 {
+			return 0, false
+		}
+
+		return time.Duration(ms) * time.Millisecond, true
+	}
+
+	backoff := float64(policy.initialBackoff) * math.Pow(policy.config.BackoffMultiplier, float64(attempt-1))
+	backoff = math.Min(backoff, float64(policy.maxBackoff))
+
+	r.randomMu.Lock()
+	defer r.randomMu.Unlock()
+
+	return time.Duration(r.random.Float64() * backoff), true
+}
+
+// waitRetry waits for delay before the next attempt, false if context is done earlier.
+func waitRetry(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// bufferRequestBody reads request body up to limit, if it is larger the rest is left unread
+// and request body is replaced to read the buffered part first.
+func bufferRequestBody(req *http.Request, limit int) ([]byte, bool, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, true, nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, int64(limit)+1))
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(body) <= limit {
+		return body, true, nil
+	}
+
+	req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+
+	return nil, false, nil
+}
+
+// resetHeader restores header to the state before the failed attempt.
+func resetHeader(header http.Header, initial http.Header) {
+	for name := range header {
+		delete(header, name)
+	}
+
+	for name, values := range initial {
+		header[name] = values
+	}
+}
+
+// retryRecorder holds back response of attempt until it is known whether the attempt is retried.
+// Response is committed to the client as soon as it has a message or error body larger than limit,
+// then the attempt is not retried, as gRPC clients do not retry calls after response messages are received.
+type retryRecorder struct {
+	rw          *http2grpcModifier
+	retrier     *retrier
+	maxBodySize int
+	statusCode  int
+	wroteHeader bool
+	body        []byte
+	committed   bool
+}
+
+func newRetryRecorder(rw *http2grpcModifier, retrier *retrier) *retryRecorder {
+	return &retryRecorder{rw: rw, retrier: retrier, maxBodySize: retrier.config.MaxResponseSize, statusCode: http.StatusOK}
+}
+
+// Header returns header of the client response, it is not sent until commit,
+// so it is restored before the next attempt.
+func (r *retryRecorder) Header() http.Header {
+	return r.rw.Header()
+}
+
+func (r *retryRecorder) WriteHeader(statusCode int) {
+	if r.wroteHeader {
+		return
+	}
+
+	r.statusCode = statusCode
+	r.wroteHeader = true
+}
+
+func (r *retryRecorder) Write(buf []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+
+	if !r.committed && ((r.isGrpcMessage() && len(buf) > 0) || len(r.body)+len(buf) > r.maxBodySize) {
+		r.commit()
+	}
+
+	if r.committed {
+		return r.rw.Write(buf)
+	}
+
+	r.body = append(r.body, buf...)
+
+	return len(buf), nil
+}
+
+func (r *retryRecorder) Flush() {
+	if r.committed {
+		r.rw.Flush()
+	}
+}
+
+// commit sends held back response to the client, header and trailers are already in the client response header.
+func (r *retryRecorder) commit() {
+	if r.committed {
+		return
+	}
+
+	r.committed = true
+
+	if !r.wroteHeader {
+		return
+	}
+
+	r.rw.WriteHeader(r.statusCode)
+
+	if len(r.body) > 0 {
+		if _, err := r.rw.Write(r.body); err != nil {
+			LoggerDEBUG.Printf("retry: response write failed: %s", err)
+		}
+	}
+}
+
+// isGrpcMessage is whether body is stream of gRPC messages.
+func (r *retryRecorder) isGrpcMessage() bool {
+	return r.statusCode == http.StatusOK && r.isGrpc()
+}
+
+func (r *retryRecorder) isGrpc() bool {
+	contentType := r.Header().Get(ContentTypeHeaderName)
+
+	return contentType == ContentTypeHeaderGrpcValue || contentType == ContentTypeHeaderGrpcWithBodyValue
+}
+
+// grpcCode returns gRPC status code of completed attempt, HTTP response code is the one retrier.httpCode returns.
+func (r *retryRecorder) grpcCode() int {
+	if !r.wroteHeader {
+		return grpc.OK
+	}
+
+	if !r.isGrpc() {
+		return r.retrier.httpCode(r.statusCode, r.Header(), r.body)
+	}
+
+	if !grpc.HasTrailer(r.Header(), GrpcStatusHeaderName) {
+		return r.retrier.mapStatus(r.statusCode)
+	}
+
+	code, err := strconv.Atoi(grpc.Trailer(r.Header(), GrpcStatusHeaderName))
+	if err != nil {
+		return grpc.UNKNOWN
+	}
+
+	return code
+}
@@ -0,0 +1,406 @@
+package http2grpc_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/v-electrolux/http2grpc"
+)
+
+type TestRetryAttempt struct {
+	httpStatusCode int
+	// grpcStatusCode is sent in trailers-only gRPC response if httpStatusCode is 0
+	grpcStatusCode int
+	grpcMessage    []byte
+	retryPushback  string
+}
+
+type TestRetryData struct {
+	cfgRetryableCodes []string
+	cfgMaxRequestSize int
+	cfgProxyErrors    bool
+
+	reqPath     string
+	reqBody     []byte
+	reqDeadline time.Duration
+
+	backendAttempts []TestRetryAttempt
+
+	expBackendCalls     int
+	expPreviousAttempts []string
+	expGrpcResBody      []byte
+	// expTrailersOnly is whether status is sent in headers of trailers-only response
+	expTrailersOnly      bool
+	expGrpcResStatusCode int
+	expGrpcResStatusMsg  string
+}
+
+func TestRetryHTTPUnavailableThenOk(t *testing.T) {
+	data := TestRetryData{
+		reqPath: "/pkg.v1.Service/Get",
+		reqBody: []byte{0x00, 0x00, 0x00, 0x00, 0x02, 0x08, 0x01},
+
+		backendAttempts: []TestRetryAttempt{
+			{httpStatusCode: 503},
+			{grpcStatusCode: 14, retryPushback: "1"},
+			{grpcStatusCode: 0, grpcMessage: []byte{0x08, 0x02}},
+		},
+
+		expBackendCalls:      3,
+		expPreviousAttempts:  []string{"", "1", "2"},
+		expGrpcResBody:       []byte{0x00, 0x00, 0x00, 0x00, 0x02, 0x08, 0x02},
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testRetryRequest(t, data)
+}
+
+func TestRetryTimeoutOfRestDeadline(t *testing.T) {
+	data := TestRetryData{
+		reqPath:     "/pkg.v1.Service/Get",
+		reqDeadline: 10 * time.Second,
+
+		backendAttempts: []TestRetryAttempt{
+			{grpcStatusCode: 14},
+			{grpcStatusCode: 0, grpcMessage: []byte{0x08, 0x02}},
+		},
+
+		expBackendCalls:      2,
+		expPreviousAttempts:  []string{"", "1"},
+		expGrpcResBody:       []byte{0x00, 0x00, 0x00, 0x00, 0x02, 0x08, 0x02},
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testRetryRequest(t, data)
+}
+
+func TestRetryDeadlineExceededBeforeAttempt(t *testing.T) {
+	data := TestRetryData{
+		reqPath:     "/pkg.v1.Service/Get",
+		reqDeadline: 50 * time.Millisecond,
+
+		backendAttempts: []TestRetryAttempt{
+			{grpcStatusCode: 14, retryPushback: "100"},
+			{grpcStatusCode: 0, grpcMessage: []byte{0x08, 0x02}},
+		},
+
+		expBackendCalls:      1,
+		expPreviousAttempts:  []string{""},
+		expGrpcResBody:       []byte{},
+		expTrailersOnly:      true,
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "attempt 1",
+	}
+	testRetryRequest(t, data)
+}
+
+func TestRetryAttemptsExhausted(t *testing.T) {
+	data := TestRetryData{
+		reqPath: "/pkg.v1.Service/Get",
+
+		backendAttempts: []TestRetryAttempt{
+			{grpcStatusCode: 14},
+			{grpcStatusCode: 14},
+			{grpcStatusCode: 14},
+			{grpcStatusCode: 0},
+		},
+
+		expBackendCalls:      3,
+		expPreviousAttempts:  []string{"", "1", "2"},
+		expGrpcResBody:       []byte{},
+		expTrailersOnly:      true,
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "attempt 3",
+	}
+	testRetryRequest(t, data)
+}
+
+func TestRetryNotRetryableCode(t *testing.T) {
+	data := TestRetryData{
+		reqPath: "/pkg.v1.Service/Get",
+
+		backendAttempts: []TestRetryAttempt{
+			{grpcStatusCode: 13},
+			{grpcStatusCode: 0},
+		},
+
+		expBackendCalls:      1,
+		expPreviousAttempts:  []string{""},
+		expGrpcResBody:       []byte{},
+		expTrailersOnly:      true,
+		expGrpcResStatusCode: 13,
+		expGrpcResStatusMsg:  "attempt 1",
+	}
+	testRetryRequest(t, data)
+}
+
+func TestRetryConfiguredCode(t *testing.T) {
+	data := TestRetryData{
+		cfgRetryableCodes: []string{"resource_exhausted", "13"},
+
+		reqPath: "/pkg.v1.Service/Get",
+
+		backendAttempts: []TestRetryAttempt{
+			{grpcStatusCode: 13},
+			{grpcStatusCode: 8},
+			{grpcStatusCode: 0},
+		},
+
+		expBackendCalls:      3,
+		expPreviousAttempts:  []string{"", "1", "2"},
+		expGrpcResBody:       []byte{},
+		expTrailersOnly:      true,
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "attempt 3",
+	}
+	testRetryRequest(t, data)
+}
+
+func TestRetryNegativePushback(t *testing.T) {
+	data := TestRetryData{
+		reqPath: "/pkg.v1.Service/Get",
+
+		backendAttempts: []TestRetryAttempt{
+			{grpcStatusCode: 14, retryPushback: "-1"},
+			{grpcStatusCode: 0},
+		},
+
+		expBackendCalls:      1,
+		expPreviousAttempts:  []string{""},
+		expGrpcResBody:       []byte{},
+		expTrailersOnly:      true,
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "attempt 1",
+	}
+	testRetryRequest(t, data)
+}
+
+func TestRetryAfterMessageNotRetried(t *testing.T) {
+	data := TestRetryData{
+		reqPath: "/pkg.v1.Service/Get",
+
+		backendAttempts: []TestRetryAttempt{
+			{grpcStatusCode: 14, grpcMessage: []byte{0x08, 0x01}},
+			{grpcStatusCode: 0},
+		},
+
+		expBackendCalls:      1,
+		expPreviousAttempts:  []string{""},
+		expGrpcResBody:       []byte{0x00, 0x00, 0x00, 0x00, 0x02, 0x08, 0x01},
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "attempt 1",
+	}
+	testRetryRequest(t, data)
+}
+
+func TestRetryOtherMethodNotRetried(t *testing.T) {
+	data := TestRetryData{
+		reqPath: "/pkg.v1.Service/Update",
+
+		backendAttempts: []TestRetryAttempt{
+			{grpcStatusCode: 14},
+			{grpcStatusCode: 0},
+		},
+
+		expBackendCalls:      1,
+		expPreviousAttempts:  []string{""},
+		expGrpcResBody:       []byte{},
+		expTrailersOnly:      true,
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "attempt 1",
+	}
+	testRetryRequest(t, data)
+}
+
+func TestRetryLargeRequestNotRetried(t *testing.T) {
+	data := TestRetryData{
+		cfgMaxRequestSize: 4,
+
+		reqPath: "/pkg.v1.Service/Get",
+		reqBody: []byte{0x00, 0x00, 0x00, 0x00, 0x02, 0x08, 0x01},
+
+		backendAttempts: []TestRetryAttempt{
+			{grpcStatusCode: 14},
+			{grpcStatusCode: 0},
+		},
+
+		expBackendCalls:      1,
+		expPreviousAttempts:  []string{""},
+		expGrpcResBody:       []byte{},
+		expTrailersOnly:      true,
+		expGrpcResStatusCode: 14,
+		expGrpcResStatusMsg:  "attempt 1",
+	}
+	testRetryRequest(t, data)
+}
+
+func TestRetryProxyTimeoutNotRetried(t *testing.T) {
+	data := TestRetryData{
+		cfgProxyErrors: true,
+
+		reqPath: "/pkg.v1.Service/Get",
+
+		backendAttempts: []TestRetryAttempt{
+			{httpStatusCode: 504},
+			{grpcStatusCode: 0},
+		},
+
+		expBackendCalls:      1,
+		expPreviousAttempts:  []string{""},
+		expGrpcResBody:       []byte{0x00, 0x00, 0x00, 0x00, 0x00},
+		expGrpcResStatusCode: 4,
+		expGrpcResStatusMsg:  "proxy: 504 Gateway Timeout",
+	}
+	testRetryRequest(t, data)
+}
+
+func TestRetryInvalidPolicy(t *testing.T) {
+	cfg := http2grpc.CreateConfig()
+	cfg.Retry.Policies = []http2grpc.RetryPolicyConfig{{
+		MaxAttempts: 3, InitialBackoff: "1ms", MaxBackoff: "10ms", BackoffMultiplier: 2,
+		RetryableStatusCodes: []string{"NOT_A_CODE"},
+	}}
+
+	_, err := http2grpc.New(context.Background(), http.NotFoundHandler(), cfg, "http2grpc")
+	if err == nil {
+		t.Errorf("expected error for unknown retryable status code")
+	}
+}
+
+func testRetryRequest(t *testing.T, data TestRetryData) {
+	t.Helper()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.Retry.Policies = []http2grpc.RetryPolicyConfig{{
+		Methods:              []string{"/pkg.v1.Service/Get"},
+		MaxAttempts:          3,
+		InitialBackoff:       "1ms",
+		MaxBackoff:           "10ms",
+		BackoffMultiplier:    2,
+		RetryableStatusCodes: data.cfgRetryableCodes,
+	}}
+
+	if data.cfgMaxRequestSize != 0 {
+		cfg.Retry.MaxRequestSize = data.cfgMaxRequestSize
+	}
+
+	cfg.ProxyErrors.Enabled = data.cfgProxyErrors
+
+	var previousAttempts []string
+
+	ctx := context.Background()
+
+	if data.reqDeadline != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, data.reqDeadline)
+
+		defer cancel()
+	}
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		attempt := data.backendAttempts[len(previousAttempts)]
+		previousAttempts = append(previousAttempts, req.Header.Get("grpc-previous-rpc-attempts"))
+
+		if data.reqDeadline != 0 && len(previousAttempts) > 1 {
+			assertGrpcTimeoutWithin(t, req.Header.Get("grpc-timeout"), data.reqDeadline)
+		}
+
+		body, _ := ioutil.ReadAll(req.Body)
+		if !bytes.Equal(body, data.reqBody) && !(len(body) == 0 && len(data.reqBody) == 0) {
+			t.Errorf("expected backend request body: `%v`, got: `%v`", data.reqBody, body)
+		}
+
+		if attempt.httpStatusCode != 0 {
+			rw.WriteHeader(attempt.httpStatusCode)
+			rw.Write([]byte(http.StatusText(attempt.httpStatusCode)))
+
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/grpc")
+
+		if attempt.grpcMessage == nil {
+			// trailers-only response
+			rw.Header().Set("grpc-status", strconv.Itoa(attempt.grpcStatusCode))
+			rw.Header().Set("grpc-message", "attempt "+strconv.Itoa(len(previousAttempts)))
+
+			if attempt.retryPushback != "" {
+				rw.Header().Set("grpc-retry-pushback-ms", attempt.retryPushback)
+			}
+
+			rw.WriteHeader(http.StatusOK)
+
+			return
+		}
+
+		rw.Header().Set("Trailer", "grpc-status, grpc-message")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write(append([]byte{0x00, 0x00, 0x00, 0x00, byte(len(attempt.grpcMessage))}, attempt.grpcMessage...))
+		rw.Header().Set("grpc-status", strconv.Itoa(attempt.grpcStatusCode))
+
+		if attempt.grpcStatusCode != 0 {
+			rw.Header().Set("grpc-message", "attempt "+strconv.Itoa(len(previousAttempts)))
+		}
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost"+data.reqPath, bytes.NewReader(data.reqBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+
+	if len(previousAttempts) != data.expBackendCalls {
+		t.Errorf("expected backend calls: `%d`, got: `%d`", data.expBackendCalls, len(previousAttempts))
+	}
+
+	assertArrayHeader(t, &http.Response{Header: http.Header{"Attempts": previousAttempts}}, "Attempts", data.expPreviousAttempts)
+	assertStatusCode(t, resp, http.StatusOK)
+	assertBody(t, resp, data.expGrpcResBody)
+	assertHeader(t, resp, "Content-Type", "application/grpc")
+
+	if data.expTrailersOnly {
+		assertHeader(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+		assertHeader(t, resp, "grpc-message", data.expGrpcResStatusMsg)
+
+		return
+	}
+
+	assertTrailer(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+	assertTrailer(t, resp, "grpc-message", data.expGrpcResStatusMsg)
+}
+
+func assertGrpcTimeoutWithin(t *testing.T, value string, max time.Duration) {
+	t.Helper()
+
+	units := map[byte]time.Duration{
+		'H': time.Hour, 'M': time.Minute, 'S': time.Second, 'm': time.Millisecond, 'u': time.Microsecond, 'n': 1,
+	}
+
+	if value == "" {
+		t.Errorf("expected header `grpc-timeout`, got none")
+		return
+	}
+
+	amount, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	unit, ok := units[value[len(value)-1]]
+	timeout := time.Duration(amount) * unit
+
+	if err != nil || !ok || timeout <= 0 || timeout > max {
+		t.Errorf("expected header `grpc-timeout` in (0, %s], got: `%s`", max, value)
+	}
+}
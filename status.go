@@ -0,0 +1,34 @@
+package http2grpc
+
+import (
+	"github.com/v-electrolux/http2grpc/grpc"
+	"github.com/v-electrolux/http2grpc/internal/pb"
+	"net/http"
+)
+
+// GrpcStatusDetailsHeaderName is the trailer with base64 encoded google.rpc.Status,
+// gRPC implementations use it to transfer error details.
+const GrpcStatusDetailsHeaderName = grpc.StatusDetailsHeaderName
+
+// grpcStatusError is the status middleware terminates the call with,
+// e.g. on violation of gRPC length-prefixed message framing.
+type grpcStatusError struct {
+	code    int
+	message string
+}
+
+func (e *grpcStatusError) Error() string {
+	return e.message
+}
+
+// setGrpcStatusTrailers sets grpc-status and grpc-message trailers,
+// and grpc-status-details-bin trailer if any details passed.
+func setGrpcStatusTrailers(header http.Header, code int, message string, details ...pb.Message) {
+	status := grpc.NewStatus(grpc.Code(code), message)
+	for _, detail := range details {
+		packed := pb.NewAny(detail)
+		status.Details = append(status.Details, grpc.Any{TypeURL: packed.TypeURL, Value: packed.Value})
+	}
+
+	status.SetTrailers(header)
+}
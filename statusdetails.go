@@ -0,0 +1,130 @@
+package http2grpc
+
+import (
+	"fmt"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"github.com/v-electrolux/http2grpc/internal/pb"
+	"sort"
+	"strings"
+)
+
+const (
+	metricStatusDetails = "http2grpc_status_details_total"
+	metricErrorInfos    = "http2grpc_error_infos_total"
+)
+
+// StatusDetailsConfig describes inspection of error details sent by gRPC backend in grpc-status-details-bin.
+type StatusDetailsConfig struct {
+	// Enabled decodes error details of gRPC backend responses, logs them at debug level and counts them in metrics
+	Enabled bool `yaml:"enabled"`
+}
+
+type statusDetailsInspector struct {
+	metrics *metrics
+}
+
+// newStatusDetailsInspector returns nil if inspection is disabled.
+func newStatusDetailsInspector(config *StatusDetailsConfig, m *metrics) *statusDetailsInspector {
+	if !config.Enabled {
+		return nil
+	}
+
+	m.register(metricStatusDetails, metricCounter, "Error details of gRPC backend responses by type.")
+	m.register(metricErrorInfos, metricCounter, "ErrorInfo details of gRPC backend responses by reason and domain.")
+
+	return &statusDetailsInspector{metrics: m}
+}
+
+// inspect decodes status of gRPC backend response, so it must be called before middleware sets its own trailers.
+func (i *statusDetailsInspector) inspect(h *http2grpcModifier) {
+	header := h.responseWriter.Header()
+	if !grpc.HasTrailer(header, GrpcStatusDetailsHeaderName) {
+		return
+	}
+
+	status, err := grpc.FromHeader(header)
+	if err != nil {
+		LoggerINFO.Printf("status details: invalid status from backend: %s", err)
+		return
+	}
+
+	LoggerDEBUG.Printf("status details: backend status code %s, message %q, %d details",
+		status.Code, status.Message, len(status.Details))
+
+	for _, packed := range status.Details {
+		typeName := packed.TypeURL[strings.LastIndex(packed.TypeURL, "/")+1:]
+		i.metrics.add(metricStatusDetails, 1, "code", status.Code.String(), "type", typeName)
+
+		detail, err := pb.UnmarshalDetail(&pb.Any{TypeURL: packed.TypeURL, Value: packed.Value})
+		if err != nil {
+			LoggerDEBUG.Printf("status details: %s", err)
+			continue
+		}
+
+		if errorInfo, ok := detail.(*pb.ErrorInfo); ok {
+			i.metrics.add(metricErrorInfos, 1, "reason", errorInfo.Reason, "domain", errorInfo.Domain)
+		}
+
+		LoggerDEBUG.Printf("status details: %s", describeDetail(detail))
+	}
+}
+
+// describeDetail formats error detail in readable form for logs.
+func describeDetail(detail pb.DetailMessage) string {
+	var parts []string
+
+	switch d := detail.(type) {
+	case *pb.ErrorInfo:
+		keys := make([]string, 0, len(d.Metadata))
+		for key := range d.Metadata {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		parts = append(parts, "reason="+d.Reason, "domain="+d.Domain)
+		for _, key := range keys {
+			parts = append(parts, key+"="+d.Metadata[key])
+		}
+	case *pb.RetryInfo:
+		if d.RetryDelay != nil {
+			parts = append(parts, "retryDelay="+d.RetryDelay.AsDuration().String())
+		}
+	case *pb.DebugInfo:
+		parts = append(parts, "detail="+d.Detail, fmt.Sprintf("stackEntries=%d", len(d.StackEntries)))
+	case *pb.QuotaFailure:
+		for _, v := range d.Violations {
+			parts = append(parts, v.Subject+": "+v.Description)
+		}
+	case *pb.PreconditionFailure:
+		for _, v := range d.Violations {
+			parts = append(parts, v.Type+" "+v.Subject+": "+v.Description)
+		}
+	case *pb.BadRequest:
+		for _, v := range d.FieldViolations {
+			parts = append(parts, v.Field+": "+v.Description)
+		}
+	case *pb.RequestInfo:
+		parts = append(parts, "requestId="+d.RequestID, "servingData="+d.ServingData)
+	case *pb.ResourceInfo:
+		parts = append(parts, "type="+d.ResourceType, "name="+d.ResourceName, "owner="+d.Owner,
+			"description="+d.Description)
+	case *pb.Help:
+		for _, link := range d.Links {
+			parts = append(parts, link.Description+" "+link.URL)
+		}
+	case *pb.LocalizedMessage:
+		parts = append(parts, d.Locale+": "+d.Message)
+	}
+
+	return detail.FullName() + "{" + strings.Join(parts, "; ") + "}"
+}
+
+// finishStatusDetails inspects status of gRPC backend response, if inspection is enabled.
+func (h *http2grpcModifier) finishStatusDetails() {
+	if h.statusDetails == nil || !h.backendUseGrpc {
+		return
+	}
+
+	h.statusDetails.inspect(h)
+}
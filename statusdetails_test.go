@@ -0,0 +1,167 @@
+package http2grpc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/v-electrolux/http2grpc"
+	"github.com/v-electrolux/http2grpc/internal/pb"
+)
+
+type TestStatusDetailsData struct {
+	cfgEnabled bool
+
+	// resDetails are sent by backend in grpc-status-details-bin trailer, it is not sent if empty
+	resDetails []pb.Message
+	// resDetailsValue overrides encoded grpc-status-details-bin trailer
+	resDetailsValue string
+
+	expGrpcResStatusCode string
+	expLogLines          []string
+	expMetrics           []string
+}
+
+func TestStatusDetailsLoggedAndCounted(t *testing.T) {
+	data := TestStatusDetailsData{
+		cfgEnabled: true,
+
+		resDetails: []pb.Message{
+			&pb.ErrorInfo{Reason: "EMAIL_TAKEN", Domain: "users.example.com", Metadata: map[string]string{"id": "7"}},
+			&pb.BadRequest{FieldViolations: []*pb.FieldViolation{
+				{Field: "email", Description: "already taken"},
+				{Field: "age", Description: "must be positive"},
+			}},
+			&pb.RetryInfo{RetryDelay: pb.NewDuration(1500 * time.Millisecond)},
+		},
+
+		expGrpcResStatusCode: "6",
+		expLogLines: []string{
+			`backend status code ALREADY_EXISTS, message "user exists", 3 details`,
+			"google.rpc.ErrorInfo{reason=EMAIL_TAKEN; domain=users.example.com; id=7}",
+			"google.rpc.BadRequest{email: already taken; age: must be positive}",
+			"google.rpc.RetryInfo{retryDelay=1.5s}",
+		},
+		expMetrics: []string{
+			`http2grpc_error_infos_total{reason="EMAIL_TAKEN",domain="users.example.com"} 1`,
+			`http2grpc_status_details_total{code="ALREADY_EXISTS",type="google.rpc.BadRequest"} 1`,
+			`http2grpc_status_details_total{code="ALREADY_EXISTS",type="google.rpc.ErrorInfo"} 1`,
+			`http2grpc_status_details_total{code="ALREADY_EXISTS",type="google.rpc.RetryInfo"} 1`,
+		},
+	}
+	testStatusDetailsRequest(t, data)
+}
+
+func TestStatusDetailsUnknownTypeCounted(t *testing.T) {
+	data := TestStatusDetailsData{
+		cfgEnabled: true,
+
+		resDetails: []pb.Message{&pb.HealthCheckResponse{Status: pb.ServingStatusServing}},
+
+		expGrpcResStatusCode: "6",
+		expLogLines:          []string{`unknown error detail type "type.googleapis.com/grpc.health.v1.HealthCheckResponse"`},
+		expMetrics: []string{
+			`http2grpc_status_details_total{code="ALREADY_EXISTS",type="grpc.health.v1.HealthCheckResponse"} 1`,
+		},
+	}
+	testStatusDetailsRequest(t, data)
+}
+
+func TestStatusDetailsInvalidValuePassedThrough(t *testing.T) {
+	data := TestStatusDetailsData{
+		cfgEnabled: true,
+
+		resDetailsValue: "!!!",
+
+		expGrpcResStatusCode: "6",
+		expLogLines:          nil,
+		expMetrics:           []string{"# TYPE http2grpc_status_details_total counter"},
+	}
+	testStatusDetailsRequest(t, data)
+}
+
+func TestStatusDetailsDisabled(t *testing.T) {
+	data := TestStatusDetailsData{
+		cfgEnabled: false,
+
+		resDetails: []pb.Message{&pb.ErrorInfo{Reason: "EMAIL_TAKEN"}},
+
+		expGrpcResStatusCode: "6",
+		expLogLines:          nil,
+		expMetrics:           nil,
+	}
+	testStatusDetailsRequest(t, data)
+}
+
+func testStatusDetailsRequest(t *testing.T, data TestStatusDetailsData) {
+	t.Helper()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.LogLevel = "debug"
+	cfg.StatusDetails.Enabled = data.cfgEnabled
+	cfg.Metrics.Path = "/metrics"
+
+	detailsValue := data.resDetailsValue
+	if len(data.resDetails) > 0 {
+		status := &pb.Status{Code: 6, Message: "user exists"}
+		for _, detail := range data.resDetails {
+			status.Details = append(status.Details, pb.NewAny(detail))
+		}
+
+		detailsValue = base64.RawStdEncoding.EncodeToString(status.Marshal())
+	}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/grpc")
+		rw.Header().Set("Trailer", "grpc-status, grpc-message")
+		rw.WriteHeader(http.StatusOK)
+		rw.Header().Set("grpc-status", "6")
+		rw.Header().Set("grpc-message", "user exists")
+		rw.Header().Set(http.TrailerPrefix+"grpc-status-details-bin", detailsValue)
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+
+	http2grpc.LoggerDEBUG.SetOutput(&logs)
+	defer http2grpc.LoggerDEBUG.SetOutput(ioutil.Discard)
+	defer http2grpc.LoggerINFO.SetOutput(ioutil.Discard)
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost/users.v1.Users/Create", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertTrailer(t, resp, "grpc-status", data.expGrpcResStatusCode)
+	assertTrailer(t, resp, "grpc-message", "user exists")
+	assertTrailer(t, resp, "grpc-status-details-bin", detailsValue)
+
+	for _, line := range data.expLogLines {
+		if !strings.Contains(logs.String(), line) {
+			t.Errorf("expected log line: `%s`, got logs:\n%s", line, logs.String())
+		}
+	}
+
+	if !data.cfgEnabled && strings.Contains(logs.String(), "status details:") {
+		t.Errorf("expected no status details logs, got logs:\n%s", logs.String())
+	}
+
+	assertMetrics(t, handler, data.expMetrics)
+}
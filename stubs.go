@@ -0,0 +1,167 @@
+package http2grpc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"github.com/v-electrolux/http2grpc/internal/pb"
+	"io/ioutil"
+	"net/http"
+)
+
+// StubResponseConfig describes response to gRPC method answered by middleware itself.
+type StubResponseConfig struct {
+	// Method is glob pattern of gRPC methods, like `/package.Service/Method`
+	Method string `yaml:"method"`
+	// Code is gRPC status code, response has no message if it is not 0
+	Code int `yaml:"code"`
+	// Message is gRPC status message
+	Message string `yaml:"message"`
+	// Base64 is response message as base64 encoded protobuf
+	Base64 string `yaml:"base64"`
+	// JSON is response message as JSON, encoded to protobuf by MessageType from descriptor set
+	JSON string `yaml:"json"`
+	// MessageType is full name of response message type, like `package.Reply`
+	MessageType string `yaml:"messageType"`
+}
+
+// StubsConfig describes gRPC methods answered by middleware without calling backend.
+type StubsConfig struct {
+	// DescriptorSetFile is path to binary google.protobuf.FileDescriptorSet,
+	// e.g. produced by `protoc --include_imports --descriptor_set_out`
+	DescriptorSetFile string `yaml:"descriptorSetFile"`
+	// DescriptorSet is base64 encoded binary google.protobuf.FileDescriptorSet, alternative to the file
+	DescriptorSet string `yaml:"descriptorSet"`
+	// Responses are checked in order, the first matching one is sent
+	Responses []StubResponseConfig `yaml:"responses"`
+}
+
+type stubResponse struct {
+	config  *StubResponseConfig
+	methods methodMatcher
+	// message is encoded response message, nil for status-only response
+	message []byte
+}
+
+type stubs struct {
+	responses []stubResponse
+}
+
+// newStubs returns nil if there are no stub responses.
+func newStubs(config *StubsConfig) (*stubs, error) {
+	if len(config.Responses) == 0 {
+		return nil, nil //nolint:nilnil // disabled feature
+	}
+
+	descriptors, err := loadDescriptorSet(config)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &stubs{responses: make([]stubResponse, 0, len(config.Responses))}
+
+	for i := range config.Responses {
+		responseConfig := &config.Responses[i]
+
+		response, err := newStubResponse(responseConfig, descriptors)
+		if err != nil {
+			return nil, fmt.Errorf("ERROR: http2grpc: stubs.responses[%d]: %w", i, err)
+		}
+
+		s.responses = append(s.responses, response)
+	}
+
+	return s, nil
+}
+
+func newStubResponse(config *StubResponseConfig, descriptors *pb.Descriptors) (stubResponse, error) {
+	methods, err := newMethodMatcher([]string{config.Method})
+	if err != nil {
+		return stubResponse{}, err
+	}
+
+	response := stubResponse{config: config, methods: methods}
+
+	switch {
+	case config.Code < grpc.OK || config.Code > grpc.UNAUTHENTICATED:
+		return stubResponse{}, fmt.Errorf("code %d is not gRPC code", config.Code)
+	case config.Code != grpc.OK && (config.Base64 != "" || config.JSON != ""):
+		return stubResponse{}, fmt.Errorf("base64 and json can be set only for code 0, got %d", config.Code)
+	case config.Code != grpc.OK:
+	case config.Base64 != "" && config.JSON != "":
+		return stubResponse{}, fmt.Errorf("only one of base64 and json can be set")
+	case config.Base64 != "":
+		if response.message, err = base64.StdEncoding.DecodeString(config.Base64); err != nil {
+			return stubResponse{}, fmt.Errorf("base64: %w", err)
+		}
+	case config.JSON != "":
+		if descriptors == nil {
+			return stubResponse{}, fmt.Errorf("json requires descriptor set")
+		}
+
+		if response.message, err = descriptors.EncodeJSON(config.MessageType, []byte(config.JSON)); err != nil {
+			return stubResponse{}, err
+		}
+	default:
+		// empty message is valid encoding of any message with default values
+		response.message = []byte{}
+	}
+
+	return response, nil
+}
+
+func loadDescriptorSet(config *StubsConfig) (*pb.Descriptors, error) {
+	var raw []byte
+
+	var err error
+
+	switch {
+	case config.DescriptorSetFile != "":
+		if raw, err = ioutil.ReadFile(config.DescriptorSetFile); err != nil {
+			return nil, fmt.Errorf("ERROR: http2grpc: stubs.descriptorSetFile: %w", err)
+		}
+	case config.DescriptorSet != "":
+		if raw, err = base64.StdEncoding.DecodeString(config.DescriptorSet); err != nil {
+			return nil, fmt.Errorf("ERROR: http2grpc: stubs.descriptorSet: %w", err)
+		}
+	default:
+		return nil, nil //nolint:nilnil // descriptor set is optional
+	}
+
+	descriptors, err := pb.ParseFileDescriptorSet(raw)
+	if err != nil {
+		return nil, fmt.Errorf("ERROR: http2grpc: stubs descriptor set: %w", err)
+	}
+
+	return descriptors, nil
+}
+
+// intercept answers gRPC method by the first matching stub response.
+func (s *stubs) intercept(rw *http2grpcModifier, req *http.Request) bool {
+	method := grpcMethod(req)
+
+	for i := range s.responses {
+		response := &s.responses[i]
+		if !response.methods.match(method) {
+			continue
+		}
+
+		LoggerDEBUG.Printf("stub: %s answered by %s", method, response.config.Method)
+
+		if response.message == nil {
+			rw.writeGrpcStatus(response.config.Code, response.config.Message)
+			return true
+		}
+
+		if err := rw.writeGrpcMessage(response.message); err != nil {
+			LoggerDEBUG.Printf("stub: response write failed: %s", err)
+			return true
+		}
+
+		setGrpcStatusTrailers(rw.Header(), grpc.OK, response.config.Message)
+
+		return true
+	}
+
+	return false
+}
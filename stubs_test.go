@@ -0,0 +1,172 @@
+package http2grpc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/v-electrolux/http2grpc"
+)
+
+// stubDescriptorSet is FileDescriptorSet of stub/v1/stub.proto with message stub.v1.Reply,
+// the proto is described in internal/pb/json_test.go.
+const stubDescriptorSet = "CroEChJzdHViL3YxL3N0dWIucHJvdG8SB3N0dWIudjEi6AMKBVJlcGx5EhIKBG5hbWUYASABKAlSBG5hbWUSFAoFY291" +
+	"bnQYAiABKAVSBWNvdW50EhAKA2lkcxgDIAMoA1IDaWRzEicKBGtpbmQYBCABKA4yEy5zdHViLnYxLlJlcGx5LktpbmRSBGtpbmQSIQoE" +
+	"aXRlbRgFIAEoCzINLnN0dWIudjEuSXRlbVIEaXRlbRIjCgVpdGVtcxgGIAMoCzINLnN0dWIudjEuSXRlbVIFaXRlbXMSMgoGbGFiZWxz" +
+	"GAcgAygLMhouc3R1Yi52MS5SZXBseS5MYWJlbHNFbnRyeVIGbGFiZWxzEhIKBGRhdGEYCCABKAxSBGRhdGESFAoFcmF0aW8YCSABKAFS" +
+	"BXJhdGlvEg4KAm9rGAogASgIUgJvaxIUCgVkZWx0YRgLIAEoEVIFZGVsdGESDgoCZngYDCABKAZSAmZ4EhQKBXNjb3JlGA0gASgCUgVz" +
+	"Y29yZRIXCgd1c2VyX2lkGA4gASgJUgZ1c2VySWQaOQoLTGFiZWxzRW50cnkSEAoDa2V5GAEgASgJUgNrZXkSFAoFdmFsdWUYAiABKAVS" +
+	"BXZhbHVlOgI4ASI0CgRLaW5kEhQKEEtJTkRfVU5TUEVDSUZJRUQQABIKCgZLSU5EX0EQARIKCgZLSU5EX0IQAiIoCgRJdGVtEg4KAmlk" +
+	"GAEgASgJUgJpZBIQCgNxdHkYAiABKA1SA3F0eWIGcHJvdG8z"
+
+type TestStubData struct {
+	cfgStubs http2grpc.StubsConfig
+
+	reqPath string
+
+	expBackendCalled     bool
+	expGrpcResBody       []byte
+	expGrpcResStatusCode int
+	expGrpcResStatusMsg  string
+}
+
+func TestStubStatusOnly(t *testing.T) {
+	data := TestStubData{
+		cfgStubs: http2grpc.StubsConfig{
+			Responses: []http2grpc.StubResponseConfig{
+				{Method: "/stub.v1.Service/*", Code: 12, Message: "not implemented in stub"},
+			},
+		},
+
+		reqPath: "/stub.v1.Service/Get",
+
+		expGrpcResBody:       []byte{0x00, 0x00, 0x00, 0x00, 0x00},
+		expGrpcResStatusCode: 12,
+		expGrpcResStatusMsg:  "not implemented in stub",
+	}
+	testStubRequest(t, data)
+}
+
+func TestStubBase64Message(t *testing.T) {
+	data := TestStubData{
+		cfgStubs: http2grpc.StubsConfig{
+			Responses: []http2grpc.StubResponseConfig{
+				{Method: "/stub.v1.Service/Get", Base64: "CgRzdHVi"},
+			},
+		},
+
+		reqPath: "/stub.v1.Service/Get",
+
+		expGrpcResBody:       []byte{0x00, 0x00, 0x00, 0x00, 0x06, 0x0a, 0x04, 's', 't', 'u', 'b'},
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testStubRequest(t, data)
+}
+
+func TestStubJSONMessage(t *testing.T) {
+	data := TestStubData{
+		cfgStubs: http2grpc.StubsConfig{
+			DescriptorSet: stubDescriptorSet,
+			Responses: []http2grpc.StubResponseConfig{
+				{Method: "/stub.v1.Service/List", Code: 5},
+				{Method: "/stub.v1.Service/Get", JSON: `{"name":"stub","ok":true}`, MessageType: "stub.v1.Reply"},
+			},
+		},
+
+		reqPath: "/stub.v1.Service/Get",
+
+		expGrpcResBody:       []byte{0x00, 0x00, 0x00, 0x00, 0x08, 0x0a, 0x04, 's', 't', 'u', 'b', 0x50, 0x01},
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testStubRequest(t, data)
+}
+
+func TestStubOtherMethodPassed(t *testing.T) {
+	data := TestStubData{
+		cfgStubs: http2grpc.StubsConfig{
+			Responses: []http2grpc.StubResponseConfig{
+				{Method: "/stub.v1.Service/Get", Base64: "CgRzdHVi"},
+			},
+		},
+
+		reqPath: "/stub.v1.Service/List",
+
+		expBackendCalled:     true,
+		expGrpcResBody:       []byte{0x00, 0x00, 0x00, 0x00, 0x00},
+		expGrpcResStatusCode: 0,
+		expGrpcResStatusMsg:  "",
+	}
+	testStubRequest(t, data)
+}
+
+func TestStubJSONWithoutDescriptorSet(t *testing.T) {
+	cfg := http2grpc.CreateConfig()
+	cfg.Stubs.Responses = []http2grpc.StubResponseConfig{
+		{Method: "/stub.v1.Service/Get", JSON: `{"name":"stub"}`, MessageType: "stub.v1.Reply"},
+	}
+
+	_, err := http2grpc.New(context.Background(), http.NotFoundHandler(), cfg, "http2grpc")
+	if err == nil {
+		t.Errorf("expected error for json stub without descriptor set")
+	}
+}
+
+func TestStubMessageWithErrorCode(t *testing.T) {
+	cfg := http2grpc.CreateConfig()
+	cfg.Stubs.Responses = []http2grpc.StubResponseConfig{
+		{Method: "/stub.v1.Service/Get", Code: 5, Base64: "CgRzdHVi"},
+	}
+
+	_, err := http2grpc.New(context.Background(), http.NotFoundHandler(), cfg, "http2grpc")
+	if err == nil {
+		t.Errorf("expected error for base64 stub with not OK code")
+	}
+}
+
+func testStubRequest(t *testing.T, data TestStubData) {
+	t.Helper()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.Stubs = data.cfgStubs
+
+	backendCalled := false
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		backendCalled = true
+
+		rw.Header().Set("Content-Type", "application/grpc")
+		rw.Header().Set("Trailer", "grpc-status")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte{0x00, 0x00, 0x00, 0x00, 0x00})
+		rw.Header().Set("grpc-status", "0")
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost"+data.reqPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+
+	if backendCalled != data.expBackendCalled {
+		t.Errorf("expected backend called: `%t`, got: `%t`", data.expBackendCalled, backendCalled)
+	}
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertBody(t, resp, data.expGrpcResBody)
+	assertHeader(t, resp, "Content-Type", "application/grpc")
+	assertTrailer(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+	assertTrailer(t, resp, "grpc-message", data.expGrpcResStatusMsg)
+}
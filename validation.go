@@ -0,0 +1,145 @@
+package http2grpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"github.com/v-electrolux/http2grpc/internal/pb"
+	"strings"
+)
+
+// ValidationErrorsConfig describes conversion of validation errors in JSON body of HTTP response,
+// like {"errors":[{"field":"email","message":"invalid"}]}, to google.rpc.BadRequest detail.
+type ValidationErrorsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// StatusCodes are HTTP status codes of responses with validation errors
+	StatusCodes []int `yaml:"statusCodes"`
+	// ErrorsPath is dot separated path of errors array in JSON body, the body itself is the array if empty
+	ErrorsPath string `yaml:"errorsPath"`
+	// FieldKey, MessageKey and ReasonKey are keys of error object, reason is not filled if ReasonKey is empty
+	FieldKey   string `yaml:"fieldKey"`
+	MessageKey string `yaml:"messageKey"`
+	ReasonKey  string `yaml:"reasonKey"`
+}
+
+type validationErrors struct {
+	config      *ValidationErrorsConfig
+	statusCodes map[int]bool
+	errorsPath  []string
+}
+
+// newValidationErrors returns nil if conversion of validation errors is disabled.
+func newValidationErrors(config *ValidationErrorsConfig) (*validationErrors, error) {
+	if !config.Enabled {
+		return nil, nil //nolint:nilnil // disabled feature
+	}
+
+	if len(config.StatusCodes) == 0 {
+		return nil, fmt.Errorf("ERROR: http2grpc: validationErrors.statusCodes is empty")
+	}
+
+	if config.FieldKey == "" || config.MessageKey == "" {
+		return nil, fmt.Errorf("ERROR: http2grpc: validationErrors.fieldKey and validationErrors.messageKey must be set")
+	}
+
+	v := &validationErrors{config: config, statusCodes: make(map[int]bool, len(config.StatusCodes))}
+	for _, statusCode := range config.StatusCodes {
+		if statusCode < 400 || statusCode > 599 {
+			return nil, fmt.Errorf("ERROR: http2grpc: validationErrors.statusCodes: %d is not HTTP error", statusCode)
+		}
+
+		v.statusCodes[statusCode] = true
+	}
+
+	if config.ErrorsPath != "" {
+		v.errorsPath = strings.Split(config.ErrorsPath, ".")
+	}
+
+	return v, nil
+}
+
+// apply replaces status of conversion with INVALID_ARGUMENT, summary message and BadRequest detail,
+// if body of HTTP response has validation errors.
+func (v *validationErrors) apply(conversion *Conversion) {
+	if !v.statusCodes[conversion.HTTPStatus] {
+		return
+	}
+
+	violations := v.parse(conversion.Body)
+	if len(violations) == 0 {
+		LoggerDEBUG.Printf("validation errors: no errors found in body of HTTP status %d", conversion.HTTPStatus)
+		return
+	}
+
+	summary := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		if violation.Field == "" {
+			summary = append(summary, violation.Description)
+		} else {
+			summary = append(summary, violation.Field+": "+violation.Description)
+		}
+	}
+
+	detail := pb.NewAny(&pb.BadRequest{FieldViolations: violations})
+
+	conversion.Code = grpc.INVALID_ARGUMENT
+	conversion.Message = truncateMessage(strings.Join(summary, "; "))
+	conversion.Details = append(conversion.Details, grpc.Any{TypeURL: detail.TypeURL, Value: detail.Value})
+}
+
+// parse returns field violations of errors array, elements which are not objects are skipped.
+func (v *validationErrors) parse(body []byte) []*pb.FieldViolation {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		LoggerDEBUG.Printf("validation errors: body is not JSON: %s", err)
+		return nil
+	}
+
+	for _, key := range v.errorsPath {
+		object, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		doc = object[key]
+	}
+
+	elements, ok := doc.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	violations := make([]*pb.FieldViolation, 0, len(elements))
+
+	for _, element := range elements {
+		object, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		violation := &pb.FieldViolation{
+			Field:       jsonScalar(object[v.config.FieldKey]),
+			Description: jsonScalar(object[v.config.MessageKey]),
+		}
+
+		if v.config.ReasonKey != "" {
+			violation.Reason = jsonScalar(object[v.config.ReasonKey])
+		}
+
+		violations = append(violations, violation)
+	}
+
+	return violations
+}
+
+// jsonScalar formats decoded JSON string, number or bool, other values are empty.
+func jsonScalar(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case float64, bool:
+		return fmt.Sprint(value)
+	default:
+		return ""
+	}
+}
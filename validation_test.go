@@ -0,0 +1,210 @@
+package http2grpc_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/v-electrolux/http2grpc"
+	"github.com/v-electrolux/http2grpc/grpc"
+	"github.com/v-electrolux/http2grpc/internal/pb"
+)
+
+type TestValidationErrorsData struct {
+	cfgEnabled    bool
+	cfgErrorsPath string
+	cfgReasonKey  string
+
+	backendStatusCode int
+	backendBody       string
+
+	expGrpcResStatusCode int
+	expGrpcResStatusMsg  string
+	expViolations        []*pb.FieldViolation
+}
+
+func TestValidationErrorsBadRequest(t *testing.T) {
+	data := TestValidationErrorsData{
+		cfgEnabled: true,
+
+		backendStatusCode: http.StatusBadRequest,
+		backendBody:       `{"errors":[{"field":"email","message":"invalid"},{"field":"age","message":"must be positive"}]}`,
+
+		expGrpcResStatusCode: grpc.INVALID_ARGUMENT,
+		expGrpcResStatusMsg:  "email: invalid; age: must be positive",
+		expViolations: []*pb.FieldViolation{
+			{Field: "email", Description: "invalid"},
+			{Field: "age", Description: "must be positive"},
+		},
+	}
+	testValidationErrorsRequest(t, data)
+}
+
+func TestValidationErrorsUnprocessableEntityWithPathAndReason(t *testing.T) {
+	data := TestValidationErrorsData{
+		cfgEnabled:    true,
+		cfgErrorsPath: "error.details",
+		cfgReasonKey:  "code",
+
+		backendStatusCode: http.StatusUnprocessableEntity,
+		backendBody: `{"error":{"details":[{"field":"items.0.qty","message":"too large","code":"MAX"},` +
+			`"skipped",{"message":"order is empty","code":17}]}}`,
+
+		expGrpcResStatusCode: grpc.INVALID_ARGUMENT,
+		expGrpcResStatusMsg:  "items.0.qty: too large; order is empty",
+		expViolations: []*pb.FieldViolation{
+			{Field: "items.0.qty", Description: "too large", Reason: "MAX"},
+			{Description: "order is empty", Reason: "17"},
+		},
+	}
+	testValidationErrorsRequest(t, data)
+}
+
+func TestValidationErrorsLongSummaryTruncated(t *testing.T) {
+	errors := make([]string, 0, 30)
+	summary := make([]string, 0, 30)
+	violations := make([]*pb.FieldViolation, 0, 30)
+
+	for i := 0; i < 30; i++ {
+		field := fmt.Sprintf("field%02d", i)
+		errors = append(errors, `{"field":"`+field+`","message":"must be positive"}`)
+		summary = append(summary, field+": must be positive")
+		violations = append(violations, &pb.FieldViolation{Field: field, Description: "must be positive"})
+	}
+
+	data := TestValidationErrorsData{
+		cfgEnabled: true,
+
+		backendStatusCode: http.StatusBadRequest,
+		backendBody:       `{"errors":[` + strings.Join(errors, ",") + `]}`,
+
+		expGrpcResStatusCode: grpc.INVALID_ARGUMENT,
+		expGrpcResStatusMsg:  strings.Join(summary, "; ")[:256] + "...",
+		expViolations:        violations,
+	}
+	testValidationErrorsRequest(t, data)
+}
+
+func TestValidationErrorsNotJSONKeepsSpecCode(t *testing.T) {
+	data := TestValidationErrorsData{
+		cfgEnabled: true,
+
+		backendStatusCode: http.StatusBadRequest,
+		backendBody:       "bad request",
+
+		expGrpcResStatusCode: grpc.INTERNAL,
+		expGrpcResStatusMsg:  "",
+		expViolations:        nil,
+	}
+	testValidationErrorsRequest(t, data)
+}
+
+func TestValidationErrorsOtherStatusIgnored(t *testing.T) {
+	data := TestValidationErrorsData{
+		cfgEnabled: true,
+
+		backendStatusCode: http.StatusConflict,
+		backendBody:       `{"errors":[{"field":"email","message":"taken"}]}`,
+
+		expGrpcResStatusCode: grpc.UNKNOWN,
+		expGrpcResStatusMsg:  "",
+		expViolations:        nil,
+	}
+	testValidationErrorsRequest(t, data)
+}
+
+func TestValidationErrorsDisabled(t *testing.T) {
+	data := TestValidationErrorsData{
+		cfgEnabled: false,
+
+		backendStatusCode: http.StatusBadRequest,
+		backendBody:       `{"errors":[{"field":"email","message":"invalid"}]}`,
+
+		expGrpcResStatusCode: grpc.INTERNAL,
+		expGrpcResStatusMsg:  "",
+		expViolations:        nil,
+	}
+	testValidationErrorsRequest(t, data)
+}
+
+func TestValidationErrorsInvalidConfig(t *testing.T) {
+	cfg := http2grpc.CreateConfig()
+	cfg.ValidationErrors.Enabled = true
+	cfg.ValidationErrors.StatusCodes = []int{200}
+
+	_, err := http2grpc.New(context.Background(), http.NotFoundHandler(), cfg, "http2grpc")
+	if err == nil {
+		t.Errorf("expected error for not error HTTP status code")
+	}
+}
+
+func testValidationErrorsRequest(t *testing.T, data TestValidationErrorsData) {
+	t.Helper()
+
+	cfg := http2grpc.CreateConfig()
+	cfg.ValidationErrors.Enabled = data.cfgEnabled
+	cfg.ValidationErrors.ReasonKey = data.cfgReasonKey
+
+	if data.cfgErrorsPath != "" {
+		cfg.ValidationErrors.ErrorsPath = data.cfgErrorsPath
+	}
+
+	ctx := context.Background()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(data.backendStatusCode)
+		rw.Write([]byte(data.backendBody))
+	})
+
+	handler, err := http2grpc.New(ctx, next, cfg, "http2grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost/orders.v1.Orders/Create", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+
+	assertStatusCode(t, resp, http.StatusOK)
+	assertHeader(t, resp, "Content-Type", "application/grpc")
+	assertTrailer(t, resp, "grpc-status", strconv.Itoa(data.expGrpcResStatusCode))
+	assertTrailer(t, resp, "grpc-message", data.expGrpcResStatusMsg)
+
+	status, err := grpc.FromHeader(resp.Trailer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.expViolations == nil {
+		if len(status.Details) != 0 {
+			t.Errorf("expected no details, got %+v", status.Details)
+		}
+
+		return
+	}
+
+	if len(status.Details) != 1 {
+		t.Fatalf("expected one BadRequest detail, got %+v", status.Details)
+	}
+
+	detail, err := pb.UnmarshalDetail(&pb.Any{TypeURL: status.Details[0].TypeURL, Value: status.Details[0].Value})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &pb.BadRequest{FieldViolations: data.expViolations}
+	if !reflect.DeepEqual(detail, expected) {
+		t.Errorf("expected detail %+v, got %+v", expected, detail)
+	}
+}